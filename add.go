@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// recordFields holds every field any record type may take, as given through
+// the add and update flags
+type recordFields struct {
+	name         string
+	content      string
+	ttl          int
+	priority     int
+	weight       uint
+	port         uint
+	sshAlgorithm int
+	sshType      int
+	redirectType int
+}
+
+// recordFlags maps every record flag to its key in the record url.Values
+var recordFlags = map[string]string{
+	"name":          "name",
+	"content":       "content",
+	"ttl":           "ttl",
+	"prio":          "prio",
+	"weight":        "weight",
+	"port":          "port",
+	"ssh-algorithm": "ssh_algorithm",
+	"ssh-type":      "ssh_type",
+	"redirect-type": "prio",
+}
+
+// addRecordFlags defines the flags for every record field in a command
+func addRecordFlags(flags *pflag.FlagSet) {
+	flags.String("name", "@", "Record name, @ for the domain itself")
+	flags.String("content", "", "Record content, or URL for Redirect records")
+	flags.Int("ttl", structures.TTL10800, "Record TTL in seconds")
+	flags.Int("prio", structures.PRIORITY10, "Priority for MX and SRV records")
+	flags.Uint("weight", 0, "Weight for SRV records")
+	flags.Uint("port", 0, "Port for SRV records")
+	flags.Int("ssh-algorithm", 0, "Algorithm for SSHFP records")
+	flags.Int("ssh-type", 0, "Fingerprint type for SSHFP records")
+	flags.Int(
+		"redirect-type", structures.REDIRECTTYPE301,
+		"HTTP status code for Redirect records",
+	)
+}
+
+// readRecordFields reads all the record flags of a command
+func readRecordFields(flags *pflag.FlagSet) (recordFields, error) {
+	var f recordFields
+	var err error
+
+	if f.name, err = flags.GetString("name"); err != nil {
+		return f, err
+	}
+	if f.content, err = flags.GetString("content"); err != nil {
+		return f, err
+	}
+	if f.ttl, err = flags.GetInt("ttl"); err != nil {
+		return f, err
+	}
+	if f.priority, err = flags.GetInt("prio"); err != nil {
+		return f, err
+	}
+	if f.weight, err = flags.GetUint("weight"); err != nil {
+		return f, err
+	}
+	if f.port, err = flags.GetUint("port"); err != nil {
+		return f, err
+	}
+	if f.sshAlgorithm, err = flags.GetInt("ssh-algorithm"); err != nil {
+		return f, err
+	}
+	if f.sshType, err = flags.GetInt("ssh-type"); err != nil {
+		return f, err
+	}
+	if f.redirectType, err = flags.GetInt("redirect-type"); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// newRecord builds a record of the given type from the fields, through the
+// NewRecord*With constructors with the capabilities of the domain
+func newRecord(
+	caps structures.Capabilities, recordType string, f recordFields,
+) (records.Record, error) {
+	switch strings.ToUpper(recordType) {
+	case "A":
+		r, err := records.NewRecordAWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "AAAA":
+		r, err := records.NewRecordAAAAWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "CNAME":
+		r, err := records.NewRecordCNAMEWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "MX":
+		r, err := records.NewRecordMXWith(
+			caps, f.name, f.content, f.ttl, f.priority,
+		)
+		return &r, err
+	case "TXT":
+		r, err := records.NewRecordTXTWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "SRV":
+		r, err := records.NewRecordSRVWith(
+			caps, f.name, f.content, f.ttl, f.priority, f.weight, f.port,
+		)
+		return &r, err
+	case "CAA":
+		r, err := records.NewRecordCAAWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "PTR":
+		r, err := records.NewRecordPTRWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "NS":
+		r, err := records.NewRecordNSWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "TLSA":
+		r, err := records.NewRecordTLSAWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "REDIRECT":
+		r, err := records.NewRecordRedirectWith(
+			caps, f.name, f.content, f.redirectType,
+		)
+		return &r, err
+	case "DYNAMIC":
+		r, err := records.NewRecordDynamicWith(caps, f.name, f.content, f.ttl)
+		return &r, err
+	case "SSHFP":
+		r, err := records.NewRecordSSHFPWith(
+			caps, f.name, f.content, f.ttl, f.sshAlgorithm, f.sshType,
+		)
+		return &r, err
+	default:
+		return nil, fmt.Errorf("Unknown record type: %s", recordType)
+	}
+}
+
+func addRecord(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+	recordType := args[1]
+
+	fields, err := readRecordFields(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return err
+	}
+
+	record, err := newRecord(
+		domainCapabilities(njalla, domain), recordType, fields,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := njalla.AddRecord(domain, record); err != nil {
+		return err
+	}
+
+	return waitForChanges(cmd, domain, records.Records{record}, nil)
+}
+
+func updateRecord(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+	recordID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("RecordID is not a valid int value: %s", args[1])
+	}
+
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return err
+	}
+
+	stored, err := njalla.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+
+	current, found := stored.FindByID(recordID)
+	if !found {
+		return fmt.Errorf("Record %d doesn't exist in %s", recordID, domain)
+	}
+
+	// Start from the current fields and only change those given as flags
+	values := current.GetURLValues()
+	changed := 0
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if key, ok := recordFlags[flag.Name]; ok {
+			values.Set(key, flag.Value.String())
+			changed++
+		}
+	})
+
+	if changed == 0 {
+		return fmt.Errorf("No fields to update were given")
+	}
+
+	fields := recordFields{
+		name:    values.Get("name"),
+		content: values.Get("content"),
+	}
+	ints := map[string]*int{
+		"ttl":           &fields.ttl,
+		"prio":          &fields.priority,
+		"ssh_algorithm": &fields.sshAlgorithm,
+		"ssh_type":      &fields.sshType,
+	}
+	for key, field := range ints {
+		if value := values.Get(key); value != "" {
+			if *field, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("Field %s is not a valid int: %s", key, value)
+			}
+		}
+	}
+	fields.redirectType = fields.priority
+	if value := values.Get("weight"); value != "" {
+		weight, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return fmt.Errorf("Field weight is not a valid uint: %s", value)
+		}
+		fields.weight = uint(weight)
+	}
+	if value := values.Get("port"); value != "" {
+		port, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return fmt.Errorf("Field port is not a valid uint: %s", value)
+		}
+		fields.port = uint(port)
+	}
+
+	updated, err := newRecord(
+		domainCapabilities(njalla, domain), current.GetType(), fields,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = njalla.UpdateRecord(domain, recordID, updated.GetURLValues())
+	if err != nil {
+		return err
+	}
+
+	return waitForChanges(
+		cmd, domain, records.Records{updated}, records.Records{current},
+	)
+}
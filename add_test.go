@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+)
+
+// useFakeNjalla points the default profile at the fake Njalla, returning a
+// function restoring the environment
+func useFakeNjalla(t *testing.T, server *njallatest.Server) func() {
+	dir, err := ioutil.TempDir("", "njallaclient")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	configDir := filepath.Join(dir, "njallaclient")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatalf("%s", err)
+	}
+	config := fmt.Sprintf(
+		"base_url: %s\nsession_cache: %s\n",
+		server.URL, filepath.Join(dir, "session.json"),
+	)
+	err = ioutil.WriteFile(
+		filepath.Join(configDir, "config.yaml"), []byte(config), 0600,
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	env := map[string]string{
+		"XDG_CONFIG_HOME": dir,
+		"NETRC":           filepath.Join(dir, "netrc"),
+		"NJALLA_PROFILE":  "",
+		"NJALLA_EMAIL":    server.Email,
+		"NJALLA_PASSWORD": server.Password,
+	}
+	previous := make(map[string]string)
+	for key, value := range env {
+		previous[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+
+	return func() {
+		for key, value := range previous {
+			os.Setenv(key, value)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// newRecordCommand returns a command with the flags of add and update
+func newRecordCommand(args ...string) *cobra.Command {
+	cmd := &cobra.Command{
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmd.Flags().String("profile", "", "")
+	cmd.Flags().String("password-file", "", "")
+	addRecordFlags(cmd.Flags())
+	addWaitFlags(cmd)
+	cmd.Flags().Parse(args)
+	return cmd
+}
+
+func TestAddRecordValidates(t *testing.T) {
+	server := njallatest.NewServer("user@example.com", "secret")
+	defer server.Close()
+	server.Capabilities.TTLs = []int{60, 43200}
+	server.AddDomain("example.com")
+	defer useFakeNjalla(t, server)()
+
+	cmd := newRecordCommand("--content", "not-an-ip", "--ttl", "60")
+	if err := addRecord(cmd, []string{"example.com", "A"}); err == nil {
+		t.Errorf("An A record with a wrong IP was added")
+	}
+
+	// The domain doesn't accept the default TTL
+	cmd = newRecordCommand("--content", "1.1.1.1")
+	if err := addRecord(cmd, []string{"example.com", "A"}); err == nil {
+		t.Errorf("An A record with a TTL the domain doesn't accept was added")
+	}
+
+	if server.Posts() != 0 {
+		t.Fatalf("Invalid records were sent to Njalla")
+	}
+
+	// But it accepts one that isn't a default
+	cmd = newRecordCommand("--content", "1.1.1.1", "--ttl", "43200")
+	if err := addRecord(cmd, []string{"example.com", "A"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	added := server.Records("example.com")
+	if len(added) != 1 || added[0].GetTTL() != 43200 {
+		t.Fatalf("Record wasn't added: %s", added)
+	}
+
+	id := fmt.Sprint(added[0].GetID())
+	cmd = newRecordCommand("--content", "not-an-ip")
+	if err := updateRecord(cmd, []string{"example.com", id}); err == nil {
+		t.Errorf("An A record was updated with a wrong IP")
+	}
+
+	if got := server.Records("example.com"); !cmp.Equal(added, got) {
+		t.Errorf("Record was changed:\n%s", cmp.Diff(added, got))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/acme"
+)
+
+// certbotChallenge is a DNS-01 challenge as given by certbot to its manual
+// hooks through environment variables
+type certbotChallenge struct {
+	// Domain being validated, without the `*.` of wildcards
+	Domain string
+	// Validation is the content of the TXT record
+	Validation string
+	// Remaining is how many challenges are left after this one
+	Remaining int
+}
+
+// FQDN returns the fully qualified name of the challenge's TXT record
+func (c certbotChallenge) FQDN() string {
+	return fmt.Sprintf("_acme-challenge.%s.", c.Domain)
+}
+
+// certbotEnv reads the challenge from CERTBOT_DOMAIN, CERTBOT_VALIDATION and
+// CERTBOT_REMAINING_CHALLENGES
+func certbotEnv() (certbotChallenge, error) {
+	challenge := certbotChallenge{
+		Domain:     os.Getenv("CERTBOT_DOMAIN"),
+		Validation: os.Getenv("CERTBOT_VALIDATION"),
+	}
+
+	if challenge.Domain == "" || challenge.Validation == "" {
+		return challenge, fmt.Errorf(
+			"CERTBOT_DOMAIN and CERTBOT_VALIDATION must be set, run this " +
+				"command as a certbot manual hook",
+		)
+	}
+
+	if remaining := os.Getenv("CERTBOT_REMAINING_CHALLENGES"); remaining != "" {
+		count, err := strconv.Atoi(remaining)
+		if err != nil {
+			return challenge, fmt.Errorf(
+				"CERTBOT_REMAINING_CHALLENGES is not a valid int value: %s",
+				remaining,
+			)
+		}
+		challenge.Remaining = count
+	}
+
+	return challenge, nil
+}
+
+// certbotProvider logs in and returns the provider solving the challenges
+func certbotProvider(cmd *cobra.Command) (*acme.DNSProvider, error) {
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return acme.NewDNSProvider(njalla, nil)
+}
+
+func certbotAuth(cmd *cobra.Command, args []string) error {
+	delay, _ := cmd.Flags().GetDuration("delay")
+
+	challenge, err := certbotEnv()
+	if err != nil {
+		return err
+	}
+
+	dnsProvider, err := certbotProvider(cmd)
+	if err != nil {
+		return err
+	}
+
+	err = dnsProvider.AddChallenge(challenge.FQDN(), challenge.Validation)
+	if err != nil {
+		return err
+	}
+
+	// Certbot checks every challenge after the last hook returns, so waiting
+	// once is enough for all the records
+	if challenge.Remaining == 0 && delay > 0 {
+		fmt.Fprintf(
+			os.Stderr, "Waiting %s for the records to propagate\n", delay,
+		)
+		time.Sleep(delay)
+	}
+
+	return nil
+}
+
+func certbotCleanup(cmd *cobra.Command, args []string) error {
+	challenge, err := certbotEnv()
+	if err != nil {
+		return err
+	}
+
+	dnsProvider, err := certbotProvider(cmd)
+	if err != nil {
+		return err
+	}
+
+	return dnsProvider.RemoveChallenge(challenge.FQDN(), challenge.Validation)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func TestCertbotHooks(t *testing.T) {
+	server := njallatest.NewServer("user@example.com", "secret")
+	defer server.Close()
+	server.AddDomain("example.com")
+	server.AddDomain("sub.example.com")
+
+	dir, err := ioutil.TempDir("", "njallaclient")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	configDir := filepath.Join(dir, "njallaclient")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatalf("%s", err)
+	}
+	session := filepath.Join(dir, "session.json")
+	config := fmt.Sprintf("base_url: %s\nsession_cache: %s\n", server.URL, session)
+	err = ioutil.WriteFile(
+		filepath.Join(configDir, "config.yaml"), []byte(config), 0600,
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for key, value := range map[string]string{
+		"XDG_CONFIG_HOME":              dir,
+		"NETRC":                        filepath.Join(dir, "netrc"),
+		"NJALLA_PROFILE":               "",
+		"NJALLA_EMAIL":                 "user@example.com",
+		"NJALLA_PASSWORD":              "secret",
+		"CERTBOT_DOMAIN":               "www.sub.example.com",
+		"CERTBOT_VALIDATION":           "first",
+		"CERTBOT_REMAINING_CHALLENGES": "1",
+	} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	cmd := &cobra.Command{
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmd.Flags().String("profile", "", "")
+	cmd.Flags().String("password-file", "", "")
+	cmd.Flags().Duration("delay", 0, "")
+
+	if err := certbotAuth(cmd, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// The session is cached, so the second hook doesn't need the password.
+	// The profile has no email, so the cache is keyed on NJALLA_EMAIL
+	cached := filepath.Join(dir, "session-user@example.com.json")
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("Session wasn't cached: %s", err)
+	}
+	os.Setenv("NJALLA_PASSWORD", "")
+
+	os.Setenv("CERTBOT_VALIDATION", "second")
+	os.Setenv("CERTBOT_REMAINING_CHALLENGES", "0")
+	if err := certbotAuth(cmd, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	challenges := records.ByName("_acme-challenge.www")
+	if got := server.Records("sub.example.com").Filter(challenges); len(got) != 2 {
+		t.Fatalf("Expected 2 challenge records, got:\n%s", got)
+	}
+
+	os.Setenv("CERTBOT_VALIDATION", "first")
+	if err := certbotCleanup(cmd, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	got := server.Records("sub.example.com").Filter(challenges)
+	if len(got) != 1 || got[0].GetContent() != "second" {
+		t.Errorf("Cleanup removed the wrong record, left:\n%s", got)
+	}
+
+	if len(server.Records("example.com")) != 0 {
+		t.Errorf("Records were added to the parent domain")
+	}
+}
+
+func TestCertbotHooksDontPrompt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "njallaclient")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for key, value := range map[string]string{
+		"XDG_CONFIG_HOME": dir,
+		"NETRC":           filepath.Join(dir, "netrc"),
+		"NJALLA_EMAIL":    "user@example.com",
+		"NJALLA_PASSWORD": "",
+	} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	cmd := &cobra.Command{
+		Use:         "certbot-auth",
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmd.Flags().String("password-file", "", "")
+
+	if _, _, err := credentials(cmd, profile{}, true); err == nil {
+		t.Errorf("Missing password should fail instead of prompting")
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultProfile is the name of the profile defined by the top level keys
+// of the configuration file
+const defaultProfile = "default"
+
+// nonInteractiveAnnotation marks the commands run unattended, such as
+// certbot hooks, which fail instead of prompting for missing credentials
+const nonInteractiveAnnotation = "non-interactive"
+
+// profile is a Njalla account, with its own credentials and session
+type profile struct {
+	Email string `yaml:"email"`
+	// PasswordFile is a file with the password
+	PasswordFile string `yaml:"password_file"`
+	// PasswordCommand is run through `sh -c`, and its output, without the
+	// trailing new line, is used as the password
+	PasswordCommand string `yaml:"password_command"`
+	BaseURL         string `yaml:"base_url"`
+	// SessionCache is the file where the session is kept between runs,
+	// by default <user cache dir>/njallaclient/<profile>-session.json
+	SessionCache string `yaml:"session_cache"`
+}
+
+// config is the njallaclient configuration file, read from
+// $XDG_CONFIG_HOME/njallaclient/config.yaml, see configPath. The top level keys define the
+// `default` profile, and any other is defined under `profiles`:
+//
+//	email: me@example.com
+//	password_command: pass show njalla
+//	default_profile: work
+//	profiles:
+//	  work:
+//	    email: me@work.example.com
+//	    password_file: ~/.njalla-work
+type config struct {
+	profile        `yaml:",inline"`
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]profile `yaml:"profiles"`
+}
+
+// getProfile returns a profile by name
+func (c *config) getProfile(name string) (profile, error) {
+	if p, ok := c.Profiles[name]; ok {
+		return p, nil
+	}
+
+	if name == defaultProfile {
+		return c.profile, nil
+	}
+
+	return profile{}, fmt.Errorf("Profile %s doesn't exist in the config", name)
+}
+
+// profileNames returns the name of every profile in the config, sorted. The
+// default profile is only included if it's defined, or if there's no other
+func (c *config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles)+1)
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+
+	_, exists := c.Profiles[defaultProfile]
+	if !exists && (c.profile != profile{} || len(names) == 0) {
+		names = append(names, defaultProfile)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// selectedProfile returns the name of the profile to use, from the --profile
+// flag, the NJALLA_PROFILE environment variable or the config, in that order
+func selectedProfile(cmd *cobra.Command, c *config) (string, error) {
+	name, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		name = os.Getenv("NJALLA_PROFILE")
+	}
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+
+	return name, nil
+}
+
+// configPath returns the path to the configuration file, under
+// $XDG_CONFIG_HOME or, if it isn't set, ~/.config, on every platform
+func configPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "njallaclient", "config.yaml"), nil
+}
+
+// loadConfig reads the configuration file. A missing file isn't an error,
+// and gives an empty configuration
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return &config{}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var c config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("Couldn't parse config file %s: %s", path, err)
+	}
+
+	return &c, nil
+}
+
+// configuredEmail returns the email to log in with a profile, from the same
+// sources as credentials but without prompting. It's empty if the email
+// would have to be prompted for
+func configuredEmail(p profile, useOverrides bool) (string, error) {
+	if useOverrides {
+		if email := os.Getenv("NJALLA_EMAIL"); email != "" {
+			return email, nil
+		}
+	}
+
+	if p.Email != "" {
+		return p.Email, nil
+	}
+
+	login, _, err := readNetrc(netrcMachine(p))
+	return login, err
+}
+
+// credentials resolves the email and password to log in with a profile.
+// Each of them is taken from the first of these sources that has it:
+//
+//  1. The --password-file flag, for the password only
+//  2. The NJALLA_EMAIL and NJALLA_PASSWORD environment variables
+//  3. The profile, with `email`, `password_file` and `password_command`
+//  4. The entry for the Njalla host in ~/.netrc
+//  5. An interactive prompt, unless the command is marked with
+//     nonInteractiveAnnotation
+//
+// The flag and environment variables are skipped if useOverrides is false,
+// such as when logging in to every profile
+func credentials(
+	cmd *cobra.Command, p profile, useOverrides bool,
+) (string, string, error) {
+	email, err := configuredEmail(p, useOverrides)
+	if err != nil {
+		return "", "", err
+	}
+
+	var password string
+	passwordFile := p.PasswordFile
+	if useOverrides {
+		flagFile, err := cmd.Flags().GetString("password-file")
+		if err != nil {
+			return "", "", err
+		}
+		if flagFile != "" {
+			passwordFile = flagFile
+		}
+
+		if flagFile == "" {
+			password = os.Getenv("NJALLA_PASSWORD")
+		}
+	}
+
+	if password == "" && passwordFile != "" {
+		data, err := ioutil.ReadFile(expandHome(passwordFile))
+		if err != nil {
+			return "", "", fmt.Errorf("Couldn't read password file: %s", err)
+		}
+		password = strings.TrimSpace(string(data))
+	}
+
+	if password == "" && p.PasswordCommand != "" {
+		var err error
+		password, err = runPasswordCommand(p.PasswordCommand)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if password == "" {
+		_, netrcPassword, err := readNetrc(netrcMachine(p))
+		if err != nil {
+			return "", "", err
+		}
+		password = netrcPassword
+	}
+
+	if (email == "" || password == "") &&
+		cmd.Annotations[nonInteractiveAnnotation] != "" {
+		return "", "", fmt.Errorf(
+			"No credentials found, and %s doesn't prompt for them", cmd.Name(),
+		)
+	}
+
+	if email == "" {
+		// Prompts go to stderr to keep stdout clean for the output
+		fmt.Fprint(os.Stderr, "Username: ")
+		reader := bufio.NewReader(os.Stdin)
+		username, err := reader.ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		email = strings.TrimSpace(username)
+	}
+
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		pw, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		if err != nil {
+			return "", "", err
+		}
+		password = strings.TrimSpace(string(pw))
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return email, password, nil
+}
+
+// netrcMachine returns the host looked up in ~/.netrc for a profile
+func netrcMachine(p profile) string {
+	if p.BaseURL != "" {
+		if parsed, err := url.Parse(p.BaseURL); err == nil && parsed.Host != "" {
+			return parsed.Hostname()
+		}
+	}
+	return "njal.la"
+}
+
+// expandHome replaces a leading ~ in a path with the user home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+func runPasswordCommand(command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Password command failed: %s", err)
+	}
+
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// readNetrc returns the login and password for a machine in ~/.netrc, or
+// the `default` entry if there's none for the machine. A missing file isn't
+// an error, and gives empty credentials
+func readNetrc(machine string) (string, string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	login, password := parseNetrc(string(data), machine)
+	return login, password, nil
+}
+
+// parseNetrc finds the login and password for a machine in the contents of
+// a netrc file
+func parseNetrc(data string, machine string) (string, string) {
+	type entry struct{ login, password string }
+
+	var current *entry
+	var found, fallback *entry
+
+	tokens := strings.Fields(data)
+	for i := 0; i < len(tokens); i++ {
+		next := func() string {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+			return ""
+		}
+
+		switch tokens[i] {
+		case "machine":
+			current = &entry{}
+			if next() == machine && found == nil {
+				found = current
+			}
+		case "default":
+			current = &entry{}
+			if fallback == nil {
+				fallback = current
+			}
+		case "login":
+			if value := next(); current != nil {
+				current.login = value
+			}
+		case "password":
+			if value := next(); current != nil {
+				current.password = value
+			}
+		case "account":
+			next()
+		case "macdef":
+			// Macro definitions run until an empty line, which can't be told
+			// apart once split, so stop here as they're usually last
+			i = len(tokens)
+		}
+	}
+
+	if found != nil {
+		return found.login, found.password
+	}
+	if fallback != nil {
+		return fallback.login, fallback.password
+	}
+	return "", ""
+}
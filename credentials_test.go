@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+)
+
+func TestParseNetrc(t *testing.T) {
+	data := `machine example.com login other password nope
+machine njal.la
+	login me@example.com
+	password secret
+default login anon password anon
+`
+
+	login, password := parseNetrc(data, "njal.la")
+	if login != "me@example.com" || password != "secret" {
+		t.Errorf("Unexpected credentials: %s %s", login, password)
+	}
+
+	login, password = parseNetrc(data, "missing.org")
+	if login != "anon" || password != "anon" {
+		t.Errorf("Default entry wasn't used: %s %s", login, password)
+	}
+
+	login, password = parseNetrc("machine example.com login a", "njal.la")
+	if login != "" || password != "" {
+		t.Errorf("Expected no credentials: %s %s", login, password)
+	}
+}
+
+func TestCredentialsPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "njallaclient")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	netrc := filepath.Join(dir, "netrc")
+	err = ioutil.WriteFile(
+		netrc, []byte("machine njal.la login netrc password netrc"), 0600,
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	passwordFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(passwordFile, []byte("file\n"), 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for key, value := range map[string]string{
+		"NETRC":           netrc,
+		"XDG_CONFIG_HOME": dir,
+		"NJALLA_EMAIL":    "",
+		"NJALLA_PASSWORD": "env",
+	} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, value)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("password-file", "", "")
+
+	email, password, err := credentials(cmd, profile{}, true)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if email != "netrc" || password != "env" {
+		t.Errorf("Unexpected credentials: %s %s", email, password)
+	}
+
+	cmd.Flags().Set("password-file", passwordFile)
+
+	email, password, err = credentials(cmd, profile{}, true)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if email != "netrc" || password != "file" {
+		t.Errorf("Unexpected credentials: %s %s", email, password)
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	data := []byte(`
+email: default@example.com
+default_profile: work
+profiles:
+  work:
+    email: me@work.example.com
+    base_url: https://work.example.com
+  personal:
+    email: me@example.com
+`)
+
+	var c config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{"default", "personal", "work"}
+	if names := c.profileNames(); !cmp.Equal(expected, names) {
+		t.Errorf("Profile names don't match:\n%s", cmp.Diff(expected, names))
+	}
+
+	defer os.Setenv("NJALLA_PROFILE", os.Getenv("NJALLA_PROFILE"))
+	os.Setenv("NJALLA_PROFILE", "")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("profile", "", "")
+
+	name, err := selectedProfile(cmd, &c)
+	if err != nil || name != "work" {
+		t.Errorf("Expected the work profile, got %s: %v", name, err)
+	}
+
+	os.Setenv("NJALLA_PROFILE", "personal")
+	if name, _ := selectedProfile(cmd, &c); name != "personal" {
+		t.Errorf("Expected the personal profile, got %s", name)
+	}
+
+	cmd.Flags().Set("profile", "default")
+	name, _ = selectedProfile(cmd, &c)
+	p, err := c.getProfile(name)
+	if err != nil || p.Email != "default@example.com" {
+		t.Errorf("Unexpected default profile %+v: %v", p, err)
+	}
+
+	if netrcMachine(c.Profiles["work"]) != "work.example.com" {
+		t.Errorf("Unexpected netrc machine for the work profile")
+	}
+
+	if _, err := c.getProfile("missing"); err == nil {
+		t.Errorf("Missing profile should fail")
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", "/home/user")
+
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	path, err := configPath()
+	if err != nil || path != "/xdg/njallaclient/config.yaml" {
+		t.Errorf("Unexpected path with XDG_CONFIG_HOME: %s %v", path, err)
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", "")
+	path, err = configPath()
+	if err != nil || path != "/home/user/.config/njallaclient/config.yaml" {
+		t.Errorf("Unexpected path without XDG_CONFIG_HOME: %s %v", path, err)
+	}
+}
+
+func TestSessionCachedPerEmail(t *testing.T) {
+	server := njallatest.NewServer("user@example.com", "secret")
+	defer server.Close()
+	defer useFakeNjalla(t, server)()
+
+	if _, err := loginCLI(newRecordCommand()); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// The cached session is of another account, so it isn't reused and
+	// logging in with the wrong password fails
+	os.Setenv("NJALLA_EMAIL", "other@example.com")
+	os.Setenv("NJALLA_PASSWORD", "wrong")
+	if _, err := loginCLI(newRecordCommand()); err == nil {
+		t.Errorf("The session of user@example.com was reused")
+	}
+
+	os.Setenv("NJALLA_EMAIL", "user@example.com")
+	if _, err := loginCLI(newRecordCommand()); err != nil {
+		t.Errorf("The session of user@example.com wasn't reused: %s", err)
+	}
+}
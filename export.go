@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func exportRecords(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+
+	format, err := exportFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return err
+	}
+
+	records, err := njalla.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+
+	return printRecords(os.Stdout, format, domain, records)
+}
+
+// exportFormat returns the format given with --output, or with the
+// deprecated --format alias. Unlike the other commands, export writes a
+// zone file by default
+func exportFormat(cmd *cobra.Command) (string, error) {
+	if cmd.Flags().Changed("format") {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return "", err
+		}
+		return checkOutputFormat(format)
+	}
+
+	if !cmd.Flags().Changed("output") {
+		return "zone", nil
+	}
+	return outputFormat(cmd)
+}
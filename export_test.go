@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func TestExportedJSONCanBeLinted(t *testing.T) {
+	exported := records.Records{
+		&records.RecordA{
+			ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300,
+		},
+		&records.RecordMX{
+			ID: 2, Type: "MX", Name: "@", Content: "mail.example.com.",
+			TTL: 3600, Priority: 10,
+		},
+		&records.RecordSRV{
+			ID: 3, Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com.",
+			TTL: 3600, Priority: 10, Weight: 5, Port: 5060,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "njallaclient")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "records.json")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	err = printRecords(file, "json", "example.com", exported)
+	file.Close()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	got, err := readRecordsFile(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !cmp.Equal(exported, got) {
+		t.Errorf("Records don't match:\n%s", cmp.Diff(exported, got))
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	newExportCommand := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("output", "o", "table", "")
+		cmd.Flags().String("format", "zone", "")
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatalf("%s", err)
+		}
+		return cmd
+	}
+
+	cases := map[string][]string{
+		"zone":  nil,
+		"json":  {"-o", "json"},
+		"jsonl": {"--format", "jsonl"},
+	}
+	for expected, args := range cases {
+		format, err := exportFormat(newExportCommand(args...))
+		if err != nil || format != expected {
+			t.Errorf("Expected %s for %v, got %s: %v", expected, args, format, err)
+		}
+	}
+
+	if _, err := exportFormat(newExportCommand("--format", "csv")); err == nil {
+		t.Errorf("Unknown formats should fail")
+	}
+}
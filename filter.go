@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// addFilterFlags adds the --type, --name and --content-regex flags read by
+// recordFilters. The verb completes each flag's help, as in "Only list"
+func addFilterFlags(cmd *cobra.Command, verb string) {
+	cmd.Flags().String("type", "", verb+" records of this type")
+	cmd.Flags().String("name", "", verb+" records with this name")
+	cmd.Flags().String(
+		"content-regex", "", verb+" records whose content matches",
+	)
+}
+
+// recordFilters returns the predicates for the filter flags given, see
+// addFilterFlags. Names may be relative to the domain or fully qualified
+func recordFilters(
+	cmd *cobra.Command, domain string,
+) ([]records.Predicate, error) {
+	recordType, _ := cmd.Flags().GetString("type")
+	name, _ := cmd.Flags().GetString("name")
+	contentRegex, _ := cmd.Flags().GetString("content-regex")
+
+	predicates := make([]records.Predicate, 0)
+	if recordType != "" {
+		predicates = append(predicates, records.ByType(recordType))
+	}
+	if name != "" {
+		predicates = append(
+			predicates, records.ByName(records.CanonicalName(domain, name)),
+		)
+	}
+	if contentRegex != "" {
+		re, err := regexp.Compile(contentRegex)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --content-regex: %s", err)
+		}
+		predicates = append(predicates, records.ContentMatches(re))
+	}
+
+	return predicates, nil
+}
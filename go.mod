@@ -4,7 +4,12 @@ go 1.13
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/go-acme/lego/v3 v3.6.0
 	github.com/google/go-cmp v0.4.0
+	github.com/libdns/libdns v0.2.1
+	github.com/miekg/dns v1.1.27
 	github.com/spf13/cobra v0.0.6
-	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	github.com/spf13/pflag v1.0.3
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+func importZone(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+	path := args[1]
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	// Without logging in, the zone is checked against the default
+	// capabilities rather than those of the domain
+	var njalla *provider.Provider
+	caps := structures.DefaultCapabilities()
+	if !dryRun {
+		if njalla, err = loginCLI(cmd); err != nil {
+			return err
+		}
+		caps = domainCapabilities(njalla, domain)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zone, report, err := records.ParseZoneFileWith(file, domain, caps)
+	if err != nil {
+		return fmt.Errorf("Couldn't parse zone file %s: %s", path, err)
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+
+	if err := zone.WriteZoneFile(os.Stdout, domain); err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	for _, record := range zone {
+		if err := njalla.AddRecord(domain, record); err != nil {
+			return fmt.Errorf(
+				"Couldn't add %s record %s: %s",
+				record.GetType(), record.GetName(), err,
+			)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Added %d record(s) to %s\n", len(zone), domain)
+
+	return nil
+}
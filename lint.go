@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/lint"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+func lintZone(cmd *cobra.Command, args []string) error {
+	file, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+
+	if file == "" && len(args) == 0 {
+		return fmt.Errorf("Either a domain or --file is required")
+	}
+
+	origin := ""
+	if len(args) > 0 {
+		origin = args[0]
+	}
+
+	var zone records.Records
+	caps := structures.DefaultCapabilities()
+	if file != "" {
+		zone, err = readRecordsFile(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		njalla, err := loginCLI(cmd)
+		if err != nil {
+			return err
+		}
+
+		zone, err = njalla.GetRecords(origin)
+		if err != nil {
+			return err
+		}
+		caps = domainCapabilities(njalla, origin)
+	}
+
+	findings := lint.New(lint.DefaultRulesWith(caps)...).Lint(origin, zone)
+	errors := 0
+	for _, finding := range findings {
+		fmt.Println(finding)
+		if finding.Severity == lint.Error {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("Found %d error(s) in the zone", errors)
+	}
+
+	return nil
+}
+
+// readRecordsFile reads a JSON array of records, in the same format Njalla
+// uses for its records
+func readRecordsFile(path string) (records.Records, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r records.Records
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("Couldn't parse records from %s: %s", path, err)
+	}
+
+	return r, nil
+}
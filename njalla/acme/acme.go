@@ -0,0 +1,207 @@
+// Package acme solves ACME DNS-01 challenges with Njalla TXT records. Its
+// DNSProvider implements go-acme/lego's challenge.Provider, so it can be
+// given to a lego client to get certificates for Njalla domains
+package acme
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v3/challenge"
+	"github.com/go-acme/lego/v3/challenge/dns01"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// Client is the part of provider.Provider needed to solve challenges
+type Client interface {
+	GetDomains() ([]string, error)
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	RemoveRecord(domain string, recordID int) error
+}
+
+// Config holds the settings of a DNSProvider
+type Config struct {
+	// TTL of the challenge records, one of Njalla's accepted TTLs
+	TTL int
+	// PropagationTimeout is how long lego waits for the records to be
+	// visible before giving up, checking every PollingInterval
+	PropagationTimeout time.Duration
+	PollingInterval    time.Duration
+}
+
+// NewDefaultConfig returns the lowest TTL Njalla allows, and a propagation
+// timeout long enough for Njalla's name servers to pick up the records
+func NewDefaultConfig() *Config {
+	return &Config{
+		TTL:                structures.TTL60,
+		PropagationTimeout: 5 * time.Minute,
+		PollingInterval:    dns01.DefaultPollingInterval,
+	}
+}
+
+// DNSProvider creates and removes the `_acme-challenge` TXT records of
+// DNS-01 challenges
+type DNSProvider struct {
+	client Client
+	config *Config
+
+	// mu serialises changes, since Njalla removes records by rewriting all
+	// the records of a domain, and concurrent changes could undo each other
+	mu sync.Mutex
+}
+
+var _ challenge.ProviderTimeout = (*DNSProvider)(nil)
+
+// NewDNSProvider returns a DNSProvider making its changes through a logged
+// in client. A nil config means NewDefaultConfig
+func NewDNSProvider(client Client, config *Config) (*DNSProvider, error) {
+	if client == nil {
+		return nil, fmt.Errorf("Njalla client is nil")
+	}
+	if config == nil {
+		config = NewDefaultConfig()
+	}
+
+	// Fail early on a TTL Njalla wouldn't accept
+	if _, err := records.NewRecordTXT("_acme-challenge", "-", config.TTL); err != nil {
+		return nil, err
+	}
+
+	return &DNSProvider{client: client, config: config}, nil
+}
+
+// Present creates the TXT record for a challenge. Wildcard and SAN
+// certificates get a record each, even when they share the same name
+func (d *DNSProvider) Present(domain, token, keyAuth string) error {
+	return d.AddChallenge(dns01.GetRecord(domain, keyAuth))
+}
+
+// CleanUp removes the TXT record created by Present for the same challenge
+func (d *DNSProvider) CleanUp(domain, token, keyAuth string) error {
+	return d.RemoveChallenge(dns01.GetRecord(domain, keyAuth))
+}
+
+// AddChallenge creates a TXT record with the value at a fully qualified name,
+// such as `_acme-challenge.example.com.`, in the Njalla domain it belongs to.
+// If an identical record already exists, no new one is created
+func (d *DNSProvider) AddChallenge(fqdn, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	zone, name, err := d.locate(fqdn)
+	if err != nil {
+		return err
+	}
+
+	existing, err := d.challengeRecords(zone, name, value)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	record, err := records.NewRecordTXT(name, value, d.config.TTL)
+	if err != nil {
+		return err
+	}
+
+	if err := d.client.AddRecord(zone, record); err != nil {
+		return fmt.Errorf("Couldn't add TXT record %s in %s: %s", name, zone, err)
+	}
+	return nil
+}
+
+// RemoveChallenge removes the TXT records created by AddChallenge, found by
+// their value, so any other challenge records at the same name are left
+// alone. It doesn't fail if the record is already gone
+func (d *DNSProvider) RemoveChallenge(fqdn, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	zone, name, err := d.locate(fqdn)
+	if err != nil {
+		return err
+	}
+
+	existing, err := d.challengeRecords(zone, name, value)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range existing {
+		if err := d.client.RemoveRecord(zone, record.GetID()); err != nil {
+			return fmt.Errorf(
+				"Couldn't remove TXT record %s in %s: %s", name, zone, err,
+			)
+		}
+	}
+	return nil
+}
+
+// Timeout returns how long lego should wait for the records to propagate,
+// and how often to check
+func (d *DNSProvider) Timeout() (timeout, interval time.Duration) {
+	return d.config.PropagationTimeout, d.config.PollingInterval
+}
+
+// locate returns the Njalla domain holding a fully qualified name, and the
+// name relative to it
+func (d *DNSProvider) locate(fqdn string) (zone string, name string, err error) {
+	domains, err := d.client.GetDomains()
+	if err != nil {
+		return "", "", fmt.Errorf("Couldn't get domains: %s", err)
+	}
+
+	zone, err = FindZone(domains, fqdn)
+	if err != nil {
+		return "", "", err
+	}
+
+	return zone, records.CanonicalName(zone, fqdn), nil
+}
+
+// challengeRecords returns the TXT records at a name with exactly the value
+func (d *DNSProvider) challengeRecords(
+	zone, name, value string,
+) (records.Records, error) {
+	stored, err := d.client.GetRecords(zone)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't get records of %s: %s", zone, err)
+	}
+
+	return stored.Filter(
+		records.ByType("TXT"), records.ByName(name),
+		func(record records.Record) bool {
+			return record.GetContent() == value
+		},
+	), nil
+}
+
+// FindZone returns the domain a fully qualified name belongs to, picking the
+// longest one when several match, as with example.com and sub.example.com
+func FindZone(domains []string, fqdn string) (string, error) {
+	name := strings.ToLower(dns01.UnFqdn(fqdn))
+
+	zone := ""
+	longest := 0
+	for _, domain := range domains {
+		candidate := strings.ToLower(dns01.UnFqdn(domain))
+		if name != candidate && !strings.HasSuffix(name, "."+candidate) {
+			continue
+		}
+		if len(candidate) > longest {
+			zone, longest = domain, len(candidate)
+		}
+	}
+
+	if zone == "" {
+		return "", fmt.Errorf("No Njalla domain found for %s", dns01.UnFqdn(fqdn))
+	}
+	return zone, nil
+}
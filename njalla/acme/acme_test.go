@@ -0,0 +1,165 @@
+package acme
+
+import (
+	"testing"
+
+	"github.com/go-acme/lego/v3/challenge/dns01"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func newTestProvider(t *testing.T) (*DNSProvider, *njallatest.Server) {
+	server := njallatest.NewServer("user@example.com", "secret")
+	server.AddDomain("example.com", &records.RecordA{
+		Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800,
+	})
+	server.AddDomain("sub.example.com")
+
+	client, err := server.Login()
+	if err != nil {
+		server.Close()
+		t.Fatalf("%s", err)
+	}
+
+	p, err := NewDNSProvider(client, nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("%s", err)
+	}
+
+	return p, server
+}
+
+func txtContents(r records.Records, name string) []string {
+	contents := make([]string, 0)
+	for _, record := range r.Filter(records.ByType("TXT"), records.ByName(name)) {
+		contents = append(contents, record.GetContent())
+	}
+	return contents
+}
+
+func TestPresentAndCleanUpSharedName(t *testing.T) {
+	p, server := newTestProvider(t)
+	defer server.Close()
+
+	// A wildcard and its base domain share the same challenge name. lego
+	// strips the `*.` of wildcards before calling the provider
+	_, apexValue := dns01.GetRecord("example.com", "apex-key")
+	_, wildcardValue := dns01.GetRecord("example.com", "wildcard-key")
+
+	if err := p.Present("example.com", "", "apex-key"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Present("example.com", "", "wildcard-key"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	// Presenting again mustn't duplicate the record
+	if err := p.Present("example.com", "", "apex-key"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{apexValue, wildcardValue}
+	got := txtContents(server.Records("example.com"), "_acme-challenge")
+	if !cmp.Equal(expected, got) {
+		t.Errorf("Challenge records don't match:\n%s", cmp.Diff(expected, got))
+	}
+
+	if err := p.CleanUp("example.com", "", "apex-key"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected = []string{wildcardValue}
+	got = txtContents(server.Records("example.com"), "_acme-challenge")
+	if !cmp.Equal(expected, got) {
+		t.Errorf("Cleanup removed the wrong record:\n%s", cmp.Diff(expected, got))
+	}
+
+	// Cleaning up twice is fine
+	if err := p.CleanUp("example.com", "", "apex-key"); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	if err := p.CleanUp("example.com", "", "wildcard-key"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	remaining := server.Records("example.com")
+	if len(remaining) != 1 || remaining[0].GetType() != "A" {
+		t.Errorf("Unrelated records were changed:\n%s", remaining)
+	}
+}
+
+func TestPresentInSubdomainZone(t *testing.T) {
+	p, server := newTestProvider(t)
+	defer server.Close()
+
+	if err := p.Present("www.sub.example.com", "", "key"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	_, value := dns01.GetRecord("www.sub.example.com", "key")
+	got := txtContents(server.Records("sub.example.com"), "_acme-challenge.www")
+	if !cmp.Equal([]string{value}, got) {
+		t.Errorf("Unexpected records in sub.example.com: %v", got)
+	}
+
+	if len(server.Records("example.com").Filter(records.ByType("TXT"))) != 0 {
+		t.Errorf("Record was added to the parent domain")
+	}
+}
+
+func TestNewDNSProviderRejectsTTL(t *testing.T) {
+	config := NewDefaultConfig()
+	config.TTL = 61
+
+	if _, err := NewDNSProvider(&fakeClient{}, config); err == nil {
+		t.Errorf("TTL 61 should be rejected")
+	}
+}
+
+func TestFindZone(t *testing.T) {
+	domains := []string{"example.com", "sub.example.com", "example.org"}
+
+	tests := map[string]string{
+		"_acme-challenge.example.com.":         "example.com",
+		"_acme-challenge.www.sub.example.com.": "sub.example.com",
+		"_acme-challenge.Sub.Example.com":      "sub.example.com",
+		"_acme-challenge.notexample.com.":      "",
+		"_acme-challenge.example.net.":         "",
+	}
+
+	for fqdn, expected := range tests {
+		zone, err := FindZone(domains, fqdn)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%s shouldn't match any zone, got %s", fqdn, zone)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", fqdn, err)
+		} else if zone != expected {
+			t.Errorf("%s: expected zone %s, got %s", fqdn, expected, zone)
+		}
+	}
+}
+
+// fakeClient is a Client that's never called
+type fakeClient struct{}
+
+func (c *fakeClient) GetDomains() ([]string, error) { return nil, nil }
+
+func (c *fakeClient) GetRecords(domain string) (records.Records, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) AddRecord(domain string, record records.Record) error {
+	return nil
+}
+
+func (c *fakeClient) RemoveRecord(domain string, recordID int) error {
+	return nil
+}
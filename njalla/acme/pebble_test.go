@@ -0,0 +1,135 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/go-acme/lego/v3/challenge/dns01"
+	"github.com/go-acme/lego/v3/lego"
+	"github.com/go-acme/lego/v3/registration"
+	"github.com/miekg/dns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// pebbleUser is the ACME account used against Pebble
+type pebbleUser struct {
+	key          crypto.PrivateKey
+	registration *registration.Resource
+}
+
+func (u *pebbleUser) GetEmail() string                        { return "" }
+func (u *pebbleUser) GetRegistration() *registration.Resource { return u.registration }
+func (u *pebbleUser) GetPrivateKey() crypto.PrivateKey        { return u.key }
+
+// TestPebble gets a wildcard certificate from a Pebble ACME test server,
+// with the challenge records stored in the fake Njalla server. It runs only
+// when PEBBLE_DIRECTORY is set to Pebble's directory URL, such as
+// https://localhost:14000/dir. Pebble must resolve names through the DNS
+// server this test starts on PEBBLE_DNS_ADDR, 127.0.0.1:8053 by default:
+//
+//	pebble -dnsserver 127.0.0.1:8053
+//
+// and LEGO_CA_CERTIFICATES must point to the CA of Pebble's HTTPS endpoint
+func TestPebble(t *testing.T) {
+	directory := os.Getenv("PEBBLE_DIRECTORY")
+	if directory == "" {
+		t.Skip("PEBBLE_DIRECTORY not set")
+	}
+
+	dnsAddr := os.Getenv("PEBBLE_DNS_ADDR")
+	if dnsAddr == "" {
+		dnsAddr = "127.0.0.1:8053"
+	}
+
+	p, server := newTestProvider(t)
+	defer server.Close()
+
+	// Serve the TXT records of the fake Njalla server to Pebble
+	dnsServer := &dns.Server{
+		Addr: dnsAddr,
+		Net:  "udp",
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Authoritative = true
+
+			for _, question := range req.Question {
+				if question.Qtype != dns.TypeTXT {
+					continue
+				}
+
+				name := records.CanonicalName("example.com", question.Name)
+				matched := server.Records("example.com").Filter(
+					records.ByType("TXT"), records.ByName(name),
+				)
+				for _, record := range matched {
+					m.Answer = append(m.Answer, &dns.TXT{
+						Hdr: dns.RR_Header{
+							Name: question.Name, Rrtype: dns.TypeTXT,
+							Class: dns.ClassINET, Ttl: uint32(record.GetTTL()),
+						},
+						Txt: []string{record.GetContent()},
+					})
+				}
+			}
+
+			w.WriteMsg(m)
+		}),
+	}
+	go dnsServer.ListenAndServe()
+	defer dnsServer.Shutdown()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	user := &pebbleUser{key: key}
+
+	config := lego.NewConfig(user)
+	config.CADirURL = directory
+
+	client, err := lego.NewClient(config)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// The fake server's records are only visible through our DNS server
+	err = client.Challenge.SetDNS01Provider(
+		p, dns01.AddRecursiveNameservers([]string{dnsAddr}),
+		dns01.AddPreCheck(func(fqdn, value string) (bool, error) {
+			return true, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	user.registration, err = client.Registration.Register(
+		registration.RegisterOptions{TermsOfServiceAgreed: true},
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cert, err := client.Certificate.Obtain(certificate.ObtainRequest{
+		Domains: []string{"example.com", "*.example.com"},
+		Bundle:  true,
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(cert.Certificate) == 0 {
+		t.Errorf("Pebble returned no certificate")
+	}
+
+	leftover := server.Records("example.com").Filter(records.ByType("TXT"))
+	if len(leftover) != 0 {
+		t.Errorf("Challenge records weren't cleaned up:\n%s", leftover)
+	}
+}
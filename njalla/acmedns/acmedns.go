@@ -0,0 +1,461 @@
+// Package acmedns serves acme-dns's HTTP API on top of Njalla, so ACME
+// clients supporting acme-dns can solve DNS-01 challenges with Njalla
+// records. Each registration gets a subdomain, whose `_acme-challenge` TXT
+// records hold the two latest values sent to /update, as acme-dns does.
+// Each registration may only touch those records, enforced as a policy, see
+// the policy package
+package acmedns
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/acme"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// passwordChars are the characters of generated passwords, as in acme-dns
+const passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"0123456789-_"
+
+// passwordLength is the length of generated passwords, as in acme-dns
+const passwordLength = 40
+
+// bcryptCost is the cost of the password hashes, lowered by the tests
+var bcryptCost = bcrypt.DefaultCost
+
+// uuidRegexp matches usernames and subdomains
+var uuidRegexp = regexp.MustCompile(
+	`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`,
+)
+
+// Client is the part of provider.Provider needed to manage the records
+type Client interface {
+	GetDomains() ([]string, error)
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	UpdateRecordTyped(domain string, record records.Record) error
+	RemoveRecords(domain string, recordIDs []int) error
+}
+
+// Server is an http.Handler serving acme-dns's /register, /update and
+// /health endpoints
+type Server struct {
+	client Client
+	store  *Store
+	// zone is where the subdomains go, such as `auth.example.com`, and
+	// domain the Njalla domain holding it
+	zone   string
+	domain string
+	mux    *http.ServeMux
+
+	// DisableRegistration makes /register answer 404, so only the
+	// registrations already in the store can be used
+	DisableRegistration bool
+	// ForwardedHeader, if set, is the header with the client's IP, such as
+	// X-Forwarded-For, for servers behind a reverse proxy. The first IP in
+	// it is checked against the allowed CIDRs
+	ForwardedHeader string
+	// Logger, if set, gets a line for every registration and update
+	Logger *log.Logger
+	// Auditor, if set, gets every update a registration wasn't allowed
+	Auditor policy.Auditor
+
+	// mu serialises updates, since each one reads the current records and
+	// then changes them
+	mu sync.Mutex
+}
+
+// NewServer returns a Server creating the subdomains of its registrations
+// under the zone, which must be one of the account's domains or a name
+// within one of them
+func NewServer(client Client, store *Store, zone string) (*Server, error) {
+	domains, err := client.GetDomains()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't get domains: %s", err)
+	}
+
+	domain, err := acme.FindZone(domains, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		client: client,
+		store:  store,
+		zone:   strings.ToLower(strings.TrimSuffix(zone, ".")),
+		domain: domain,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/register", s.handleRegister)
+	s.mux.HandleFunc("/update", s.handleUpdate)
+	s.mux.HandleFunc("/health", s.handleHealth)
+
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// FullDomain returns the fully qualified name, without the trailing dot, of
+// a subdomain's TXT records. It's the target of the CNAME records ACME
+// clients ask to create
+func (s *Server) FullDomain(subdomain string) string {
+	return "_acme-challenge." + subdomain + "." + s.zone
+}
+
+type registerRequest struct {
+	AllowFrom []string `json:"allowfrom"`
+}
+
+type registerResponse struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	FullDomain string   `json:"fulldomain"`
+	Subdomain  string   `json:"subdomain"`
+	AllowFrom  []string `json:"allowfrom"`
+}
+
+type updateRequest struct {
+	Subdomain string `json:"subdomain"`
+	TXT       string `json:"txt"`
+}
+
+type updateResponse struct {
+	TXT string `json:"txt"`
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if s.DisableRegistration {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+		return
+	}
+
+	var req registerRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed_json_payload")
+		return
+	}
+
+	allowFrom, err := ParseAllowFrom(req.AllowFrom)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_allowfrom_cidr")
+		return
+	}
+
+	username, err := newUUID()
+	if err != nil {
+		s.fail(w, "Couldn't generate a username: %s", err)
+		return
+	}
+	subdomain, err := newUUID()
+	if err != nil {
+		s.fail(w, "Couldn't generate a subdomain: %s", err)
+		return
+	}
+	password, err := newPassword()
+	if err != nil {
+		s.fail(w, "Couldn't generate a password: %s", err)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		s.fail(w, "Couldn't hash the password: %s", err)
+		return
+	}
+
+	err = s.store.Add(Registration{
+		Username:     username,
+		PasswordHash: string(hash),
+		Subdomain:    subdomain,
+		AllowFrom:    allowFrom,
+	})
+	if err != nil {
+		s.fail(w, "Couldn't store the registration: %s", err)
+		return
+	}
+
+	s.logf("%s: registered %s", s.clientIP(r), s.FullDomain(subdomain))
+	writeJSON(w, http.StatusCreated, registerResponse{
+		Username:   username,
+		Password:   password,
+		FullDomain: s.FullDomain(subdomain),
+		Subdomain:  subdomain,
+		AllowFrom:  allowFrom,
+	})
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+		return
+	}
+
+	registration, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "forbidden")
+		return
+	}
+
+	var req updateRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed_json_payload")
+		return
+	}
+
+	if !uuidRegexp.MatchString(req.Subdomain) {
+		writeError(w, http.StatusBadRequest, "bad_subdomain")
+		return
+	}
+	if !validTXT(req.TXT) {
+		writeError(w, http.StatusBadRequest, "bad_txt")
+		return
+	}
+	client := s.policyClient(registration, s.clientIP(r).String())
+	if req.Subdomain != registration.Subdomain {
+		s.logf(
+			"%s: %s may not update %s", s.clientIP(r), registration.Username,
+			req.Subdomain,
+		)
+		client.Deny(policy.Denial{
+			Operation: policy.Update,
+			Domain:    s.domain,
+			Name: records.CanonicalName(
+				s.domain, s.FullDomain(req.Subdomain),
+			),
+			Type: "TXT",
+		})
+		writeError(w, http.StatusUnauthorized, "forbidden")
+		return
+	}
+
+	err := s.update(client, registration, req.TXT)
+	if policy.IsDenied(err) {
+		s.logf("%s: %s", s.clientIP(r), err)
+		writeError(w, http.StatusUnauthorized, "forbidden")
+		return
+	}
+	if err != nil {
+		s.fail(w, "Couldn't update %s: %s", s.FullDomain(req.Subdomain), err)
+		return
+	}
+
+	s.logf("%s: updated %s", s.clientIP(r), s.FullDomain(req.Subdomain))
+	writeJSON(w, http.StatusOK, updateResponse{TXT: req.TXT})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticate returns the registration of the X-Api-User and X-Api-Key
+// headers, if the key is right and the client's IP is allowed
+func (s *Server) authenticate(r *http.Request) (Registration, bool) {
+	username := r.Header.Get("X-Api-User")
+	password := r.Header.Get("X-Api-Key")
+	if !uuidRegexp.MatchString(username) || len(password) != passwordLength {
+		return Registration{}, false
+	}
+
+	registration, exists := s.store.Get(username)
+	if !exists {
+		return registration, false
+	}
+
+	err := bcrypt.CompareHashAndPassword(
+		[]byte(registration.PasswordHash), []byte(password),
+	)
+	if err != nil {
+		s.logf("%s: wrong key for %s", s.clientIP(r), username)
+		return registration, false
+	}
+
+	if ip := s.clientIP(r); !registration.Allows(ip) {
+		s.logf("%s: %s isn't allowed from this address", ip, username)
+		return registration, false
+	}
+
+	return registration, true
+}
+
+// update sets a TXT record of the registration's subdomain to the value.
+// There are at most two records, and once both exist the older one is
+// replaced
+func (s *Server) update(
+	client *policy.PolicyProvider, registration Registration, txt string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := records.CanonicalName(s.domain, s.FullDomain(registration.Subdomain))
+
+	stored, err := client.GetRecords(s.domain)
+	if err != nil {
+		return err
+	}
+
+	existing := stored.Filter(records.ByType("TXT"), records.ByName(name))
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].GetID() < existing[j].GetID()
+	})
+
+	switch {
+	case len(existing.Filter(contentIs(txt))) > 0:
+		// Clients retry, so the value may already be there
+	case len(existing) < 2:
+		caps, err := records.CapabilitiesOf(client, s.domain)
+		if err != nil {
+			s.logf("Couldn't get the capabilities of %s: %s", s.domain, err)
+		}
+
+		record, err := records.NewRecordTXTWith(
+			caps, name, txt, structures.TTL60,
+		)
+		if err != nil {
+			return err
+		}
+		if err := client.AddRecord(s.domain, &record); err != nil {
+			return err
+		}
+	default:
+		older := existing[0]
+		if older.GetContent() == registration.LastTXT {
+			older = existing[1]
+		}
+		err := client.UpdateRecordTyped(s.domain, older.WithContent(txt))
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.store.SetLastTXT(registration.Username, txt)
+}
+
+// policyClient returns the client of a registration, which may only read,
+// add and update the TXT records of its subdomain
+func (s *Server) policyClient(
+	registration Registration, source string,
+) *policy.PolicyProvider {
+	// FullDomain is a valid pattern, since subdomains are UUIDs and the zone
+	// was found among the domains
+	p, _ := policy.New(registration.Username, []policy.Rule{{
+		Domains:    []string{s.domain},
+		Names:      []string{s.FullDomain(registration.Subdomain)},
+		Types:      []string{"TXT"},
+		Operations: []policy.Operation{policy.Read, policy.Add, policy.Update},
+	}})
+
+	client := policy.NewPolicyProvider(s.client, p, s.Auditor)
+	client.Frontend = "acmedns"
+	client.Source = source
+	return client
+}
+
+// clientIP returns the IP of the client, from ForwardedHeader if set
+func (s *Server) clientIP(r *http.Request) net.IP {
+	if s.ForwardedHeader != "" {
+		if value := r.Header.Get(s.ForwardedHeader); value != "" {
+			return net.ParseIP(strings.TrimSpace(strings.Split(value, ",")[0]))
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// fail logs an internal error and answers 500 without its details
+func (s *Server) fail(w http.ResponseWriter, format string, args ...interface{}) {
+	s.logf(format, args...)
+	writeError(w, http.StatusInternalServerError, "internal_error")
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+func contentIs(content string) records.Predicate {
+	return func(record records.Record) bool {
+		return record.GetContent() == content
+	}
+}
+
+// validTXT returns true for the 43 characters, unpadded base64url, values of
+// DNS-01 challenges
+func validTXT(txt string) bool {
+	if len(txt) != 43 {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(txt)
+	return err == nil
+}
+
+// decodeBody decodes a JSON body into v. An empty body leaves v as is
+func decodeBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<16)).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// newUUID returns a random, version 4, UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func newPassword() (string, error) {
+	password := make([]byte, passwordLength)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordChars[n.Int64()]
+	}
+	return string(password), nil
+}
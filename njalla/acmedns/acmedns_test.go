@@ -0,0 +1,316 @@
+package acmedns
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+const (
+	txt1 = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	txt2 = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	txt3 = "ccccccccccccccccccccccccccccccccccccccccccc"
+)
+
+func init() {
+	bcryptCost = bcrypt.MinCost
+}
+
+type testEnv struct {
+	njalla    *njallatest.Server
+	server    *Server
+	storePath string
+	dir       string
+	audit     *bytes.Buffer
+}
+
+func (e *testEnv) Close() {
+	e.njalla.Close()
+	os.RemoveAll(e.dir)
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain("example.com")
+
+	dir, err := ioutil.TempDir("", "acmedns")
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+	env := &testEnv{
+		njalla: njalla, dir: dir, storePath: filepath.Join(dir, "store.json"),
+	}
+
+	client, err := njalla.Login()
+	if err != nil {
+		env.Close()
+		t.Fatalf("%s", err)
+	}
+
+	store, err := OpenStore(env.storePath)
+	if err != nil {
+		env.Close()
+		t.Fatalf("%s", err)
+	}
+
+	env.server, err = NewServer(client, store, "auth.example.com")
+	if err != nil {
+		env.Close()
+		t.Fatalf("%s", err)
+	}
+	env.audit = &bytes.Buffer{}
+	env.server.Auditor = policy.NewJSONAuditor(env.audit)
+
+	return env
+}
+
+// do sends a request to the server, from 192.0.2.1, decoding the JSON answer
+// into v if given
+func (e *testEnv) do(
+	t *testing.T, path string, headers map[string]string, body interface{},
+	v interface{},
+) int {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+	req.RemoteAddr = "192.0.2.1:1234"
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	e.server.ServeHTTP(recorder, req)
+
+	if v != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+			t.Fatalf("Couldn't decode %q: %s", recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+func (e *testEnv) register(t *testing.T, allowFrom ...string) registerResponse {
+	var resp registerResponse
+	code := e.do(t, "/register", nil, registerRequest{AllowFrom: allowFrom}, &resp)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201 registering, got %d", code)
+	}
+	return resp
+}
+
+func (e *testEnv) update(t *testing.T, reg registerResponse, txt string) int {
+	return e.do(
+		t, "/update",
+		map[string]string{"X-Api-User": reg.Username, "X-Api-Key": reg.Password},
+		updateRequest{Subdomain: reg.Subdomain, TXT: txt}, nil,
+	)
+}
+
+func (e *testEnv) contents(reg registerResponse) []string {
+	name := "_acme-challenge." + reg.Subdomain + ".auth"
+	contents := make([]string, 0)
+	for _, record := range e.njalla.Records("example.com").Filter(
+		records.ByType("TXT"), records.ByName(name),
+	) {
+		contents = append(contents, record.GetContent())
+	}
+	return contents
+}
+
+func TestRegister(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	reg := env.register(t, "192.0.2.0/24", "2001:db8::1")
+
+	if !uuidRegexp.MatchString(reg.Username) ||
+		!uuidRegexp.MatchString(reg.Subdomain) ||
+		len(reg.Password) != passwordLength {
+		t.Errorf("Unexpected credentials: %+v", reg)
+	}
+
+	expected := "_acme-challenge." + reg.Subdomain + ".auth.example.com"
+	if reg.FullDomain != expected {
+		t.Errorf("Expected full domain %s, got %s", expected, reg.FullDomain)
+	}
+
+	expectedAllow := []string{"192.0.2.0/24", "2001:db8::1/128"}
+	if !cmp.Equal(expectedAllow, reg.AllowFrom) {
+		t.Errorf(
+			"Allowed CIDRs don't match:\n%s",
+			cmp.Diff(expectedAllow, reg.AllowFrom),
+		)
+	}
+
+	// The password isn't stored, only its hash
+	data, err := ioutil.ReadFile(env.storePath)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if strings.Contains(string(data), reg.Password) {
+		t.Errorf("Password was stored in clear text")
+	}
+
+	store, err := OpenStore(env.storePath)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if stored, exists := store.Get(reg.Username); !exists ||
+		stored.Subdomain != reg.Subdomain {
+		t.Errorf("Registration wasn't persisted: %+v", stored)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	var resp map[string]string
+	code := env.do(t, "/register", nil, registerRequest{
+		AllowFrom: []string{"not a cidr"},
+	}, &resp)
+	if code != http.StatusBadRequest || resp["error"] != "invalid_allowfrom_cidr" {
+		t.Errorf("Expected invalid_allowfrom_cidr, got %d %v", code, resp)
+	}
+
+	env.server.DisableRegistration = true
+	if code := env.do(t, "/register", nil, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 with registration disabled, got %d", code)
+	}
+}
+
+func TestUpdateKeepsTwoLatestValues(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	reg := env.register(t)
+
+	steps := []struct {
+		txt      string
+		expected []string
+	}{
+		{txt1, []string{txt1}},
+		{txt2, []string{txt1, txt2}},
+		{txt3, []string{txt3, txt2}},
+		// Retrying the latest value changes nothing
+		{txt3, []string{txt3, txt2}},
+		{txt1, []string{txt3, txt1}},
+	}
+
+	for i, step := range steps {
+		if code := env.update(t, reg, step.txt); code != http.StatusOK {
+			t.Fatalf("Step %d: expected 200, got %d", i, code)
+		}
+
+		got := env.contents(reg)
+		if !cmp.Equal(step.expected, got) {
+			t.Errorf(
+				"Step %d: TXT records don't match:\n%s", i,
+				cmp.Diff(step.expected, got),
+			)
+		}
+	}
+}
+
+func TestUpdateForbidden(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	reg := env.register(t)
+	other := env.register(t)
+	restricted := env.register(t, "198.51.100.0/24")
+
+	wrongKey := reg
+	wrongKey.Password = strings.Repeat("x", passwordLength)
+
+	otherSubdomain := reg
+	otherSubdomain.Subdomain = other.Subdomain
+
+	unknown := reg
+	unknown.Username = other.Subdomain
+
+	for name, r := range map[string]registerResponse{
+		"wrong key":           wrongKey,
+		"another subdomain":   otherSubdomain,
+		"disallowed address":  restricted,
+		"unknown username":    unknown,
+		"missing credentials": {Subdomain: reg.Subdomain},
+	} {
+		if code := env.update(t, r, txt1); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, code)
+		}
+	}
+
+	if posts := env.njalla.Posts(); posts != 0 {
+		t.Errorf("Forbidden updates changed records %d times", posts)
+	}
+
+	// Only the attempt on another subdomain got past the authentication
+	var denial policy.Denial
+	if err := json.Unmarshal(env.audit.Bytes(), &denial); err != nil {
+		t.Fatalf("Expected one audit entry, got %q: %s", env.audit, err)
+	}
+	expected := "_acme-challenge." + other.Subdomain + ".auth"
+	if denial.Principal != reg.Username || denial.Frontend != "acmedns" ||
+		denial.Name != expected || denial.Source != "192.0.2.1" {
+		t.Errorf("Unexpected audit entry: %+v", denial)
+	}
+
+	// The forwarded header is only trusted when enabled
+	env.server.ForwardedHeader = "X-Forwarded-For"
+	code := env.do(
+		t, "/update",
+		map[string]string{
+			"X-Api-User": restricted.Username, "X-Api-Key": restricted.Password,
+			"X-Forwarded-For": "198.51.100.7, 192.0.2.1",
+		},
+		updateRequest{Subdomain: restricted.Subdomain, TXT: txt1}, nil,
+	)
+	if code != http.StatusOK {
+		t.Errorf("Expected 200 from an allowed forwarded address, got %d", code)
+	}
+}
+
+func TestUpdateBadRequests(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	reg := env.register(t)
+
+	tests := []struct {
+		subdomain, txt, expected string
+	}{
+		{"not-a-subdomain", txt1, "bad_subdomain"},
+		{reg.Subdomain, "short", "bad_txt"},
+		{reg.Subdomain, strings.Repeat("!", 43), "bad_txt"},
+	}
+
+	for _, test := range tests {
+		var resp map[string]string
+		code := env.do(
+			t, "/update",
+			map[string]string{"X-Api-User": reg.Username, "X-Api-Key": reg.Password},
+			updateRequest{Subdomain: test.subdomain, TXT: test.txt}, &resp,
+		)
+		if code != http.StatusBadRequest || resp["error"] != test.expected {
+			t.Errorf("Expected %s, got %d %v", test.expected, code, resp)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package acmedns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registration is an acme-dns account, allowed to update the TXT records of
+// its subdomain
+type Registration struct {
+	Username string `json:"username"`
+	// PasswordHash is the bcrypt hash of the password, which isn't stored
+	PasswordHash string `json:"password_hash"`
+	Subdomain    string `json:"subdomain"`
+	// AllowFrom are the CIDRs updates may come from. Empty means anywhere
+	AllowFrom []string `json:"allowfrom"`
+	// LastTXT is the value of the latest update, so the next one replaces
+	// the other, older, record
+	LastTXT string `json:"last_txt,omitempty"`
+}
+
+// Allows returns true if the registration may be used from the IP
+func (r Registration) Allows(ip net.IP) bool {
+	if len(r.AllowFrom) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range r.AllowFrom {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAllowFrom validates CIDRs, turning bare IPs into single address
+// networks, as acme-dns does
+func ParseAllowFrom(allowFrom []string) ([]string, error) {
+	cidrs := make([]string, 0, len(allowFrom))
+	for _, value := range allowFrom {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid CIDR %s", value)
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR %s", value)
+		}
+		cidrs = append(cidrs, network.String())
+	}
+	return cidrs, nil
+}
+
+// Store keeps the registrations in a JSON file, rewritten on every change
+type Store struct {
+	path string
+
+	mu            sync.Mutex
+	registrations map[string]Registration
+}
+
+// storeFile is the format of the Store's file
+type storeFile struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+// OpenStore loads the registrations in the file. A missing file isn't an
+// error, and gives an empty Store, which creates the file when changed
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, registrations: make(map[string]Registration)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Couldn't parse %s: %s", path, err)
+	}
+
+	for _, r := range file.Registrations {
+		s.registrations[r.Username] = r
+	}
+	return s, nil
+}
+
+// Get returns the registration with the username
+func (s *Store) Get(username string) (Registration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, exists := s.registrations[username]
+	return r, exists
+}
+
+// Add stores a new registration
+func (s *Store) Add(r Registration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.registrations[r.Username]; exists {
+		return fmt.Errorf("Registration %s already exists", r.Username)
+	}
+
+	s.registrations[r.Username] = r
+	if err := s.save(); err != nil {
+		delete(s.registrations, r.Username)
+		return err
+	}
+	return nil
+}
+
+// SetLastTXT records the value of the latest update of a registration
+func (s *Store) SetLastTXT(username, txt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, exists := s.registrations[username]
+	if !exists {
+		return fmt.Errorf("Registration %s doesn't exist", username)
+	}
+
+	r.LastTXT = txt
+	s.registrations[username] = r
+	return s.save()
+}
+
+// save writes the registrations, readable only by the user, replacing the
+// file at once so a failed write doesn't lose them
+func (s *Store) save() error {
+	file := storeFile{Registrations: make([]Registration, 0)}
+	for _, r := range s.registrations {
+		file.Registrations = append(file.Registrations, r)
+	}
+	sort.Slice(file.Registrations, func(i, j int) bool {
+		return file.Registrations[i].Username < file.Registrations[j].Username
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".acmedns")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,515 @@
+// Package api serves a JSON REST API for the domains and records of a Njalla
+// account, so other services can manage them without the account password.
+// Callers authenticate with bearer tokens, and the API is described by the
+// OpenAPI document returned by OpenAPIJSON. What each token may do is
+// limited by its rules, see the policy package
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// maxBodySize is the largest request body accepted
+const maxBodySize = 1 << 16
+
+// Client is the part of provider.Provider the API exposes
+type Client interface {
+	GetDomains() ([]string, error)
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	UpdateRecordTyped(domain string, record records.Record) error
+	RemoveRecords(domain string, recordIDs []int) error
+}
+
+// Token is a bearer token allowed to use the API
+type Token struct {
+	// Name identifies the token in the logs
+	Name string `yaml:"name"`
+	// Token is the secret sent in the Authorization header
+	Token string `yaml:"token"`
+	// Rules are what the token may do, see the policy package. A token
+	// needs at least one, and an empty rule allows everything
+	Rules []policy.Rule `yaml:"rules"`
+}
+
+// caller is the token of a request, along with the client enforcing its
+// policy
+type caller struct {
+	token  Token
+	client *policy.PolicyProvider
+}
+
+// Server is an http.Handler serving the API
+type Server struct {
+	client   Client
+	tokens   []Token
+	policies map[string]*policy.Policy
+
+	// Logger, if set, gets a line for every change and every rejected token
+	Logger *log.Logger
+	// Auditor, if set, gets every attempt denied by the policy of a token
+	Auditor policy.Auditor
+
+	// mu serialises changes, since Njalla changes records by rewriting all
+	// the records of a domain, and concurrent changes could undo each other
+	mu sync.Mutex
+}
+
+// NewServer returns a Server exposing the client to the holders of the
+// tokens
+func NewServer(client Client, tokens []Token) (*Server, error) {
+	policies := make(map[string]*policy.Policy)
+	for i, token := range tokens {
+		if token.Name == "" {
+			return nil, fmt.Errorf("Token %d has no name", i)
+		}
+		if len(token.Token) < 16 {
+			return nil, fmt.Errorf(
+				"Token %s is too short, use at least 16 characters", token.Name,
+			)
+		}
+		if _, exists := policies[token.Name]; exists {
+			return nil, fmt.Errorf("Token %s is defined twice", token.Name)
+		}
+		if len(token.Rules) == 0 {
+			return nil, fmt.Errorf(
+				"Token %s has no rules, give it `rules: [{}]` to allow "+
+					"everything", token.Name,
+			)
+		}
+
+		p, err := policy.New(token.Name, token.Rules)
+		if err != nil {
+			return nil, err
+		}
+		policies[token.Name] = p
+	}
+
+	return &Server{client: client, tokens: tokens, policies: policies}, nil
+}
+
+// apiError is the body of every error response. Fields is only set for
+// validation errors
+type apiError struct {
+	Error  string               `json:"error"`
+	Fields []records.FieldError `json:"fields,omitempty"`
+}
+
+// ServeHTTP routes the requests:
+//
+//	GET    /openapi.json
+//	GET    /domains
+//	GET    /domains/{domain}/records
+//	POST   /domains/{domain}/records
+//	GET    /domains/{domain}/records/{id}
+//	PATCH  /domains/{domain}/records/{id}
+//	DELETE /domains/{domain}/records/{id}
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/openapi.json" {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(OpenAPIJSON())
+		return
+	}
+
+	token, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="njalla"`)
+		writeError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	c := caller{
+		token: token,
+		client: policy.NewPolicyProvider(
+			s.client, s.policies[token.Name], s.Auditor,
+		),
+	}
+	c.client.Frontend = "api"
+	c.client.Source = r.RemoteAddr
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "domains":
+		if allowMethods(w, r, http.MethodGet) {
+			s.listDomains(w, c)
+		}
+	case len(parts) == 3 && parts[0] == "domains" && parts[2] == "records":
+		if allowMethods(w, r, http.MethodGet, http.MethodPost) {
+			s.handleRecords(w, r, c, parts[1])
+		}
+	case len(parts) == 4 && parts[0] == "domains" && parts[2] == "records":
+		id, err := strconv.Atoi(parts[3])
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Record IDs are integers")
+			return
+		}
+		if allowMethods(
+			w, r, http.MethodGet, http.MethodPatch, http.MethodDelete,
+		) {
+			s.handleRecord(w, r, c, parts[1], id)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// authenticate returns the token in the Authorization header, if valid
+func (s *Server) authenticate(r *http.Request) (Token, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return Token{}, false
+	}
+	secret := []byte(strings.TrimPrefix(header, "Bearer "))
+
+	for _, token := range s.tokens {
+		if subtle.ConstantTimeCompare(secret, []byte(token.Token)) == 1 {
+			return token, true
+		}
+	}
+
+	s.logf("%s: rejected invalid token", r.RemoteAddr)
+	return Token{}, false
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, c caller) {
+	domains, err := c.client.GetDomains()
+	if err != nil {
+		s.upstreamError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domains)
+}
+
+func (s *Server) handleRecords(
+	w http.ResponseWriter, r *http.Request, c caller, domain string,
+) {
+	if !s.domainAllowed(w, r, c, domain) || !s.domainExists(w, domain) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		stored, err := c.client.GetRecords(domain)
+		if err != nil {
+			s.upstreamError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, recordsOrEmpty(stored))
+		return
+	}
+
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	record, err := records.UnmarshalRecord(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.validRecord(w, domain, record) {
+		return
+	}
+
+	created, err := s.addRecord(c, domain, record)
+	if err != nil {
+		s.upstreamError(w, err)
+		return
+	}
+
+	s.logf(
+		"%s: token %s added %s %s to %s", r.RemoteAddr, c.token.Name,
+		record.GetType(), record.GetName(), domain,
+	)
+	w.Header().Set(
+		"Location",
+		fmt.Sprintf("/domains/%s/records/%d", domain, created.GetID()),
+	)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) handleRecord(
+	w http.ResponseWriter, r *http.Request, c caller, domain string, id int,
+) {
+	if !s.domainAllowed(w, r, c, domain) || !s.domainExists(w, domain) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	stored, err := s.client.GetRecords(domain)
+	if err != nil {
+		s.upstreamError(w, err)
+		return
+	}
+	record, exists := stored.FindByID(id)
+	if !exists {
+		writeError(
+			w, http.StatusNotFound,
+			fmt.Sprintf("Record %d doesn't exist in %s", id, domain),
+		)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := c.client.Check(policy.Read, domain, record); err != nil {
+			s.upstreamError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, record)
+
+	case http.MethodPatch:
+		data, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updated, err := patchRecord(record, data)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !s.validRecord(w, domain, updated) {
+			return
+		}
+
+		if err := c.client.UpdateRecordTyped(domain, updated); err != nil {
+			s.upstreamError(w, err)
+			return
+		}
+
+		s.logf(
+			"%s: token %s updated record %d of %s", r.RemoteAddr, c.token.Name,
+			id, domain,
+		)
+		writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		if err := c.client.RemoveRecord(domain, id); err != nil {
+			s.upstreamError(w, err)
+			return
+		}
+
+		s.logf(
+			"%s: token %s removed record %d of %s", r.RemoteAddr, c.token.Name,
+			id, domain,
+		)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// addRecord adds the record and returns it as stored, with its ID. Njalla
+// doesn't return the new record, so it's found among the records that
+// weren't there before
+func (s *Server) addRecord(
+	c caller, domain string, record records.Record,
+) (records.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, err := s.client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.client.AddRecord(domain, record); err != nil {
+		return nil, err
+	}
+
+	after, err := s.client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	key := records.MatchKey(domain, record)
+	for _, stored := range after {
+		if _, existed := before.FindByID(stored.GetID()); existed {
+			continue
+		}
+		if records.MatchKey(domain, stored) == key {
+			return stored, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Couldn't find the added record")
+}
+
+// domainAllowed answers 403 if the policy of the token doesn't allow the
+// operation of the request on any record of the domain
+func (s *Server) domainAllowed(
+	w http.ResponseWriter, r *http.Request, c caller, domain string,
+) bool {
+	op := map[string]policy.Operation{
+		http.MethodGet:    policy.Read,
+		http.MethodPost:   policy.Add,
+		http.MethodPatch:  policy.Update,
+		http.MethodDelete: policy.Delete,
+	}[r.Method]
+
+	if c.client.Policy().AllowsDomain(op, domain) {
+		return true
+	}
+
+	s.upstreamError(w, c.client.Deny(policy.Denial{Operation: op, Domain: domain}))
+	return false
+}
+
+// domainExists answers 404 for domains that aren't in the account
+func (s *Server) domainExists(w http.ResponseWriter, domain string) bool {
+	domains, err := s.client.GetDomains()
+	if err != nil {
+		s.upstreamError(w, err)
+		return false
+	}
+
+	for _, candidate := range domains {
+		if candidate == domain {
+			return true
+		}
+	}
+
+	writeError(
+		w, http.StatusNotFound, fmt.Sprintf("Domain %s doesn't exist", domain),
+	)
+	return false
+}
+
+// upstreamError answers 403 for attempts denied by the policy of the token.
+// Any other error comes from Njalla, so it is logged and answered with 502
+func (s *Server) upstreamError(w http.ResponseWriter, err error) {
+	if policy.IsDenied(err) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	s.logf("Njalla error: %s", err)
+	writeError(w, http.StatusBadGateway, fmt.Sprintf("Njalla error: %s", err))
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// patchRecord returns a copy of the record with the fields in the JSON
+// object replaced. The ID and the type can't be changed
+func patchRecord(record records.Record, patch []byte) (records.Record, error) {
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+
+	current, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(current, &fields); err != nil {
+		return nil, err
+	}
+
+	for field, value := range changes {
+		if _, exists := fields[field]; !exists {
+			return nil, fmt.Errorf(
+				"%s records don't have field %s", record.GetType(), field,
+			)
+		}
+		if (field == "id" || field == "type") &&
+			string(value) != string(fields[field]) {
+			return nil, fmt.Errorf("Field %s can't be changed", field)
+		}
+		fields[field] = value
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return records.UnmarshalRecord(merged)
+}
+
+// validRecord answers 400 with the problems of a record that isn't valid
+// for the capabilities of the domain. When those can't be got, the defaults
+// are used
+func (s *Server) validRecord(
+	w http.ResponseWriter, domain string, record records.Record,
+) bool {
+	caps, err := records.CapabilitiesOf(s.client, domain)
+	if err != nil {
+		s.logf("Couldn't get the capabilities of %s: %s", domain, err)
+	}
+
+	err = record.ValidateWith(caps)
+	if err == nil {
+		return true
+	}
+
+	body := apiError{Error: err.Error()}
+	if validationErr, ok := err.(*records.ValidationError); ok {
+		body.Fields = validationErr.Errors
+	}
+	writeJSON(w, http.StatusBadRequest, body)
+	return false
+}
+
+// allowMethods answers 405 to requests with any other method
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(
+		w, http.StatusMethodNotAllowed,
+		fmt.Sprintf("Method %s not allowed", r.Method),
+	)
+	return false
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read the body: %s", err)
+	}
+	return data, nil
+}
+
+// recordsOrEmpty makes sure no records are encoded as [] rather than null
+func recordsOrEmpty(r records.Records) records.Records {
+	if r == nil {
+		return records.Records{}
+	}
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
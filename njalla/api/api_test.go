@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+const (
+	testToken   = "0123456789abcdef0123"
+	scopedToken = "scoped-token-scoped-token"
+)
+
+var allowAll = []policy.Rule{{}}
+
+type testEnv struct {
+	njalla *njallatest.Server
+	server *Server
+	audit  *bytes.Buffer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain(
+		"example.com",
+		&records.RecordA{Type: "A", Name: "www", Content: "1.1.1.1", TTL: 300},
+	)
+
+	client, err := njalla.Login()
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	server, err := NewServer(client, []Token{
+		{Name: "test", Token: testToken, Rules: allowAll},
+		{Name: "certbot", Token: scopedToken, Rules: []policy.Rule{{
+			Domains: []string{"example.com"},
+			Names:   []string{"_acme-challenge.*"},
+			Types:   []string{"TXT"},
+		}}},
+	})
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	audit := &bytes.Buffer{}
+	server.Auditor = policy.NewJSONAuditor(audit)
+	return &testEnv{njalla: njalla, server: server, audit: audit}
+}
+
+// do sends a request with the token, returning the response
+func (e *testEnv) do(
+	t *testing.T, method, path, token string, body string,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	e.server.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decodeRecord(t *testing.T, resp *httptest.ResponseRecorder) records.Record {
+	record, err := records.UnmarshalRecord(resp.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Couldn't decode %q: %s", resp.Body.String(), err)
+	}
+	return record
+}
+
+func TestAuthentication(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.njalla.Close()
+
+	for _, token := range []string{"", "wrong-token-wrong-token"} {
+		resp := env.do(t, "GET", "/domains", token, "")
+		if resp.Code != http.StatusUnauthorized {
+			t.Errorf("Token %q: expected 401, got %d", token, resp.Code)
+		}
+		if resp.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Token %q: missing WWW-Authenticate header", token)
+		}
+	}
+
+	if resp := env.do(t, "GET", "/openapi.json", "", ""); resp.Code != http.StatusOK {
+		t.Errorf("OpenAPI document should be public, got %d", resp.Code)
+	}
+
+	resp := env.do(t, "GET", "/domains", testToken, "")
+	if resp.Code != http.StatusOK ||
+		strings.TrimSpace(resp.Body.String()) != `["example.com"]` {
+		t.Errorf("Unexpected domains: %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestNewServerRejectsBadTokens(t *testing.T) {
+	for _, tokens := range [][]Token{
+		{{Name: "", Token: testToken, Rules: allowAll}},
+		{{Name: "short", Token: "short", Rules: allowAll}},
+		{
+			{Name: "twice", Token: testToken, Rules: allowAll},
+			{Name: "twice", Token: testToken + "x", Rules: allowAll},
+		},
+		{{Name: "norules", Token: testToken}},
+		{{
+			Name: "invalid", Token: testToken,
+			Rules: []policy.Rule{{Operations: []policy.Operation{"wipe"}}},
+		}},
+	} {
+		if _, err := NewServer(nil, tokens); err == nil {
+			t.Errorf("Expected an error for %+v", tokens)
+		}
+	}
+}
+
+func TestRecordLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.njalla.Close()
+
+	resp := env.do(
+		t, "POST", "/domains/example.com/records", testToken,
+		`{"type": "TXT", "name": "_acme-challenge", "content": "token", "ttl": 60}`,
+	)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %s", resp.Code, resp.Body)
+	}
+	created := decodeRecord(t, resp)
+	if created.GetID() == 0 || created.GetContent() != "token" {
+		t.Fatalf("Unexpected created record: %+v", created)
+	}
+
+	location := resp.Header().Get("Location")
+	resp = env.do(t, "GET", location, testToken, "")
+	if resp.Code != http.StatusOK || decodeRecord(t, resp).GetID() != created.GetID() {
+		t.Fatalf("Couldn't get %s: %d %s", location, resp.Code, resp.Body)
+	}
+
+	resp = env.do(t, "PATCH", location, testToken, `{"content": "changed"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 updating, got %d %s", resp.Code, resp.Body)
+	}
+	stored, _ := env.njalla.Records("example.com").FindByID(created.GetID())
+	if stored == nil || stored.GetContent() != "changed" || stored.GetTTL() != 60 {
+		t.Errorf("Record wasn't updated: %+v", stored)
+	}
+
+	for _, patch := range []string{
+		`{"type": "A"}`, `{"id": 1}`, `{"prio": 10}`, `{"ttl": 61}`, `[]`,
+	} {
+		resp = env.do(t, "PATCH", location, testToken, patch)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Patch %s: expected 400, got %d", patch, resp.Code)
+		}
+	}
+
+	resp = env.do(t, "DELETE", location, testToken, "")
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 removing, got %d %s", resp.Code, resp.Body)
+	}
+	resp = env.do(t, "GET", location, testToken, "")
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a removed record, got %d", resp.Code)
+	}
+
+	remaining := env.njalla.Records("example.com")
+	if len(remaining) != 1 || remaining[0].GetName() != "www" {
+		t.Errorf("Other records were changed: %s", remaining)
+	}
+}
+
+func TestAddInvalidRecord(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.njalla.Close()
+
+	resp := env.do(
+		t, "POST", "/domains/example.com/records", testToken,
+		`{"type": "A", "name": "www", "content": "not an IP", "ttl": 61}`,
+	)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", resp.Code)
+	}
+
+	var body apiError
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(body.Fields) != 2 {
+		t.Errorf("Expected errors for content and ttl, got %+v", body)
+	}
+
+	if env.njalla.Posts() != 0 {
+		t.Errorf("Invalid record was sent to Njalla")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.njalla.Close()
+
+	for _, path := range []string{
+		"/domains/example.net/records",
+		"/domains/example.com/records/999",
+		"/domains/example.com/records/abc",
+		"/unknown",
+	} {
+		resp := env.do(t, "GET", path, testToken, "")
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, resp.Code)
+		}
+	}
+
+	resp := env.do(t, "PUT", "/domains/example.com/records", testToken, "")
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", resp.Code)
+	}
+}
+
+func TestScopedToken(t *testing.T) {
+	env := newTestEnv(t)
+	env.njalla.AddDomain("example.net")
+	defer env.njalla.Close()
+
+	resp := env.do(t, "GET", "/domains", scopedToken, "")
+	if strings.TrimSpace(resp.Body.String()) != `["example.com"]` {
+		t.Errorf("Unexpected domains: %d %s", resp.Code, resp.Body)
+	}
+
+	resp = env.do(t, "GET", "/domains/example.com/records", scopedToken, "")
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != "[]" {
+		t.Errorf("www shouldn't be readable: %d %s", resp.Code, resp.Body)
+	}
+
+	resp = env.do(
+		t, "POST", "/domains/example.com/records", scopedToken,
+		`{"type": "TXT", "name": "_acme-challenge", "content": "token", "ttl": 60}`,
+	)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %s", resp.Code, resp.Body)
+	}
+	location := resp.Header().Get("Location")
+
+	resp = env.do(t, "DELETE", location, scopedToken, "")
+	if resp.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 removing, got %d %s", resp.Code, resp.Body)
+	}
+
+	www := env.njalla.Records("example.com")[0]
+	denied := []struct{ method, path, body string }{
+		{"GET", "/domains/example.net/records", ""},
+		{
+			"POST", "/domains/example.com/records",
+			`{"type": "A", "name": "_acme-challenge", "content": "1.1.1.1", "ttl": 60}`,
+		},
+		{"GET", fmt.Sprintf("/domains/example.com/records/%d", www.GetID()), ""},
+		{
+			"PATCH", fmt.Sprintf("/domains/example.com/records/%d", www.GetID()),
+			`{"content": "2.2.2.2"}`,
+		},
+		{"DELETE", fmt.Sprintf("/domains/example.com/records/%d", www.GetID()), ""},
+	}
+	for _, req := range denied {
+		resp = env.do(t, req.method, req.path, scopedToken, req.body)
+		if resp.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403, got %d", req.method, req.path, resp.Code)
+		}
+	}
+
+	if len(env.njalla.Records("example.com")) != 1 {
+		t.Errorf("Denied requests changed records")
+	}
+
+	lines := strings.Split(strings.TrimSpace(env.audit.String()), "\n")
+	if len(lines) != len(denied) {
+		t.Fatalf("Expected %d audit entries, got:\n%s", len(denied), env.audit)
+	}
+	var entry policy.Denial
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if entry.Principal != "certbot" || entry.Frontend != "api" ||
+		entry.Domain != "example.net" || entry.Operation != policy.Read {
+		t.Errorf("Unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestOpenAPIUpToDate(t *testing.T) {
+	data, err := ioutil.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !bytes.Equal(data, OpenAPIJSON()) {
+		t.Errorf("openapi.json is outdated, run go generate")
+	}
+}
+
+func TestOpenAPIDescribesEveryType(t *testing.T) {
+	var document struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(OpenAPIJSON(), &document); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, recordType := range records.Types {
+		schema, exists := document.Components.Schemas["Record"+recordType]
+		if !exists {
+			t.Errorf("No schema for %s records", recordType)
+			continue
+		}
+
+		empty, _ := records.NewEmpty(recordType)
+		data, _ := json.Marshal(empty)
+		var fields map[string]interface{}
+		json.Unmarshal(data, &fields)
+
+		if len(fields) != len(schema.Properties) {
+			t.Errorf(
+				"%s schema has %d properties, expected %d", recordType,
+				len(schema.Properties), len(fields),
+			)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build ignore
+// +build ignore
+
+// gen writes openapi.json, the OpenAPI document of the API, so it can be
+// read without running the server. Run it with go generate
+package main
+
+import (
+	"io/ioutil"
+	"log"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/api"
+)
+
+func main() {
+	if err := ioutil.WriteFile("openapi.json", api.OpenAPIJSON(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
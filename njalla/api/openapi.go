@@ -0,0 +1,270 @@
+package api
+
+//go:generate go run gen.go
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// object is a JSON object of the OpenAPI document. Maps are encoded with
+// sorted keys, so the document is the same on every run
+type object = map[string]interface{}
+
+// fieldDescriptions describe the record fields, by JSON name
+var fieldDescriptions = map[string]string{
+	"id":            "Njalla's ID of the record",
+	"type":          "Type of the record",
+	"name":          "Name relative to the domain, @ for the domain itself",
+	"content":       "Content of the record",
+	"ttl":           "Time to live, in seconds",
+	"prio":          "Priority",
+	"weight":        "Weight among records with the same priority",
+	"port":          "Port of the service",
+	"ssh_algorithm": "Algorithm of the key: 1 RSA, 2 DSA, 3 ECDSA, 4 Ed25519",
+	"ssh_type":      "Type of the fingerprint: 1 SHA-1, 2 SHA-256",
+}
+
+// redirectDescriptions override fieldDescriptions for Redirect records,
+// which reuse the content and prio fields
+var redirectDescriptions = map[string]string{
+	"content": "URL to redirect to",
+	"prio":    "HTTP status code of the redirect",
+}
+
+// OpenAPIJSON returns the OpenAPI 3 document describing the API, with a
+// schema for each record type generated from the records package
+func OpenAPIJSON() []byte {
+	data, err := json.MarshalIndent(openAPI(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append(data, '\n')
+}
+
+func openAPI() object {
+	schemas := object{
+		"Error": object{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": object{
+				"error": object{"type": "string"},
+				"fields": object{
+					"type":        "array",
+					"description": "Problems found validating a record",
+					"items": object{
+						"type":     "object",
+						"required": []string{"field", "message"},
+						"properties": object{
+							"field":   object{"type": "string"},
+							"message": object{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	oneOf := make([]object, 0, len(records.Types))
+	mapping := object{}
+	for _, recordType := range records.Types {
+		name := "Record" + recordType
+		schemas[name] = recordSchema(recordType)
+		ref := "#/components/schemas/" + name
+		oneOf = append(oneOf, object{"$ref": ref})
+		mapping[recordType] = ref
+	}
+	schemas["Record"] = object{
+		"oneOf": oneOf,
+		"discriminator": object{
+			"propertyName": "type",
+			"mapping":      mapping,
+		},
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "Njalla DNS API",
+			"version": "1.0.0",
+			"description": "Manages the domains and records of a Njalla " +
+				"account. Every path but this document needs a bearer token.",
+		},
+		"security": []object{{"bearerAuth": []string{}}},
+		"components": object{
+			"securitySchemes": object{
+				"bearerAuth": object{"type": "http", "scheme": "bearer"},
+			},
+			"schemas": schemas,
+		},
+		"paths": object{
+			"/domains": object{
+				"get": operation(
+					"listDomains", "List the domains of the account", nil,
+					"200", object{
+						"type":  "array",
+						"items": object{"type": "string"},
+					},
+				),
+			},
+			"/domains/{domain}/records": object{
+				"parameters": []object{domainParameter()},
+				"get": operation(
+					"listRecords", "List the records of a domain", nil,
+					"200", object{
+						"type":  "array",
+						"items": schemaRef("Record"),
+					},
+				),
+				"post": operation(
+					"addRecord", "Add a record, the id is ignored",
+					schemaRef("Record"), "201", schemaRef("Record"),
+				),
+			},
+			"/domains/{domain}/records/{id}": object{
+				"parameters": []object{domainParameter(), idParameter()},
+				"get": operation(
+					"getRecord", "Get a record", nil, "200", schemaRef("Record"),
+				),
+				"patch": operation(
+					"updateRecord",
+					"Change some fields of a record, all but its id and type",
+					object{"type": "object"}, "200", schemaRef("Record"),
+				),
+				"delete": operation(
+					"removeRecord", "Remove a record", nil, "204", nil,
+				),
+			},
+		},
+	}
+}
+
+// recordSchema returns the schema of a record type, from the JSON fields of
+// its struct
+func recordSchema(recordType string) object {
+	empty, err := records.NewEmpty(recordType)
+	if err != nil {
+		panic(err)
+	}
+	caps := structures.DefaultCapabilities()
+
+	properties := object{}
+	required := make([]string, 0)
+
+	structType := reflect.TypeOf(empty).Elem()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := object{"description": fieldDescriptions[name]}
+		if recordType == "Redirect" && redirectDescriptions[name] != "" {
+			property["description"] = redirectDescriptions[name]
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Int:
+			property["type"] = "integer"
+		case reflect.Uint:
+			property["type"] = "integer"
+			property["minimum"] = 0
+		default:
+			property["type"] = "string"
+		}
+
+		switch {
+		case name == "id":
+			property["readOnly"] = true
+		case name == "type":
+			property["enum"] = []string{recordType}
+		case name == "ttl":
+			property["enum"] = caps.TTLs
+		case name == "prio" && recordType == "Redirect":
+			property["enum"] = caps.RedirectTypes
+		case name == "prio":
+			property["enum"] = caps.Priorities
+		case name == "ssh_algorithm":
+			property["enum"] = caps.SSHAlgorithms
+		case name == "ssh_type":
+			property["enum"] = caps.SSHTypes
+		}
+
+		properties[name] = property
+		if name != "id" {
+			required = append(required, name)
+		}
+	}
+
+	return object{
+		"type":       "object",
+		"title":      recordType + " record",
+		"required":   required,
+		"properties": properties,
+	}
+}
+
+// operation describes an endpoint. A nil request or response schema means
+// there's no body
+func operation(
+	id, summary string, request object, status string, response object,
+) object {
+	success := object{"description": "Success"}
+	if response != nil {
+		success["content"] = jsonContent(response)
+	}
+
+	errorResponse := func(description string) object {
+		return object{
+			"description": description,
+			"content":     jsonContent(schemaRef("Error")),
+		}
+	}
+
+	op := object{
+		"operationId": id,
+		"summary":     summary,
+		"responses": object{
+			status: success,
+			"400":  errorResponse("Invalid request or record"),
+			"401":  errorResponse("Missing or invalid token"),
+			"403":  errorResponse("Not allowed by the rules of the token"),
+			"404":  errorResponse("Unknown domain or record"),
+			"502":  errorResponse("Njalla failed"),
+		},
+	}
+	if request != nil {
+		op["requestBody"] = object{
+			"required": true,
+			"content":  jsonContent(request),
+		}
+	}
+	return op
+}
+
+func jsonContent(schema object) object {
+	return object{"application/json": object{"schema": schema}}
+}
+
+func schemaRef(name string) object {
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+func domainParameter() object {
+	return object{
+		"name": "domain", "in": "path", "required": true,
+		"schema": object{"type": "string"},
+	}
+}
+
+func idParameter() object {
+	return object{
+		"name": "id", "in": "path", "required": true,
+		"schema": object{"type": "integer"},
+	}
+}
@@ -0,0 +1,281 @@
+package externaldns
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// defaultTTL is used for endpoints without a TTL, as Njalla's website does
+const defaultTTL = structures.TTL10800
+
+// supportedTypes are the external-dns record types Njalla supports
+var supportedTypes = []string{
+	"A", "AAAA", "CNAME", "MX", "TXT", "SRV", "CAA", "PTR", "NS",
+}
+
+func supportedType(recordType string) bool {
+	for _, supported := range supportedTypes {
+		if recordType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// ttl returns the TTL Njalla accepts closest to the TTL of an endpoint
+func ttl(endpointTTL int64) int {
+	if endpointTTL <= 0 {
+		return defaultTTL
+	}
+	return records.ClosestTTL(int(endpointTTL))
+}
+
+// adjustTargets rounds the priority of MX and SRV targets to the closest one
+// Njalla accepts, the same as Records does. Other targets, and those that
+// don't start with a priority, are returned as they are
+func adjustTargets(recordType string, targets []string) []string {
+	if recordType != "MX" && recordType != "SRV" {
+		return targets
+	}
+
+	adjusted := make([]string, len(targets))
+	for i, target := range targets {
+		adjusted[i] = target
+
+		fields := strings.Fields(target)
+		if len(fields) == 0 {
+			continue
+		}
+		priority, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		fields[0] = strconv.Itoa(records.ClosestPriority(priority))
+		adjusted[i] = strings.Join(fields, " ")
+	}
+	return adjusted
+}
+
+// Endpoints converts the records of a domain into endpoints, one for each
+// name and type, with a target for each record. Targets are in the zone
+// file form external-dns uses, such as `10 mail.example.com` for MX
+// records, but for TXT records, which are the plain content. Records of the
+// types external-dns doesn't support are left out
+func Endpoints(domain string, stored records.Records) []*Endpoint {
+	sorted := append(records.Records{}, stored...)
+	sorted.SortBy(func(a, b records.Record) bool {
+		return a.GetID() < b.GetID()
+	})
+
+	endpoints := make([]*Endpoint, 0)
+	byKey := make(map[string]*Endpoint)
+	for _, record := range sorted {
+		if !supportedType(record.GetType()) {
+			continue
+		}
+		target, err := targetOf(domain, record)
+		if err != nil {
+			continue
+		}
+
+		name := records.CanonicalName(domain, record.GetName())
+		key := name + "\x00" + record.GetType()
+		if endpoint, exists := byKey[key]; exists {
+			endpoint.Targets = append(endpoint.Targets, target)
+			continue
+		}
+
+		endpoint := &Endpoint{
+			DNSName:    fqdn(domain, name),
+			RecordType: record.GetType(),
+			Targets:    []string{target},
+			RecordTTL:  int64(record.GetTTL()),
+		}
+		byKey[key] = endpoint
+		endpoints = append(endpoints, endpoint)
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].DNSName != endpoints[j].DNSName {
+			return endpoints[i].DNSName < endpoints[j].DNSName
+		}
+		return endpoints[i].RecordType < endpoints[j].RecordType
+	})
+	return endpoints
+}
+
+// Records converts an endpoint into a record of the domain for each of its
+// targets, with the TTL, and the priority of MX and SRV targets, rounded to
+// the closest ones Njalla accepts
+func Records(domain string, endpoint *Endpoint) (records.Records, error) {
+	if !supportedType(endpoint.RecordType) {
+		return nil, fmt.Errorf(
+			"Record type %s isn't supported by Njalla", endpoint.RecordType,
+		)
+	}
+
+	name := dns.Fqdn(strings.ToLower(endpoint.DNSName))
+	converted := make(records.Records, 0, len(endpoint.Targets))
+	for _, target := range endpoint.Targets {
+		var rr dns.RR
+		if endpoint.RecordType == "TXT" {
+			rr = &dns.TXT{
+				Hdr: dns.RR_Header{
+					Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET,
+				},
+				Txt: []string{target},
+			}
+		} else {
+			var err error
+			rr, err = dns.NewRR(fmt.Sprintf(
+				"%s 0 IN %s %s", name, endpoint.RecordType, target,
+			))
+			if err != nil || rr == nil {
+				return nil, fmt.Errorf(
+					"Invalid %s target %q for %s", endpoint.RecordType,
+					target, endpoint.DNSName,
+				)
+			}
+		}
+		rr.Header().Ttl = uint32(ttl(endpoint.RecordTTL))
+
+		record, err := records.FromRR(rr, domain)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, record)
+	}
+
+	return converted, nil
+}
+
+// Owns returns true if the endpoint is owned by the owner ID, according to
+// the TXT registry of external-dns. TXT registry records are owned if they
+// name the owner, and any other endpoint is owned if there's a TXT registry
+// record naming the owner at its name, or at its name with the record type
+// prefix external-dns adds, such as `a-www` for the A records of `www`.
+// Custom registry prefixes and suffixes aren't supported
+func Owns(
+	domain string, current records.Records, endpoint *Endpoint, ownerID string,
+) bool {
+	registry := true
+	for _, target := range endpoint.Targets {
+		owner, isRegistry := registryOwner(target)
+		if !isRegistry || owner != ownerID {
+			registry = false
+		}
+	}
+	if endpoint.RecordType == "TXT" && len(endpoint.Targets) > 0 && registry {
+		return true
+	}
+
+	name := records.CanonicalName(domain, endpoint.DNSName)
+	candidates := []string{
+		name, strings.ToLower(endpoint.RecordType) + "-" + name,
+	}
+	for _, record := range current.Filter(records.ByType("TXT")) {
+		owner, isRegistry := registryOwner(record.GetContent())
+		if !isRegistry || owner != ownerID {
+			continue
+		}
+		recordName := records.CanonicalName(domain, record.GetName())
+		for _, candidate := range candidates {
+			if recordName == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// registryOwner returns the owner in the content of a TXT registry record,
+// like `"heritage=external-dns,external-dns/owner=default"`, and whether
+// the content is one
+func registryOwner(content string) (string, bool) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(strings.Trim(content, `"`), ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 {
+			labels[parts[0]] = parts[1]
+		}
+	}
+
+	if labels["heritage"] != "external-dns" {
+		return "", false
+	}
+	return labels["external-dns/owner"], true
+}
+
+// matching returns the current records with the name, type and one of the
+// targets of the endpoint
+func matching(
+	domain string, current records.Records, endpoint *Endpoint,
+) (records.Records, error) {
+	wanted, err := Records(domain, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(records.Records, 0, len(wanted))
+	for _, record := range wanted {
+		if existing := findSame(domain, current, record); existing != nil {
+			matched = append(matched, existing)
+		}
+	}
+	return matched, nil
+}
+
+// findSame returns the current record with the same name, type and value as
+// the record, whatever its TTL, or nil
+func findSame(
+	domain string, current records.Records, record records.Record,
+) records.Record {
+	key := identity(domain, record)
+	for _, existing := range current {
+		if identity(domain, existing) == key {
+			return existing
+		}
+	}
+	return nil
+}
+
+// identity identifies a record by its name, type and value, including the
+// priority, weight and port of the types having them
+func identity(domain string, record records.Record) string {
+	values := record.GetURLValues()
+	return strings.Join([]string{
+		records.MatchKey(domain, record),
+		values.Get("prio"), values.Get("weight"), values.Get("port"),
+	}, "\x00")
+}
+
+// targetOf returns the target of a record in the form external-dns uses
+func targetOf(domain string, record records.Record) (string, error) {
+	if record.GetType() == "TXT" {
+		return record.GetContent(), nil
+	}
+
+	rr, err := records.ToRR(record, domain)
+	if err != nil {
+		return "", err
+	}
+	target := strings.TrimPrefix(rr.String(), rr.Header().String())
+	return strings.TrimSuffix(target, "."), nil
+}
+
+// fqdn returns the fully qualified name, without the trailing dot, of a
+// record name relative to the domain
+func fqdn(domain, name string) string {
+	if name == "@" {
+		return strings.ToLower(domain)
+	}
+	return name + "." + strings.ToLower(domain)
+}
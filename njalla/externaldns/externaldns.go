@@ -0,0 +1,499 @@
+// Package externaldns serves external-dns's webhook provider protocol on top
+// of Njalla, so external-dns can manage the records of Kubernetes workloads
+// in Njalla domains. Changes are only made to the records owned by the
+// external-dns instance, as recorded by its TXT registry, and allowed by the
+// policy of the server, see the policy package.
+//
+// external-dns can't send any credentials to a webhook provider, so unlike
+// the other frontends there are no tokens. The server is meant to run next
+// to external-dns, listening on a loopback address, and the policy limits
+// what any caller may change
+package externaldns
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/acme"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// MediaType is the content type of every request and answer of the
+// webhook protocol
+const MediaType = "application/external.dns.webhook+json;version=1"
+
+// Client is the part of provider.Provider needed to manage the records,
+// which a policy.PolicyProvider can wrap
+type Client interface {
+	policy.Client
+	UpdateRecords(
+		domain string, updates map[int]records.Record, removeIDs []int,
+	) error
+}
+
+// Endpoint is external-dns's endpoint.Endpoint: a name with the targets of
+// one record type
+type Endpoint struct {
+	DNSName          string             `json:"dnsName,omitempty"`
+	Targets          []string           `json:"targets,omitempty"`
+	RecordType       string             `json:"recordType,omitempty"`
+	SetIdentifier    string             `json:"setIdentifier,omitempty"`
+	RecordTTL        int64              `json:"recordTTL,omitempty"`
+	Labels           map[string]string  `json:"labels,omitempty"`
+	ProviderSpecific []ProviderSpecific `json:"providerSpecific,omitempty"`
+}
+
+// ProviderSpecific is a provider specific property of an Endpoint. Njalla
+// has none, so they're kept as given
+type ProviderSpecific struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Changes is external-dns's plan.Changes, the body of apply requests.
+// UpdateOld and UpdateNew hold the endpoints before and after each update,
+// in the same order
+type Changes struct {
+	Create    []*Endpoint `json:"create,omitempty"`
+	UpdateOld []*Endpoint `json:"updateOld,omitempty"`
+	UpdateNew []*Endpoint `json:"updateNew,omitempty"`
+	Delete    []*Endpoint `json:"delete,omitempty"`
+}
+
+// DomainFilter is the answer to the negotiation, telling external-dns which
+// domains it may manage
+type DomainFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Server is an http.Handler serving the webhook protocol:
+//
+//	GET  /                 negotiation, answering the DomainFilter
+//	GET  /records          every record of the managed domains
+//	POST /adjustendpoints  endpoints adjusted to what Njalla accepts
+//	POST /records          applies Changes, also served as POST /apply
+//	GET  /healthz          health check
+type Server struct {
+	client  Client
+	ownerID string
+	policy  *policy.Policy
+
+	// Domains limits the domains external-dns may manage. Every domain of
+	// the account if empty
+	Domains []string
+	// Logger, if set, gets a line for every change and every endpoint left
+	// untouched because it isn't owned
+	Logger *log.Logger
+	// Auditor, if set, gets every attempt denied by the policy
+	Auditor policy.Auditor
+
+	// mu serialises the changes, since each one reads the current records
+	// and then changes them
+	mu sync.Mutex
+}
+
+// NewServer returns a Server managing the records owned by the TXT registry
+// owner ID, the --txt-owner-id of external-dns, as far as the rules allow.
+// Without rules, every record of the managed domains may be changed
+func NewServer(
+	client Client, ownerID string, rules []policy.Rule,
+) (*Server, error) {
+	if ownerID == "" {
+		return nil, fmt.Errorf("The TXT registry owner ID is required")
+	}
+
+	if len(rules) == 0 {
+		rules = []policy.Rule{{}}
+	}
+	p, err := policy.New("external-dns "+ownerID, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{client: client, ownerID: ownerID, policy: p}, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !acceptable(r) {
+		http.Error(
+			w, fmt.Sprintf("Only %s is supported", MediaType),
+			http.StatusNotAcceptable,
+		)
+		return
+	}
+
+	client := policy.NewPolicyProvider(s.client, s.policy, s.Auditor)
+	client.Frontend = "external-dns"
+	client.Source = r.RemoteAddr
+
+	switch {
+	case r.URL.Path == "/" && r.Method == http.MethodGet:
+		s.negotiate(w, client)
+	case r.URL.Path == "/records" && r.Method == http.MethodGet:
+		s.getRecords(w, client)
+	case r.URL.Path == "/records" && r.Method == http.MethodPost,
+		r.URL.Path == "/apply" && r.Method == http.MethodPost:
+		s.applyChanges(w, r, client)
+	case r.URL.Path == "/adjustendpoints" && r.Method == http.MethodPost:
+		s.adjustEndpoints(w, r)
+	case r.URL.Path == "/", r.URL.Path == "/records", r.URL.Path == "/apply",
+		r.URL.Path == "/adjustendpoints":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) negotiate(w http.ResponseWriter, client *policy.PolicyProvider) {
+	domains, err := s.domains(client)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DomainFilter{Include: domains})
+}
+
+// getRecords answers the records of the managed domains the policy allows
+// reading
+func (s *Server) getRecords(w http.ResponseWriter, client *policy.PolicyProvider) {
+	domains, err := s.domains(client)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	endpoints := make([]*Endpoint, 0)
+	for _, domain := range domains {
+		if !client.Policy().AllowsDomain(policy.Read, domain) {
+			continue
+		}
+
+		stored, err := client.GetRecords(domain)
+		if err != nil {
+			s.fail(w, err)
+			return
+		}
+		endpoints = append(endpoints, Endpoints(domain, stored)...)
+	}
+
+	writeJSON(w, http.StatusOK, endpoints)
+}
+
+// adjustEndpoints rounds the TTLs, and the priorities of MX and SRV targets,
+// to those Njalla accepts, so external-dns doesn't plan the same update every
+// time, and drops the endpoints of types Njalla doesn't support
+func (s *Server) adjustEndpoints(w http.ResponseWriter, r *http.Request) {
+	var endpoints []*Endpoint
+	if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid endpoints: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	adjusted := make([]*Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if !supportedType(endpoint.RecordType) {
+			s.logf(
+				"Ignoring %s %s, Njalla doesn't support it",
+				endpoint.RecordType, endpoint.DNSName,
+			)
+			continue
+		}
+
+		copied := *endpoint
+		copied.DNSName = strings.ToLower(strings.TrimSuffix(copied.DNSName, "."))
+		copied.RecordTTL = int64(ttl(copied.RecordTTL))
+		copied.Targets = adjustTargets(copied.RecordType, copied.Targets)
+		adjusted = append(adjusted, &copied)
+	}
+
+	writeJSON(w, http.StatusOK, adjusted)
+}
+
+func (s *Server) applyChanges(
+	w http.ResponseWriter, r *http.Request, client *policy.PolicyProvider,
+) {
+	var changes Changes
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid changes: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		http.Error(
+			w, "UpdateOld and UpdateNew must have the same length",
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domains, err := s.domains(client)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	byDomain := make(map[string]*domainChanges)
+	group := func(endpoint *Endpoint) (*domainChanges, error) {
+		domain, err := acme.FindZone(domains, endpoint.DNSName)
+		if err != nil {
+			return nil, err
+		}
+		if byDomain[domain] == nil {
+			byDomain[domain] = &domainChanges{}
+		}
+		return byDomain[domain], nil
+	}
+
+	for _, endpoint := range changes.Create {
+		c, err := group(endpoint)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.create = append(c.create, endpoint)
+	}
+	for i, endpoint := range changes.UpdateOld {
+		c, err := group(endpoint)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.updateOld = append(c.updateOld, endpoint)
+		c.updateNew = append(c.updateNew, changes.UpdateNew[i])
+	}
+	for _, endpoint := range changes.Delete {
+		c, err := group(endpoint)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.delete = append(c.delete, endpoint)
+	}
+
+	names := make([]string, 0, len(byDomain))
+	for domain := range byDomain {
+		names = append(names, domain)
+	}
+	sort.Strings(names)
+
+	for _, domain := range names {
+		err := s.apply(client, domain, byDomain[domain])
+		if policy.IsDenied(err) {
+			s.logf("%s: %s", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			s.fail(w, fmt.Errorf("Couldn't change %s: %s", domain, err))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// domainChanges are the Changes of a single domain
+type domainChanges struct {
+	create    []*Endpoint
+	updateOld []*Endpoint
+	updateNew []*Endpoint
+	delete    []*Endpoint
+}
+
+// apply makes the changes to a domain. Updates and deletions of endpoints
+// not owned by the owner ID are skipped. The whole change is checked against
+// the policy before any of it is made, failing with a *policy.DeniedError.
+// Removals and updates of existing records are made in a single Njalla
+// update operation, and the new records are added afterwards
+func (s *Server) apply(
+	client *policy.PolicyProvider, domain string, changes *domainChanges,
+) error {
+	current, err := s.client.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+
+	added := append([]*Endpoint{}, changes.create...)
+	removed := make([]*Endpoint, 0)
+	owned := append(append([]*Endpoint{}, changes.updateOld...), changes.delete...)
+	for i, endpoint := range owned {
+		if !Owns(domain, current, endpoint, s.ownerID) {
+			s.logf(
+				"Not changing %s %s, it isn't owned by %s",
+				endpoint.RecordType, endpoint.DNSName, s.ownerID,
+			)
+			continue
+		}
+
+		removed = append(removed, endpoint)
+		if i < len(changes.updateNew) {
+			added = append(added, changes.updateNew[i])
+		}
+	}
+
+	desired := make(records.Records, 0)
+	for _, endpoint := range added {
+		converted, err := Records(domain, endpoint)
+		if err != nil {
+			return err
+		}
+		desired = append(desired, converted...)
+	}
+
+	removing := make(map[int]records.Record)
+	for _, endpoint := range removed {
+		matched, err := matching(domain, current, endpoint)
+		if err != nil {
+			return err
+		}
+		for _, record := range matched {
+			removing[record.GetID()] = record
+		}
+	}
+
+	// Records both removed and added are kept, and only updated if their
+	// TTL changed. Records added that already exist aren't added again
+	updates := make(map[int]records.Record)
+	additions := make(records.Records, 0)
+	for _, record := range desired {
+		existing := findSame(domain, current, record)
+		switch {
+		case existing == nil:
+			additions = append(additions, record)
+		case removing[existing.GetID()] != nil:
+			delete(removing, existing.GetID())
+			if existing.GetTTL() != record.GetTTL() {
+				updates[existing.GetID()] = existing.WithTTL(record.GetTTL())
+			}
+		}
+	}
+
+	removeIDs := make([]int, 0, len(removing))
+	for id := range removing {
+		removeIDs = append(removeIDs, id)
+	}
+	sort.Ints(removeIDs)
+
+	for _, id := range removeIDs {
+		if err := client.Check(policy.Delete, domain, removing[id]); err != nil {
+			return err
+		}
+	}
+	for _, stored := range current {
+		record, updated := updates[stored.GetID()]
+		if !updated {
+			continue
+		}
+		for _, r := range []records.Record{stored, record} {
+			if err := client.Check(policy.Update, domain, r); err != nil {
+				return err
+			}
+		}
+	}
+	for _, record := range additions {
+		if err := client.Check(policy.Add, domain, record); err != nil {
+			return err
+		}
+	}
+
+	if len(updates) > 0 || len(removeIDs) > 0 {
+		if err := s.client.UpdateRecords(domain, updates, removeIDs); err != nil {
+			return err
+		}
+	}
+	for _, record := range additions {
+		if err := s.client.AddRecord(domain, record); err != nil {
+			return err
+		}
+	}
+
+	s.logf(
+		"Changed %s: %d added, %d updated, %d removed", domain,
+		len(additions), len(updates), len(removeIDs),
+	)
+	return nil
+}
+
+// domains returns the domains of the account external-dns may manage, those
+// in Domains the policy grants anything on
+func (s *Server) domains(client *policy.PolicyProvider) ([]string, error) {
+	domains, err := client.GetDomains()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Domains) == 0 {
+		return domains, nil
+	}
+
+	managed := make([]string, 0, len(s.Domains))
+	for _, domain := range domains {
+		for _, allowed := range s.Domains {
+			if strings.EqualFold(strings.TrimSuffix(allowed, "."), domain) {
+				managed = append(managed, domain)
+				break
+			}
+		}
+	}
+	return managed, nil
+}
+
+// fail logs an error, coming from Njalla, and answers 500
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	s.logf("%s", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// acceptable returns true if the request takes and sends the webhook media
+// type, or doesn't say
+func acceptable(r *http.Request) bool {
+	header := r.Header.Get("Accept")
+	if r.Method == http.MethodPost {
+		header = r.Header.Get("Content-Type")
+	}
+	if header == "" {
+		return true
+	}
+
+	for _, value := range strings.Split(header, ",") {
+		mediaType, _, err := mime.ParseMediaType(value)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/external.dns.webhook+json", "application/json",
+			"*/*":
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", MediaType)
+	w.Header().Set("Vary", "Content-Type")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
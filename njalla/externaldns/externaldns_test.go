@@ -0,0 +1,472 @@
+package externaldns
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+const registry = `"heritage=external-dns,external-dns/owner=default"`
+
+// newTestServer returns a fake Njalla and a Server managing it with the
+// rules, which allow everything if none are given
+func newTestServer(
+	t *testing.T, rules ...policy.Rule,
+) (*njallatest.Server, *Server) {
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain(
+		"example.com",
+		&records.RecordA{Type: "A", Name: "www", Content: "1.1.1.1", TTL: 300},
+		&records.RecordTXT{Type: "TXT", Name: "a-www", Content: registry, TTL: 300},
+		&records.RecordA{Type: "A", Name: "manual", Content: "2.2.2.2", TTL: 300},
+		&records.RecordMX{
+			Type: "MX", Name: "@", Content: "mail.example.com", TTL: 3600,
+			Priority: 10,
+		},
+		&records.RecordRedirect{
+			Type: "Redirect", Name: "old", URL: "https://example.com",
+			RedirectType: 301,
+		},
+	)
+	njalla.AddDomain("example.org")
+
+	client, err := njalla.Login()
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	server, err := NewServer(client, "default", rules)
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	return njalla, server
+}
+
+func request(
+	t *testing.T, s *Server, method, path string, body interface{},
+) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	r := httptest.NewRequest(method, path, &buf)
+	r.Header.Set("Accept", MediaType)
+	if body != nil {
+		r.Header.Set("Content-Type", MediaType)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestNewServerRequiresOwnerID(t *testing.T) {
+	if _, err := NewServer(nil, "", nil); err == nil {
+		t.Errorf("Expected an error without an owner ID")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodGet, "/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w.Header().Get("Content-Type") != MediaType {
+		t.Errorf("Unexpected content type %s", w.Header().Get("Content-Type"))
+	}
+
+	var filter DomainFilter
+	json.NewDecoder(w.Body).Decode(&filter)
+	expected := DomainFilter{Include: []string{"example.com", "example.org"}}
+	if diff := cmp.Diff(expected, filter); diff != "" {
+		t.Errorf("Domain filter doesn't match:\n%s", diff)
+	}
+
+	s.Domains = []string{"example.org."}
+	w = request(t, s, http.MethodGet, "/", nil)
+	filter = DomainFilter{}
+	json.NewDecoder(w.Body).Decode(&filter)
+	expected = DomainFilter{Include: []string{"example.org"}}
+	if diff := cmp.Diff(expected, filter); diff != "" {
+		t.Errorf("Limited domain filter doesn't match:\n%s", diff)
+	}
+}
+
+func TestNotAcceptable(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "/records", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406, got %d", w.Code)
+	}
+
+	w = request(t, s, http.MethodDelete, "/records", nil)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
+
+func TestGetRecords(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodGet, "/records", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var endpoints []*Endpoint
+	json.NewDecoder(w.Body).Decode(&endpoints)
+
+	// Redirect records have no DNS counterpart, so they're left out
+	expected := []*Endpoint{
+		{
+			DNSName: "a-www.example.com", RecordType: "TXT",
+			Targets: []string{registry}, RecordTTL: 300,
+		},
+		{
+			DNSName: "example.com", RecordType: "MX",
+			Targets: []string{"10 mail.example.com"}, RecordTTL: 3600,
+		},
+		{
+			DNSName: "manual.example.com", RecordType: "A",
+			Targets: []string{"2.2.2.2"}, RecordTTL: 300,
+		},
+		{
+			DNSName: "www.example.com", RecordType: "A",
+			Targets: []string{"1.1.1.1"}, RecordTTL: 300,
+		},
+	}
+	if diff := cmp.Diff(expected, endpoints); diff != "" {
+		t.Errorf("Endpoints don't match:\n%s", diff)
+	}
+}
+
+func TestAdjustEndpoints(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodPost, "/adjustendpoints", []*Endpoint{
+		{DNSName: "WWW.example.com.", RecordType: "A", Targets: []string{"1.1.1.1"}},
+		{
+			DNSName: "api.example.com", RecordType: "A",
+			Targets: []string{"1.1.1.1"}, RecordTTL: 120,
+		},
+		{DNSName: "x.example.com", RecordType: "NAPTR", Targets: []string{"x"}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var endpoints []*Endpoint
+	json.NewDecoder(w.Body).Decode(&endpoints)
+	expected := []*Endpoint{
+		{
+			DNSName: "www.example.com", RecordType: "A",
+			Targets: []string{"1.1.1.1"}, RecordTTL: 10800,
+		},
+		{
+			DNSName: "api.example.com", RecordType: "A",
+			Targets: []string{"1.1.1.1"}, RecordTTL: 60,
+		},
+	}
+	if diff := cmp.Diff(expected, endpoints); diff != "" {
+		t.Errorf("Adjusted endpoints don't match:\n%s", diff)
+	}
+}
+
+func TestAdjustEndpointsPriorities(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodPost, "/adjustendpoints", []*Endpoint{
+		{
+			DNSName: "example.com", RecordType: "MX",
+			Targets: []string{"15 mail.example.com"}, RecordTTL: 3600,
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var endpoints []*Endpoint
+	json.NewDecoder(w.Body).Decode(&endpoints)
+	expected := []*Endpoint{
+		{
+			DNSName: "example.com", RecordType: "MX",
+			Targets: []string{"20 mail.example.com"}, RecordTTL: 3600,
+		},
+	}
+	if diff := cmp.Diff(expected, endpoints); diff != "" {
+		t.Fatalf("Adjusted endpoints don't match:\n%s", diff)
+	}
+
+	// The adjusted endpoint is what GET /records reports once it's stored,
+	// so external-dns sees no difference on the next sync
+	converted, err := Records("example.com", endpoints[0])
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	got := Endpoints("example.com", converted)
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Stored endpoints don't match:\n%s", diff)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodPost, "/records", Changes{
+		Create: []*Endpoint{
+			{
+				DNSName: "api.example.com", RecordType: "A",
+				Targets: []string{"3.3.3.3"}, RecordTTL: 300,
+			},
+			{
+				DNSName: "a-api.example.com", RecordType: "TXT",
+				Targets: []string{registry}, RecordTTL: 300,
+			},
+		},
+		UpdateOld: []*Endpoint{
+			{
+				DNSName: "www.example.com", RecordType: "A",
+				Targets: []string{"1.1.1.1"}, RecordTTL: 300,
+			},
+			{
+				DNSName: "manual.example.com", RecordType: "A",
+				Targets: []string{"2.2.2.2"}, RecordTTL: 300,
+			},
+		},
+		UpdateNew: []*Endpoint{
+			{
+				DNSName: "www.example.com", RecordType: "A",
+				Targets: []string{"1.1.1.1", "4.4.4.4"}, RecordTTL: 3600,
+			},
+			{
+				DNSName: "manual.example.com", RecordType: "A",
+				Targets: []string{"5.5.5.5"}, RecordTTL: 300,
+			},
+		},
+		Delete: []*Endpoint{
+			{
+				DNSName: "example.com", RecordType: "MX",
+				Targets: []string{"10 mail.example.com"}, RecordTTL: 3600,
+			},
+		},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body)
+	}
+
+	stored := njalla.Records("example.com")
+	got := make([]string, 0, len(stored))
+	for _, record := range stored {
+		got = append(got, strings.Join([]string{
+			record.GetName(), record.GetType(), record.GetContent(),
+		}, " "))
+	}
+
+	// The records of www were kept and updated, manual and the MX record
+	// aren't owned so they're untouched, and the rest were added
+	expected := []string{
+		"www A 1.1.1.1",
+		"a-www TXT " + registry,
+		"manual A 2.2.2.2",
+		"@ MX mail.example.com",
+		"old Redirect https://example.com",
+		"api A 3.3.3.3",
+		"a-api TXT " + registry,
+		"www A 4.4.4.4",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Records don't match:\n%s", diff)
+	}
+
+	www := stored.Filter(records.ByName("www"))
+	if www[0].GetTTL() != 3600 {
+		t.Errorf("TTL of www wasn't updated: %s", www[0])
+	}
+	// One update for www, and three additions
+	if njalla.Posts() != 4 {
+		t.Errorf("Expected 4 posts, got %d", njalla.Posts())
+	}
+}
+
+func TestApplyChangesRemovesOwned(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodPost, "/records", Changes{
+		Delete: []*Endpoint{
+			{
+				DNSName: "www.example.com", RecordType: "A",
+				Targets: []string{"1.1.1.1"},
+			},
+			{
+				DNSName: "a-www.example.com", RecordType: "TXT",
+				Targets: []string{registry},
+			},
+		},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body)
+	}
+
+	if len(njalla.Records("example.com").Filter(records.ByName("www"))) != 0 ||
+		len(njalla.Records("example.com").Filter(records.ByName("a-www"))) != 0 {
+		t.Errorf("Records weren't removed: %s", njalla.Records("example.com"))
+	}
+	if njalla.Posts() != 1 {
+		t.Errorf("Expected a single update, got %d posts", njalla.Posts())
+	}
+}
+
+func TestApplyChangesOutsideDomains(t *testing.T) {
+	njalla, s := newTestServer(t)
+	defer njalla.Close()
+
+	w := request(t, s, http.MethodPost, "/records", Changes{
+		Create: []*Endpoint{
+			{
+				DNSName: "www.example.net", RecordType: "A",
+				Targets: []string{"1.1.1.1"},
+			},
+		},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d: %s", w.Code, w.Body)
+	}
+	if njalla.Posts() != 0 {
+		t.Errorf("Records outside the domains were sent to Njalla")
+	}
+}
+
+// testAuditor keeps the denials
+type testAuditor struct {
+	denials []policy.Denial
+}
+
+func (a *testAuditor) Deny(d policy.Denial) {
+	a.denials = append(a.denials, d)
+}
+
+func TestPolicy(t *testing.T) {
+	// external-dns may only manage www and its registry record in
+	// example.com
+	njalla, s := newTestServer(t, policy.Rule{
+		Domains: []string{"example.com"},
+		Names:   []string{"www.example.com", "a-www.example.com"},
+	})
+	defer njalla.Close()
+	audit := &testAuditor{}
+	s.Auditor = audit
+
+	w := request(t, s, http.MethodGet, "/", nil)
+	var filter DomainFilter
+	json.NewDecoder(w.Body).Decode(&filter)
+	if expected := []string{"example.com"}; !cmp.Equal(expected, filter.Include) {
+		t.Errorf("Domains don't match:\n%s", cmp.Diff(expected, filter.Include))
+	}
+
+	w = request(t, s, http.MethodGet, "/records", nil)
+	var endpoints []*Endpoint
+	json.NewDecoder(w.Body).Decode(&endpoints)
+	names := make([]string, 0)
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.DNSName)
+	}
+	if expected := []string{"a-www.example.com", "www.example.com"}; !cmp.Equal(expected, names) {
+		t.Errorf("Endpoints don't match:\n%s", cmp.Diff(expected, names))
+	}
+
+	// The whole change is refused when any part of it is denied
+	w = request(t, s, http.MethodPost, "/records", Changes{
+		Create: []*Endpoint{
+			{
+				DNSName: "api.example.com", RecordType: "A",
+				Targets: []string{"3.3.3.3"},
+			},
+		},
+		Delete: []*Endpoint{
+			{
+				DNSName: "www.example.com", RecordType: "A",
+				Targets: []string{"1.1.1.1"},
+			},
+		},
+	})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d: %s", w.Code, w.Body)
+	}
+	if njalla.Posts() != 0 {
+		t.Errorf("A denied change was sent to Njalla")
+	}
+
+	if len(audit.denials) != 1 || audit.denials[0].Name != "api" ||
+		audit.denials[0].Frontend != "external-dns" {
+		t.Errorf("Denial wasn't audited: %+v", audit.denials)
+	}
+}
+
+func TestRecordsRoundTrip(t *testing.T) {
+	for _, endpoint := range []*Endpoint{
+		{DNSName: "example.com", RecordType: "A", Targets: []string{"1.1.1.1"}},
+		{
+			DNSName: "www.example.com", RecordType: "AAAA",
+			Targets: []string{"2001:db8::1"},
+		},
+		{
+			DNSName: "alias.example.com", RecordType: "CNAME",
+			Targets: []string{"example.net"},
+		},
+		{
+			DNSName: "example.com", RecordType: "MX",
+			Targets: []string{"10 mail.example.com", "20 backup.example.com"},
+		},
+		{
+			DNSName: "txt.example.com", RecordType: "TXT",
+			Targets: []string{"v=spf1 -all"},
+		},
+		{
+			DNSName: "_sip._tcp.example.com", RecordType: "SRV",
+			Targets: []string{"10 5 5060 sip.example.com"},
+		},
+		{
+			DNSName: "example.com", RecordType: "CAA",
+			Targets: []string{`0 issue "letsencrypt.org"`},
+		},
+	} {
+		endpoint.RecordTTL = 60
+		converted, err := Records("example.com", endpoint)
+		if err != nil {
+			t.Errorf("%s: %s", endpoint.RecordType, err)
+			continue
+		}
+
+		got := Endpoints("example.com", converted)
+		if diff := cmp.Diff([]*Endpoint{endpoint}, got); diff != "" {
+			t.Errorf("%s doesn't round trip:\n%s", endpoint.RecordType, diff)
+		}
+	}
+}
@@ -0,0 +1,687 @@
+// Package libdns implements the libdns interfaces on top of Njalla, so Caddy
+// and other tools built on libdns can manage Njalla records. Only the types
+// with a DNS counterpart are handled, so Redirect and Dynamic records are
+// neither returned nor changed
+package libdns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// Client is the part of provider.Provider the libdns interfaces need
+type Client interface {
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	UpdateRecords(
+		domain string, updates map[int]records.Record, removeIDs []int,
+	) error
+	RemoveRecords(domain string, recordIDs []int) error
+}
+
+// Provider implements libdns.RecordGetter, RecordAppender, RecordSetter and
+// RecordDeleter. It either uses the client given to NewProvider, or logs in
+// with Email and Password the first time it's used, as when configured by
+// Caddy
+type Provider struct {
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// mu serialises the calls, since most read the records before changing
+	// them
+	mu     sync.Mutex
+	client Client
+}
+
+var (
+	_ libdns.RecordGetter   = (*Provider)(nil)
+	_ libdns.RecordAppender = (*Provider)(nil)
+	_ libdns.RecordSetter   = (*Provider)(nil)
+	_ libdns.RecordDeleter  = (*Provider)(nil)
+)
+
+// NewProvider returns a Provider using a logged in client
+func NewProvider(client Client) *Provider {
+	return &Provider{client: client}
+}
+
+// login returns the client, logging in with Email and Password if there
+// isn't one yet
+func (p *Provider) login() (Client, error) {
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	if p.Email == "" || p.Password == "" {
+		return nil, fmt.Errorf("Email and password are required to log in")
+	}
+
+	njalla, err := provider.New()
+	if err != nil {
+		return nil, err
+	}
+	if err := njalla.Login(p.Email, p.Password); err != nil {
+		return nil, err
+	}
+
+	p.client = njalla
+	return njalla, nil
+}
+
+// GetRecords returns the records of the zone, such as `example.com.`
+func (p *Provider) GetRecords(
+	ctx context.Context, zone string,
+) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, stored, err := p.fetch(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]libdns.Record, 0, len(stored))
+	for _, record := range stored {
+		result = append(result, toLibdns(record))
+	}
+	return result, nil
+}
+
+// AppendRecords adds the records to the zone, returning them with their IDs.
+// Every record is checked before any is added
+func (p *Provider) AppendRecords(
+	ctx context.Context, zone string, recs []libdns.Record,
+) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	domain := domainOf(zone)
+	client, before, err := p.fetch(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := capabilities(client, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	additions, err := fromLibdnsAll(caps, domain, recs)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.add(ctx, client, domain, before, additions)
+}
+
+// SetRecords makes the records of the zone match the given ones. Records
+// with an ID replace the record with that ID. The records without one are
+// grouped by name and type, and each group replaces every stored record
+// with its name and type, reusing the stored records where possible.
+//
+// Updates and removals are made in a single Njalla update operation, so
+// they either all happen or none does. Additions can only be made one by
+// one afterwards
+func (p *Provider) SetRecords(
+	ctx context.Context, zone string, recs []libdns.Record,
+) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	domain := domainOf(zone)
+	client, stored, err := p.fetch(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := capabilities(client, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	desired, err := fromLibdnsAll(caps, domain, recs)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &setPlan{domain: domain, updates: make(map[int]records.Record)}
+	if err := s.plan(stored, recs, desired); err != nil {
+		return nil, err
+	}
+
+	if len(s.updates) > 0 || len(s.removeIDs) > 0 {
+		err := client.UpdateRecords(domain, s.updates, s.removeIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]libdns.Record, 0, len(recs))
+	for _, id := range s.order {
+		record := s.updates[id]
+		if record == nil {
+			record, _ = stored.FindByID(id)
+		}
+		set := toLibdns(record)
+		set.ID = strconv.Itoa(id)
+		result = append(result, set)
+	}
+
+	if len(s.additions) == 0 {
+		return result, nil
+	}
+
+	before, err := client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	added, err := p.add(ctx, client, domain, supported(before), s.additions)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, added...), nil
+}
+
+// DeleteRecords removes the records from the zone in a single Njalla update
+// operation, returning those removed. Records with an ID remove the record
+// with that ID. Any other removes the records with its name and type, and
+// its value, TTL and priority if given
+func (p *Provider) DeleteRecords(
+	ctx context.Context, zone string, recs []libdns.Record,
+) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	domain := domainOf(zone)
+	client, stored, err := p.fetch(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := capabilities(client, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make(map[int]bool)
+	removeIDs := make([]int, 0)
+	deleted := make([]libdns.Record, 0)
+	for _, rec := range recs {
+		matches, err := matching(caps, domain, stored, rec)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range matches {
+			if removed[record.GetID()] {
+				continue
+			}
+			removed[record.GetID()] = true
+			removeIDs = append(removeIDs, record.GetID())
+			deleted = append(deleted, toLibdns(record))
+		}
+	}
+
+	if len(removeIDs) == 0 {
+		return deleted, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := client.RemoveRecords(domain, removeIDs); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// fetch returns the client and the stored records of the zone libdns can
+// represent
+func (p *Provider) fetch(
+	ctx context.Context, zone string,
+) (Client, records.Records, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := p.login()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := client.GetRecords(domainOf(zone))
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, supported(stored), nil
+}
+
+// capabilities returns the values the domain accepts, if the client can
+// get them, or the defaults
+func capabilities(
+	client Client, domain string,
+) (structures.Capabilities, error) {
+	caps, err := records.CapabilitiesOf(client, domain)
+	if err != nil {
+		return caps, fmt.Errorf(
+			"Couldn't get the capabilities of %s: %s", domain, err,
+		)
+	}
+	return caps, nil
+}
+
+// add adds the records, and returns them as stored. Njalla doesn't return
+// the new records, so they're found among the records that weren't there
+// before
+func (p *Provider) add(
+	ctx context.Context, client Client, domain string,
+	before records.Records, additions records.Records,
+) ([]libdns.Record, error) {
+	for _, record := range additions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := client.AddRecord(domain, record); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make(map[int]bool)
+	result := make([]libdns.Record, 0, len(additions))
+	for _, record := range additions {
+		key := records.MatchKey(domain, record)
+		for _, stored := range after {
+			_, existed := before.FindByID(stored.GetID())
+			if existed || claimed[stored.GetID()] ||
+				records.MatchKey(domain, stored) != key {
+				continue
+			}
+			claimed[stored.GetID()] = true
+			result = append(result, toLibdns(stored))
+			break
+		}
+	}
+
+	if len(result) != len(additions) {
+		return nil, fmt.Errorf("Couldn't find the added records")
+	}
+	return result, nil
+}
+
+// setPlan holds the changes SetRecords makes
+type setPlan struct {
+	domain    string
+	updates   map[int]records.Record
+	removeIDs []int
+	additions records.Records
+	// order holds the IDs of the records set, other than the additions, in
+	// the order they were given
+	order []int
+}
+
+func (s *setPlan) plan(
+	stored records.Records, recs []libdns.Record, desired records.Records,
+) error {
+	claimed := make(map[int]bool)
+
+	// Records with IDs are replaced first, so the groups can't reuse them
+	groups := make(map[string]records.Records)
+	groupOrder := make([]string, 0)
+	for i, rec := range recs {
+		if rec.ID == "" {
+			key := records.CanonicalName(s.domain, desired[i].GetName()) +
+				"\x00" + desired[i].GetType()
+			if _, exists := groups[key]; !exists {
+				groupOrder = append(groupOrder, key)
+			}
+			groups[key] = append(groups[key], desired[i])
+			continue
+		}
+
+		id, err := strconv.Atoi(rec.ID)
+		if err != nil {
+			return fmt.Errorf("Invalid record ID %q", rec.ID)
+		}
+		current, exists := stored.FindByID(id)
+		if !exists {
+			return fmt.Errorf("Record %d doesn't exist in %s", id, s.domain)
+		}
+		if claimed[id] {
+			return fmt.Errorf("Record %d is set twice", id)
+		}
+		claimed[id] = true
+
+		if current.GetType() != desired[i].GetType() {
+			// Njalla can't change the type of a record, so it's replaced
+			s.removeIDs = append(s.removeIDs, id)
+			s.additions = append(s.additions, desired[i])
+			continue
+		}
+		s.set(current, desired[i])
+	}
+
+	for _, key := range groupOrder {
+		group := groups[key]
+		existing := stored.Filter(func(record records.Record) bool {
+			return !claimed[record.GetID()] &&
+				records.CanonicalName(s.domain, record.GetName())+"\x00"+
+					record.GetType() == key
+		})
+
+		// Stored records with the same value are kept, or updated if
+		// their TTL or priority changed
+		unmatched := make(records.Records, 0)
+		for _, record := range group {
+			match := -1
+			for i, current := range existing {
+				if sameValue(current, record) {
+					match = i
+					break
+				}
+			}
+			if match == -1 {
+				unmatched = append(unmatched, record)
+				continue
+			}
+
+			claimed[existing[match].GetID()] = true
+			s.set(existing[match], record)
+			existing = append(existing[:match], existing[match+1:]...)
+		}
+
+		// The rest of the stored records get the new values, and any left
+		// over is removed
+		for _, record := range unmatched {
+			if len(existing) == 0 {
+				s.additions = append(s.additions, record)
+				continue
+			}
+			claimed[existing[0].GetID()] = true
+			s.set(existing[0], record)
+			existing = existing[1:]
+		}
+		for _, current := range existing {
+			s.removeIDs = append(s.removeIDs, current.GetID())
+		}
+	}
+
+	return nil
+}
+
+// set replaces the current record with the desired one, if they differ
+func (s *setPlan) set(current, desired records.Record) {
+	s.order = append(s.order, current.GetID())
+
+	want, have := toLibdns(desired), toLibdns(current)
+	want.ID = have.ID
+	if want != have {
+		s.updates[current.GetID()] = desired
+	}
+}
+
+// matching returns the stored records a libdns record given to
+// DeleteRecords refers to
+func matching(
+	caps structures.Capabilities, domain string, stored records.Records,
+	rec libdns.Record,
+) (records.Records, error) {
+	if rec.ID != "" {
+		id, err := strconv.Atoi(rec.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid record ID %q", rec.ID)
+		}
+		if record, exists := stored.FindByID(id); exists {
+			return records.Records{record}, nil
+		}
+		return nil, nil
+	}
+
+	name, err := relativeName(domain, rec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The TTL and priority are compared as Njalla would store them
+	ttl, priority := rec.TTL, rec.Priority
+	if ttl != 0 {
+		if ttl, err = roundTTL(caps, ttl); err != nil {
+			return nil, err
+		}
+	}
+	if priority != 0 {
+		priority, err = records.ClosestPriorityWith(caps, priority)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stored.Filter(func(record records.Record) bool {
+		current := toLibdns(record)
+		switch {
+		case records.CanonicalName(domain, record.GetName()) != name,
+			!strings.EqualFold(current.Type, rec.Type),
+			ttl != 0 && current.TTL != ttl,
+			priority != 0 && current.Priority != priority:
+			return false
+		case rec.Value == "":
+			return true
+		}
+
+		// The value is compared in the form Njalla stores it
+		wanted, err := fromLibdns(caps, domain, rec)
+		return err == nil && sameValue(record, wanted)
+	}), nil
+}
+
+// sameValue returns true if the records of the same type have the same
+// content, as compared by records.CanonicalContent, and the same priority
+// and other fields of those types having them
+func sameValue(a, b records.Record) bool {
+	if records.CanonicalContent(a) != records.CanonicalContent(b) {
+		return false
+	}
+
+	x, y := toLibdns(a.WithContent("")), toLibdns(b.WithContent(""))
+	return x.Value == y.Value && x.Priority == y.Priority
+}
+
+// supported returns the records libdns can represent
+func supported(stored records.Records) records.Records {
+	return stored.Filter(func(record records.Record) bool {
+		switch record.GetType() {
+		case "Redirect", "Dynamic":
+			return false
+		}
+		return true
+	})
+}
+
+// domainOf returns the Njalla domain of a libdns zone, which is usually
+// fully qualified
+func domainOf(zone string) string {
+	return strings.TrimSuffix(strings.ToLower(zone), ".")
+}
+
+// relativeName returns the name Njalla uses for a libdns record name,
+// which is relative to the zone, although fully qualified names in the zone
+// are accepted too
+func relativeName(domain, name string) (string, error) {
+	if strings.HasSuffix(name, ".") {
+		fqdn := strings.ToLower(strings.TrimSuffix(name, "."))
+		if fqdn != domain && !strings.HasSuffix(fqdn, "."+domain) {
+			return "", fmt.Errorf("Name %s is outside of the zone %s", name, domain)
+		}
+	}
+	return records.CanonicalName(domain, name), nil
+}
+
+// roundTTL returns the TTL the domain accepts closest to the libdns one
+func roundTTL(
+	caps structures.Capabilities, ttl time.Duration,
+) (time.Duration, error) {
+	closest, err := records.ClosestTTLWith(caps, int(ttl/time.Second))
+	return time.Duration(closest) * time.Second, err
+}
+
+// toLibdns converts a Njalla record. MX and SRV records keep their priority
+// in Priority, and the value of SRV and SSHFP records has the other fields
+// before the content, as in their zone file form
+func toLibdns(record records.Record) libdns.Record {
+	result := libdns.Record{
+		ID:    strconv.Itoa(record.GetID()),
+		Type:  record.GetType(),
+		Name:  record.GetName(),
+		Value: record.GetContent(),
+		TTL:   time.Duration(record.GetTTL()) * time.Second,
+	}
+
+	switch r := record.(type) {
+	case *records.RecordMX:
+		result.Priority = r.Priority
+	case *records.RecordSRV:
+		result.Priority = r.Priority
+		result.Value = fmt.Sprintf("%d %d %s", r.Weight, r.Port, r.Content)
+	case *records.RecordSSHFP:
+		result.Value = fmt.Sprintf(
+			"%d %d %s", r.SSHAlgorithm, r.SSHType, r.Content,
+		)
+	}
+
+	return result
+}
+
+// fromLibdnsAll converts the libdns records, failing on the first one that
+// can't be converted
+func fromLibdnsAll(
+	caps structures.Capabilities, domain string, recs []libdns.Record,
+) (records.Records, error) {
+	result := make(records.Records, 0, len(recs))
+	for _, rec := range recs {
+		record, err := fromLibdns(caps, domain, rec)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// fromLibdns converts a libdns record into a new Njalla record without an
+// ID, validated against the capabilities of the domain. The TTL, and the
+// priority of MX and SRV records, are changed to the closest ones the
+// domain accepts
+func fromLibdns(
+	caps structures.Capabilities, domain string, rec libdns.Record,
+) (records.Record, error) {
+	name, err := relativeName(domain, rec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := roundTTL(caps, rec.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToUpper(rec.Type) {
+	case "MX", "SRV":
+		rec.Priority, err = records.ClosestPriorityWith(caps, rec.Priority)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newRecord(caps, name, int(ttl/time.Second), rec)
+}
+
+// newRecord creates the Njalla record of a libdns one through the
+// NewRecord*With constructors
+func newRecord(
+	caps structures.Capabilities, name string, ttl int, rec libdns.Record,
+) (records.Record, error) {
+	value := rec.Value
+	hostname := strings.TrimSuffix(value, ".")
+
+	switch strings.ToUpper(rec.Type) {
+	case "A":
+		r, err := records.NewRecordAWith(caps, name, value, ttl)
+		return &r, err
+	case "AAAA":
+		r, err := records.NewRecordAAAAWith(caps, name, value, ttl)
+		return &r, err
+	case "CNAME":
+		r, err := records.NewRecordCNAMEWith(caps, name, hostname, ttl)
+		return &r, err
+	case "MX":
+		r, err := records.NewRecordMXWith(
+			caps, name, hostname, ttl, rec.Priority,
+		)
+		return &r, err
+	case "TXT":
+		r, err := records.NewRecordTXTWith(caps, name, value, ttl)
+		return &r, err
+	case "SRV":
+		fields := strings.Fields(value)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf(
+				"SRV value %q isn't `weight port target`", value,
+			)
+		}
+		weight, weightErr := strconv.ParseUint(fields[0], 10, 16)
+		port, portErr := strconv.ParseUint(fields[1], 10, 16)
+		if weightErr != nil || portErr != nil {
+			return nil, fmt.Errorf("Invalid SRV weight or port in %q", value)
+		}
+		r, err := records.NewRecordSRVWith(
+			caps, name, strings.TrimSuffix(fields[2], "."), ttl, rec.Priority,
+			uint(weight), uint(port),
+		)
+		return &r, err
+	case "CAA":
+		r, err := records.NewRecordCAAWith(caps, name, value, ttl)
+		return &r, err
+	case "PTR":
+		r, err := records.NewRecordPTRWith(caps, name, hostname, ttl)
+		return &r, err
+	case "NS":
+		r, err := records.NewRecordNSWith(caps, name, hostname, ttl)
+		return &r, err
+	case "TLSA":
+		r, err := records.NewRecordTLSAWith(caps, name, value, ttl)
+		return &r, err
+	case "SSHFP":
+		fields := strings.Fields(value)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf(
+				"SSHFP value %q isn't `algorithm type fingerprint`", value,
+			)
+		}
+		algorithm, algorithmErr := strconv.Atoi(fields[0])
+		sshType, typeErr := strconv.Atoi(fields[1])
+		if algorithmErr != nil || typeErr != nil {
+			return nil, fmt.Errorf("Invalid SSHFP algorithm or type in %q", value)
+		}
+		r, err := records.NewRecordSSHFPWith(
+			caps, name, fields[2], ttl, algorithm, sshType,
+		)
+		return &r, err
+	default:
+		return nil, fmt.Errorf(
+			"Record type %s isn't supported by Njalla", rec.Type,
+		)
+	}
+}
@@ -0,0 +1,364 @@
+package libdns
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/libdns/libdns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+const zone = "example.com."
+
+func newTestProvider(t *testing.T) (*njallatest.Server, *Provider) {
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain(
+		"example.com",
+		&records.RecordA{Type: "A", Name: "www", Content: "1.1.1.1", TTL: 300},
+		&records.RecordMX{
+			Type: "MX", Name: "@", Content: "mail.example.com", TTL: 3600,
+			Priority: 10,
+		},
+		&records.RecordRedirect{
+			Type: "Redirect", Name: "old", URL: "https://example.com",
+			RedirectType: 301,
+		},
+	)
+
+	client, err := njalla.Login()
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	return njalla, NewProvider(client)
+}
+
+// withoutIDs returns the records sorted, without their IDs, to compare them
+func withoutIDs(recs []libdns.Record) []libdns.Record {
+	result := make([]libdns.Record, 0, len(recs))
+	for _, rec := range recs {
+		rec.ID = ""
+		result = append(result, rec)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+func TestGetRecords(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+
+	recs, err := p.GetRecords(context.Background(), zone)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Redirect records have no DNS counterpart, so they're left out
+	expected := []libdns.Record{
+		{
+			Type: "MX", Name: "@", Value: "mail.example.com",
+			TTL: time.Hour, Priority: 10,
+		},
+		{Type: "A", Name: "www", Value: "1.1.1.1", TTL: 5 * time.Minute},
+	}
+	if !cmp.Equal(expected, withoutIDs(recs)) {
+		t.Errorf("Records don't match:\n%s", cmp.Diff(expected, withoutIDs(recs)))
+	}
+	for _, rec := range recs {
+		if rec.ID == "" {
+			t.Errorf("Record without ID: %+v", rec)
+		}
+	}
+}
+
+func TestAppendRecords(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+
+	added, err := p.AppendRecords(context.Background(), zone, []libdns.Record{
+		// Fully qualified names and TTLs Njalla doesn't accept are fine
+		{
+			Type: "TXT", Name: "_acme-challenge.example.com.", Value: "token",
+			TTL: 2 * time.Minute,
+		},
+		{
+			Type: "SRV", Name: "_sip._tcp", Value: "5 5060 sip.example.com.",
+			TTL: time.Hour, Priority: 10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []libdns.Record{
+		{
+			Type: "TXT", Name: "_acme-challenge", Value: "token",
+			TTL: time.Minute,
+		},
+		{
+			Type: "SRV", Name: "_sip._tcp", Value: "5 5060 sip.example.com",
+			TTL: time.Hour, Priority: 10,
+		},
+	}
+	if !cmp.Equal(withoutIDs(expected), withoutIDs(added)) {
+		t.Errorf(
+			"Added records don't match:\n%s",
+			cmp.Diff(withoutIDs(expected), withoutIDs(added)),
+		)
+	}
+	for _, rec := range added {
+		if rec.ID == "" {
+			t.Errorf("Added record without ID: %+v", rec)
+		}
+	}
+
+	if len(njalla.Records("example.com")) != 5 {
+		t.Errorf("Unexpected records: %s", njalla.Records("example.com"))
+	}
+}
+
+func TestAppendRecordsChecksAllFirst(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+
+	for _, recs := range [][]libdns.Record{
+		{
+			{Type: "A", Name: "a", Value: "1.1.1.1"},
+			{Type: "A", Name: "b", Value: "not an IP"},
+		},
+		{{Type: "TXT", Name: "www.example.net.", Value: "outside"}},
+		{{Type: "SPF", Name: "www", Value: "v=spf1"}},
+	} {
+		if _, err := p.AppendRecords(context.Background(), zone, recs); err == nil {
+			t.Errorf("Expected an error for %+v", recs)
+		}
+	}
+
+	if njalla.Posts() != 0 {
+		t.Errorf("Invalid records were sent to Njalla")
+	}
+}
+
+func TestAppendRecordsRoundsToCapabilities(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+	njalla.Capabilities.TTLs = []int{60, 43200}
+
+	added, err := p.AppendRecords(context.Background(), zone, []libdns.Record{
+		{
+			Type: "MX", Name: "@", Value: "backup.example.com",
+			TTL: 12 * time.Hour, Priority: 15,
+		},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// The priority is rounded like the TTL, which is one only the domain's
+	// capabilities accept
+	expected := []libdns.Record{
+		{
+			Type: "MX", Name: "@", Value: "backup.example.com",
+			TTL: 12 * time.Hour, Priority: 20,
+		},
+	}
+	if !cmp.Equal(expected, withoutIDs(added)) {
+		t.Errorf("Records don't match:\n%s", cmp.Diff(expected, withoutIDs(added)))
+	}
+}
+
+func TestSetRecords(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+	njalla.AddDomain(
+		"example.org",
+		&records.RecordA{Type: "A", Name: "www", Content: "1.1.1.1", TTL: 300},
+		&records.RecordA{Type: "A", Name: "www", Content: "2.2.2.2", TTL: 300},
+		&records.RecordA{Type: "A", Name: "www", Content: "3.3.3.3", TTL: 300},
+		&records.RecordTXT{Type: "TXT", Name: "www", Content: "keep", TTL: 300},
+	)
+
+	// The A RRset of www becomes 2.2.2.2 and 4.4.4.4, with a new TTL
+	set, err := p.SetRecords(context.Background(), "example.org.", []libdns.Record{
+		{Type: "A", Name: "www", Value: "2.2.2.2", TTL: time.Hour},
+		{Type: "A", Name: "www", Value: "4.4.4.4", TTL: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []libdns.Record{
+		{Type: "A", Name: "www", Value: "2.2.2.2", TTL: time.Hour},
+		{Type: "A", Name: "www", Value: "4.4.4.4", TTL: time.Hour},
+	}
+	if !cmp.Equal(expected, withoutIDs(set)) {
+		t.Errorf(
+			"Set records don't match:\n%s", cmp.Diff(expected, withoutIDs(set)),
+		)
+	}
+
+	// Stored records were reused, and everything was done in one post
+	if njalla.Posts() != 1 {
+		t.Errorf("Expected a single update, got %d posts", njalla.Posts())
+	}
+	stored, _ := p.GetRecords(context.Background(), "example.org.")
+	expected = append(expected, libdns.Record{
+		Type: "TXT", Name: "www", Value: "keep", TTL: 5 * time.Minute,
+	})
+	if diff := cmp.Diff(expected, withoutIDs(stored)); diff != "" {
+		t.Errorf("Stored records don't match:\n%s", diff)
+	}
+
+	// Setting the same records again changes nothing
+	_, err = p.SetRecords(context.Background(), "example.org.", set)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if njalla.Posts() != 1 {
+		t.Errorf("Setting unchanged records posted to Njalla")
+	}
+}
+
+func TestSetRecordsByID(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+
+	www := njalla.Records("example.com").Filter(records.ByName("www"))[0]
+	rec := toLibdns(www)
+	rec.Value = "9.9.9.9"
+
+	set, err := p.SetRecords(context.Background(), zone, []libdns.Record{rec})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(set) != 1 || set[0].ID != rec.ID || set[0].Value != "9.9.9.9" {
+		t.Errorf("Unexpected set records: %+v", set)
+	}
+
+	stored, _ := njalla.Records("example.com").FindByID(www.GetID())
+	if stored == nil || stored.GetContent() != "9.9.9.9" {
+		t.Errorf("Record wasn't updated: %+v", stored)
+	}
+
+	rec.ID = "999"
+	_, err = p.SetRecords(context.Background(), zone, []libdns.Record{rec})
+	if err == nil {
+		t.Errorf("Expected an error for an unknown ID")
+	}
+}
+
+func TestDeleteRecords(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+	challenge := func(content string) records.Record {
+		return &records.RecordTXT{
+			Type: "TXT", Name: "_acme-challenge", Content: content, TTL: 60,
+		}
+	}
+	njalla.AddDomain(
+		"example.org", challenge("a"), challenge("b"), challenge("c"),
+		&records.RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300},
+	)
+
+	ctx := context.Background()
+	a := njalla.Records("example.org").Filter(records.ByName("_acme-challenge"))[0]
+	deleted, err := p.DeleteRecords(ctx, "example.org", []libdns.Record{
+		{ID: toLibdns(a).ID},
+		{Type: "TXT", Name: "_acme-challenge.example.org.", Value: "b"},
+		{Type: "TXT", Name: "_acme-challenge", Value: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("Expected 2 deleted records, got %+v", deleted)
+	}
+	if njalla.Posts() != 1 {
+		t.Errorf("Expected a single update, got %d posts", njalla.Posts())
+	}
+
+	// Without a value, every record with the name and type is deleted
+	deleted, err = p.DeleteRecords(ctx, "example.org", []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge"},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(deleted) != 1 || deleted[0].Value != "c" {
+		t.Errorf("Unexpected deleted records: %+v", deleted)
+	}
+
+	remaining := njalla.Records("example.org")
+	if len(remaining) != 1 || remaining[0].GetType() != "A" {
+		t.Errorf("Unexpected remaining records: %s", remaining)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	njalla, p := newTestProvider(t)
+	defer njalla.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := p.AppendRecords(ctx, zone, []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token"},
+	})
+	if err != context.Canceled || njalla.Posts() != 0 {
+		t.Errorf("Expected nothing to be done, got %v", err)
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	for _, rec := range []libdns.Record{
+		{Type: "A", Name: "@", Value: "1.1.1.1", TTL: time.Minute},
+		{Type: "AAAA", Name: "www", Value: "2001:db8::1", TTL: time.Minute},
+		{Type: "CNAME", Name: "alias", Value: "example.net", TTL: time.Minute},
+		{
+			Type: "MX", Name: "@", Value: "mail.example.com", TTL: time.Minute,
+			Priority: 20,
+		},
+		{Type: "TXT", Name: "txt", Value: "v=spf1 -all", TTL: time.Minute},
+		{
+			Type: "SRV", Name: "_sip._tcp", Value: "1 5060 sip.example.com",
+			TTL: time.Minute, Priority: 10,
+		},
+		{
+			Type: "CAA", Name: "@", Value: `0 issue "letsencrypt.org"`,
+			TTL: time.Minute,
+		},
+		{Type: "NS", Name: "sub", Value: "ns1.example.net", TTL: time.Minute},
+		{Type: "SSHFP", Name: "host", Value: "4 2 " + sha256Hex, TTL: time.Minute},
+	} {
+		record, err := fromLibdns(
+			structures.DefaultCapabilities(), "example.com", rec,
+		)
+		if err != nil {
+			t.Errorf("%s: %s", rec.Type, err)
+			continue
+		}
+
+		got := toLibdns(record)
+		got.ID = ""
+		if !cmp.Equal(rec, got) {
+			t.Errorf("%s doesn't round trip:\n%s", rec.Type, cmp.Diff(rec, got))
+		}
+	}
+}
+
+const sha256Hex = "123456789abcdef67890123456789abcdef67890123456789abcdef123456789"
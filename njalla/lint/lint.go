@@ -0,0 +1,182 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// Severity of a lint finding
+type Severity int
+
+const (
+	// Info findings are merely informative
+	Info Severity = iota
+	// Warning findings are likely mistakes, but the zone still works
+	Warning
+	// Error findings will break resolution of at least part of the zone
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Finding is a single problem found by a Rule
+type Finding struct {
+	Rule     string
+	Severity Severity
+	// Name is the fully qualified name the finding refers to
+	Name    string
+	Message string
+	Records records.Records
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%-7s %-20s %s: %s", f.Severity, f.Rule, f.Name, f.Message)
+}
+
+// Zone is a domain with its records, as passed to every Rule
+type Zone struct {
+	Origin  string
+	Records records.Records
+}
+
+// FQDN returns the fully qualified name, without trailing dot, of a record
+// name in the zone, such as `www.mydomain.com` for `www`
+func (z Zone) FQDN(name string) string {
+	origin := strings.ToLower(strings.TrimSuffix(z.Origin, "."))
+	name = strings.ToLower(name)
+
+	if name == "@" || name == "" {
+		return origin
+	}
+
+	if strings.HasSuffix(name, ".") {
+		return strings.TrimSuffix(name, ".")
+	}
+
+	if origin == "" {
+		return name
+	}
+	return name + "." + origin
+}
+
+// Target returns the fully qualified name, without trailing dot, of a
+// hostname used as content by records like CNAME or MX. Njalla takes those
+// as absolute names, so only single label targets are considered relative to
+// the zone
+func (z Zone) Target(hostname string) string {
+	hostname = strings.ToLower(hostname)
+
+	if strings.HasSuffix(hostname, ".") || strings.Contains(hostname, ".") {
+		return strings.TrimSuffix(hostname, ".")
+	}
+
+	return z.FQDN(hostname)
+}
+
+// ByName groups the zone records by their fully qualified name
+func (z Zone) ByName() map[string]records.Records {
+	grouped := make(map[string]records.Records)
+	for _, record := range z.Records {
+		name := z.FQDN(record.GetName())
+		grouped[name] = append(grouped[name], record)
+	}
+	return grouped
+}
+
+// Rule is a single check over a whole Zone
+type Rule interface {
+	// ID is a short identifier for the rule, such as `cname-exclusive`
+	ID() string
+	Check(zone Zone) []Finding
+}
+
+// Linter runs a set of Rules over zones
+type Linter struct {
+	Rules []Rule
+	// Severities overrides the severity of the findings of a rule, by ID
+	Severities map[string]Severity
+}
+
+// New creates a Linter with the given rules, or DefaultRules if none given
+func New(rules ...Rule) *Linter {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	return &Linter{Rules: rules, Severities: make(map[string]Severity)}
+}
+
+// Lint runs every rule over the zone, returning all the findings sorted by
+// severity, most severe first, and then by name and rule
+func (l *Linter) Lint(origin string, r records.Records) []Finding {
+	zone := Zone{Origin: origin, Records: r}
+
+	findings := make([]Finding, 0)
+	for _, rule := range l.Rules {
+		for _, finding := range rule.Check(zone) {
+			finding.Rule = rule.ID()
+			if severity, ok := l.Severities[rule.ID()]; ok {
+				finding.Severity = severity
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		if findings[i].Name != findings[j].Name {
+			return findings[i].Name < findings[j].Name
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+
+	return findings
+}
+
+// DefaultRules returns all the rules available in this package, checking
+// records against the default values Njalla accepts
+func DefaultRules() []Rule {
+	return DefaultRulesWith(structures.DefaultCapabilities())
+}
+
+// DefaultRulesWith is the same as DefaultRules, but records are checked
+// against the given Capabilities, such as those returned by
+// Provider.GetCapabilities
+func DefaultRulesWith(caps structures.Capabilities) []Rule {
+	return []Rule{
+		ValidRecords{Capabilities: caps},
+		CNAMEExclusive{},
+		TargetIsCNAME{},
+		DuplicateRecords{},
+		SPFLookupLimit{},
+		MultipleSPF{},
+	}
+}
+
+// sortedNames returns the keys of a map grouped by name in a deterministic
+// order
+func sortedNames(grouped map[string]records.Records) []string {
+	names := make([]string, 0, len(grouped))
+	for name := range grouped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,139 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+func findingRules(findings []Finding) []string {
+	rules := make([]string, len(findings))
+	for i, finding := range findings {
+		rules[i] = finding.Rule + " " + finding.Name
+	}
+	return rules
+}
+
+func TestCleanZoneHasNoFindings(t *testing.T) {
+	r := records.Records{
+		&records.RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&records.RecordCNAME{
+			ID: 2, Type: "CNAME", Name: "www", Content: "example.com", TTL: 10800,
+		},
+		&records.RecordMX{
+			ID: 3, Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+			TTL: 10800, Priority: 10,
+		},
+		&records.RecordTXT{
+			ID: 4, Type: "TXT", Name: "@",
+			Content: "v=spf1 include:_spf.protonmail.ch mx ~all", TTL: 10800,
+		},
+	}
+
+	findings := New().Lint("example.com", r)
+	if len(findings) != 0 {
+		t.Errorf("Expected no findings, got %+v", findings)
+	}
+}
+
+func TestBrokenZone(t *testing.T) {
+	r := records.Records{
+		&records.RecordCNAME{
+			ID: 1, Type: "CNAME", Name: "www", Content: "example.org", TTL: 10800,
+		},
+		&records.RecordA{
+			ID: 2, Type: "A", Name: "www", Content: "1.1.1.1", TTL: 10800,
+		},
+		&records.RecordCNAME{
+			ID: 3, Type: "CNAME", Name: "mail", Content: "example.org", TTL: 10800,
+		},
+		&records.RecordMX{
+			ID: 4, Type: "MX", Name: "@", Content: "mail.example.com",
+			TTL: 10800, Priority: 10,
+		},
+		&records.RecordTXT{
+			ID: 5, Type: "TXT", Name: "@", Content: "v=spf1 mx -all", TTL: 10800,
+		},
+		&records.RecordTXT{
+			ID: 6, Type: "TXT", Name: "@",
+			Content: "v=spf1 include:a include:b include:c include:d " +
+				"include:e include:f a mx ptr exists:g redirect=h",
+			TTL: 10800,
+		},
+		&records.RecordA{
+			ID: 7, Type: "A", Name: "dup", Content: "1.1.1.1", TTL: 10800,
+		},
+		&records.RecordA{
+			ID: 8, Type: "A", Name: "dup", Content: "1.1.1.1", TTL: 10800,
+		},
+	}
+
+	findings := New().Lint("example.com", r)
+
+	expected := []string{
+		"multiple-spf example.com",
+		"spf-lookup-limit example.com",
+		"target-is-cname example.com",
+		"cname-exclusive www.example.com",
+		"duplicate-records dup.example.com",
+	}
+
+	if got := findingRules(findings); !cmp.Equal(expected, got) {
+		t.Errorf("Findings don't match:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestSeverityOverride(t *testing.T) {
+	r := records.Records{
+		&records.RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&records.RecordA{ID: 2, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+	}
+
+	linter := New(DuplicateRecords{})
+	linter.Severities["duplicate-records"] = Error
+
+	findings := linter.Lint("example.com", r)
+	if len(findings) != 1 || findings[0].Severity != Error {
+		t.Errorf("Severity wasn't overridden: %+v", findings)
+	}
+}
+
+func TestValidRecordsWithCapabilities(t *testing.T) {
+	r := records.Records{
+		&records.RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 43200},
+		&records.RecordA{ID: 2, Type: "A", Name: "www", Content: "1.1.1.1", TTL: 10800},
+	}
+
+	expected := []string{"valid-record example.com"}
+	findings := New().Lint("example.com", r)
+	if got := findingRules(findings); !cmp.Equal(expected, got) {
+		t.Errorf("Findings don't match:\n%s", cmp.Diff(expected, got))
+	}
+
+	caps := structures.DefaultCapabilities()
+	caps.TTLs = []int{43200}
+
+	expected = []string{"valid-record www.example.com"}
+	findings = New(DefaultRulesWith(caps)...).Lint("example.com", r)
+	if got := findingRules(findings); !cmp.Equal(expected, got) {
+		t.Errorf("Findings don't match:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestCountSPFLookups(t *testing.T) {
+	cases := map[string]int{
+		"v=spf1 -all":                              0,
+		"v=spf1 a mx ip4:1.1.1.1 ~all":             2,
+		"v=spf1 +a:example.com -mx/24 ?all":        2,
+		"v=spf1 include:x exists:y redirect=z ptr": 4,
+	}
+
+	for content, expected := range cases {
+		if got := countSPFLookups(content); got != expected {
+			t.Errorf("%s: expected %d lookups, got %d", content, expected, got)
+		}
+	}
+}
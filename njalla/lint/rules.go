@@ -0,0 +1,273 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// ValidRecords reports records Njalla wouldn't accept, such as those with a
+// TTL or priority missing from the Capabilities
+type ValidRecords struct {
+	Capabilities structures.Capabilities
+}
+
+// ID of the rule
+func (ValidRecords) ID() string {
+	return "valid-record"
+}
+
+// Check the zone
+func (v ValidRecords) Check(zone Zone) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, record := range zone.Records {
+		err := record.ValidateWith(v.Capabilities)
+		if err == nil {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Severity: Error,
+			Name:     zone.FQDN(record.GetName()),
+			Message:  fmt.Sprintf("%s record isn't valid: %s", record.GetType(), err),
+			Records:  records.Records{record},
+		})
+	}
+
+	return findings
+}
+
+// CNAMEExclusive reports CNAME records sharing a name with any other record.
+// A CNAME must be the only record at its name
+type CNAMEExclusive struct{}
+
+// ID of the rule
+func (CNAMEExclusive) ID() string {
+	return "cname-exclusive"
+}
+
+// Check the zone
+func (CNAMEExclusive) Check(zone Zone) []Finding {
+	findings := make([]Finding, 0)
+	grouped := zone.ByName()
+
+	for _, name := range sortedNames(grouped) {
+		group := grouped[name]
+		cnames := 0
+		for _, record := range group {
+			if record.GetType() == "CNAME" {
+				cnames++
+			}
+		}
+
+		if cnames == 0 || len(group) == 1 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Severity: Error,
+			Name:     name,
+			Message: fmt.Sprintf(
+				"CNAME shares its name with %d other record(s)", len(group)-1,
+			),
+			Records: group,
+		})
+	}
+
+	return findings
+}
+
+// TargetIsCNAME reports MX and SRV records whose target is a CNAME in the
+// same zone. Those targets must point to address records directly
+type TargetIsCNAME struct{}
+
+// ID of the rule
+func (TargetIsCNAME) ID() string {
+	return "target-is-cname"
+}
+
+// Check the zone
+func (TargetIsCNAME) Check(zone Zone) []Finding {
+	cnames := make(map[string]bool)
+	for _, record := range zone.Records {
+		if record.GetType() == "CNAME" {
+			cnames[zone.FQDN(record.GetName())] = true
+		}
+	}
+
+	findings := make([]Finding, 0)
+	for _, record := range zone.Records {
+		if record.GetType() != "MX" && record.GetType() != "SRV" {
+			continue
+		}
+
+		target := zone.Target(record.GetContent())
+		if !cnames[target] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Severity: Error,
+			Name:     zone.FQDN(record.GetName()),
+			Message: fmt.Sprintf(
+				"%s target %s is a CNAME", record.GetType(), target,
+			),
+			Records: records.Records{record},
+		})
+	}
+
+	return findings
+}
+
+// DuplicateRecords reports records with the same type, name and content
+type DuplicateRecords struct{}
+
+// ID of the rule
+func (DuplicateRecords) ID() string {
+	return "duplicate-records"
+}
+
+// Check the zone
+func (DuplicateRecords) Check(zone Zone) []Finding {
+	seen := make(map[string]records.Records)
+	order := make([]string, 0)
+
+	for _, record := range zone.Records {
+		values := record.GetURLValues()
+		values.Del("id")
+		values.Set("name", zone.FQDN(record.GetName()))
+
+		key := values.Encode()
+		if _, exists := seen[key]; !exists {
+			order = append(order, key)
+		}
+		seen[key] = append(seen[key], record)
+	}
+
+	findings := make([]Finding, 0)
+	for _, key := range order {
+		group := seen[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Severity: Warning,
+			Name:     zone.FQDN(group[0].GetName()),
+			Message: fmt.Sprintf(
+				"%d identical %s records", len(group), group[0].GetType(),
+			),
+			Records: group,
+		})
+	}
+
+	return findings
+}
+
+// SPFLookupLimit reports SPF records needing more than 10 DNS lookups, which
+// RFC 7208 treats as a permanent error. Only the terms of the record itself
+// are counted, as `include`d records aren't resolved, so the real count can
+// only be higher
+type SPFLookupLimit struct{}
+
+// ID of the rule
+func (SPFLookupLimit) ID() string {
+	return "spf-lookup-limit"
+}
+
+// Check the zone
+func (SPFLookupLimit) Check(zone Zone) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, record := range zone.Records {
+		if !isSPF(record) {
+			continue
+		}
+
+		lookups := countSPFLookups(record.GetContent())
+		if lookups <= 10 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Severity: Error,
+			Name:     zone.FQDN(record.GetName()),
+			Message: fmt.Sprintf(
+				"SPF record needs at least %d DNS lookups, more than 10",
+				lookups,
+			),
+			Records: records.Records{record},
+		})
+	}
+
+	return findings
+}
+
+// MultipleSPF reports names with more than one SPF record, which RFC 7208
+// treats as a permanent error
+type MultipleSPF struct{}
+
+// ID of the rule
+func (MultipleSPF) ID() string {
+	return "multiple-spf"
+}
+
+// Check the zone
+func (MultipleSPF) Check(zone Zone) []Finding {
+	findings := make([]Finding, 0)
+	grouped := zone.ByName()
+
+	for _, name := range sortedNames(grouped) {
+		spf := make(records.Records, 0)
+		for _, record := range grouped[name] {
+			if isSPF(record) {
+				spf = append(spf, record)
+			}
+		}
+
+		if len(spf) < 2 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Severity: Error,
+			Name:     name,
+			Message:  fmt.Sprintf("%d SPF records at the same name", len(spf)),
+			Records:  spf,
+		})
+	}
+
+	return findings
+}
+
+func isSPF(record records.Record) bool {
+	if record.GetType() != "TXT" {
+		return false
+	}
+
+	content := strings.ToLower(strings.TrimSpace(record.GetContent()))
+	return content == "v=spf1" || strings.HasPrefix(content, "v=spf1 ")
+}
+
+// countSPFLookups counts the terms of an SPF record that trigger a DNS
+// lookup, as defined in RFC 7208 section 4.6.4
+func countSPFLookups(content string) int {
+	lookups := 0
+	for _, term := range strings.Fields(strings.ToLower(content)) {
+		term = strings.TrimLeft(term, "+-~?")
+
+		name := term
+		if i := strings.IndexAny(term, ":/="); i != -1 {
+			name = term[:i]
+		}
+
+		switch name {
+		case "include", "a", "mx", "ptr", "exists", "redirect":
+			lookups++
+		}
+	}
+	return lookups
+}
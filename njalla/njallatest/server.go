@@ -0,0 +1,403 @@
+// Package njallatest provides an in-memory fake of Njalla's website, with
+// just enough of the sign in, domains and records pages for
+// provider.Provider to work against it in tests
+package njallatest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// numericFields are the record fields Njalla stores as numbers
+var numericFields = map[string]bool{
+	"ttl": true, "prio": true, "weight": true, "port": true,
+	"ssh_algorithm": true, "ssh_type": true,
+}
+
+// Server is a fake Njalla website. Only the account with Email and Password
+// can sign in
+type Server struct {
+	*httptest.Server
+	Email    string
+	Password string
+	// Capabilities are the values offered by the add record forms, and the
+	// only ones added records may use
+	Capabilities structures.Capabilities
+
+	mu       sync.Mutex
+	domains  map[string]records.Records
+	sessions map[string]bool
+	nextID   int
+	posts    int
+}
+
+// NewServer starts a fake Njalla website with no domains. Close it when done
+func NewServer(email, password string) *Server {
+	s := &Server{
+		Email:        email,
+		Password:     password,
+		Capabilities: structures.DefaultCapabilities(),
+		domains:      make(map[string]records.Records),
+		sessions:     make(map[string]bool),
+		nextID:       1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/signin/", s.handleSignin)
+	mux.HandleFunc("/domains/", s.handleDomains)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// AddDomain registers a domain in the account with the given records. Records
+// without an ID get one assigned
+func (s *Server) AddDomain(domain string, r ...records.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make(records.Records, 0, len(r))
+	for _, record := range r {
+		stored = append(stored, s.withID(record))
+	}
+	s.domains[domain] = stored
+}
+
+// Records returns a copy of the current records of a domain
+func (s *Server) Records(domain string) records.Records {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := make(records.Records, 0, len(s.domains[domain]))
+	for _, record := range s.domains[domain] {
+		copied = append(copied, record.Clone())
+	}
+	return copied
+}
+
+// Posts returns how many add and update requests the server has handled
+func (s *Server) Posts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.posts
+}
+
+// Login returns a Provider pointed at the server and logged in
+func (s *Server) Login() (*provider.Provider, error) {
+	p, err := provider.New()
+	if err != nil {
+		return nil, err
+	}
+	p.BaseURL = s.URL
+
+	if err := p.Login(s.Email, s.Password); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Server) handleSignin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		token := randomToken()
+		http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: token, Path: "/"})
+		fmt.Fprintf(
+			w, `<html><body><form method="post">
+<input type="hidden" name="csrfmiddlewaretoken" value="%s">
+<input name="email"><input name="password" type="password">
+</form></body></html>`, token,
+		)
+
+	case http.MethodPost:
+		if !validCSRF(r) {
+			http.Error(w, "CSRF verification failed", http.StatusForbidden)
+			return
+		}
+		if r.FormValue("email") != s.Email ||
+			r.FormValue("password") != s.Password {
+			http.Error(w, "Wrong email or password", http.StatusUnauthorized)
+			return
+		}
+
+		session := randomToken()
+		s.mu.Lock()
+		s.sessions[session] = true
+		s.mu.Unlock()
+
+		http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: session, Path: "/"})
+		fmt.Fprint(w, "<html><body>Signed in</body></html>")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleDomains(w http.ResponseWriter, r *http.Request) {
+	if !s.signedIn(r) {
+		http.Redirect(w, r, "/signin/", http.StatusFound)
+		return
+	}
+
+	domain := strings.Trim(strings.TrimPrefix(r.URL.Path, "/domains/"), "/")
+	if domain == "" {
+		s.listDomains(w)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.domains[domain]; !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := json.Marshal(s.domains[domain])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(
+			w, "<html><body>%s<script>\nvar records = %s;\n</script></body></html>",
+			s.addForm(), data,
+		)
+
+	case http.MethodPost:
+		if !validCSRF(r) {
+			http.Error(w, "CSRF verification failed", http.StatusForbidden)
+			return
+		}
+
+		var err error
+		switch r.FormValue("action") {
+		case "add":
+			err = s.add(domain, r.PostForm)
+		case "update":
+			err = s.update(domain, r.FormValue("records"))
+		default:
+			err = fmt.Errorf("Unknown action %q", r.FormValue("action"))
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.posts++
+		fmt.Fprint(w, "{}")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) listDomains(w http.ResponseWriter) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.domains))
+	for domain := range s.domains {
+		names = append(names, domain)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	fmt.Fprint(w, `<html><body><table class="table">`)
+	for _, domain := range names {
+		escaped := html.EscapeString(domain)
+		fmt.Fprintf(
+			w, `<tr><td>%s</td><td><a href="/domains/%s/">Manage</a></td></tr>`,
+			escaped, escaped,
+		)
+	}
+	fmt.Fprint(w, "</table></body></html>")
+}
+
+// addForm renders the add record forms with the selects of the Capabilities
+func (s *Server) addForm() string {
+	type selectValues struct {
+		name   string
+		values []int
+	}
+	forms := []struct {
+		id      string
+		selects []selectValues
+	}{
+		{"add-MX", []selectValues{
+			{"ttl", s.Capabilities.TTLs},
+			{"prio", s.Capabilities.Priorities},
+		}},
+		{"add-Redirect", []selectValues{
+			{"prio", s.Capabilities.RedirectTypes},
+		}},
+		{"add-SSHFP", []selectValues{
+			{"ttl", s.Capabilities.TTLs},
+			{"ssh_algorithm", s.Capabilities.SSHAlgorithms},
+			{"ssh_type", s.Capabilities.SSHTypes},
+		}},
+	}
+
+	var page strings.Builder
+	for _, form := range forms {
+		fmt.Fprintf(&page, `<form id="%s">`, form.id)
+		for _, sel := range form.selects {
+			fmt.Fprintf(&page, `<select name="%s">`, sel.name)
+			for _, value := range sel.values {
+				fmt.Fprintf(
+					&page, `<option value="%d">%d</option>`, value, value,
+				)
+			}
+			page.WriteString("</select>")
+		}
+		page.WriteString("</form>")
+	}
+	return page.String()
+}
+
+// add stores a new record from the fields of an add request
+func (s *Server) add(domain string, form url.Values) error {
+	values := url.Values{}
+	for key := range form {
+		switch key {
+		case "action", "csrfmiddlewaretoken", "id":
+			continue
+		}
+		values.Set(key, form.Get(key))
+	}
+
+	record, err := recordFromValues(s.nextID, values)
+	if err != nil {
+		return err
+	}
+	if err := record.ValidateWith(s.Capabilities); err != nil {
+		return err
+	}
+
+	s.nextID++
+	s.domains[domain] = append(s.domains[domain], record)
+	return nil
+}
+
+// update replaces the records of a domain with those of an update request.
+// Like Njalla, records missing from the request are removed
+func (s *Server) update(domain string, payload string) error {
+	decoded, err := records.DecodeUpdatePayload([]byte(payload))
+	if err != nil {
+		return err
+	}
+
+	current := s.domains[domain]
+	for id := range decoded {
+		if _, exists := current.FindByID(id); !exists {
+			return fmt.Errorf("Record %d doesn't exist", id)
+		}
+	}
+
+	updated := make(records.Records, 0, len(decoded))
+	for _, stored := range current {
+		values, exists := decoded[stored.GetID()]
+		if !exists {
+			continue
+		}
+
+		values.Set("type", stored.GetType())
+		record, err := recordFromValues(stored.GetID(), values)
+		if err != nil {
+			return err
+		}
+		updated = append(updated, record)
+	}
+
+	s.domains[domain] = updated
+	return nil
+}
+
+// withID returns the record with an ID assigned if it had none
+func (s *Server) withID(record records.Record) records.Record {
+	values := record.GetURLValues()
+	values.Del("id")
+
+	id := record.GetID()
+	if id == 0 {
+		id = s.nextID
+		s.nextID++
+	} else if id >= s.nextID {
+		s.nextID = id + 1
+	}
+
+	stored, err := recordFromValues(id, values)
+	if err != nil {
+		panic(err)
+	}
+	return stored
+}
+
+func (s *Server) signedIn(r *http.Request) bool {
+	cookie, err := r.Cookie("sessionid")
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[cookie.Value]
+}
+
+// recordFromValues builds a record the same way GetRecords would parse it
+// from Njalla's JSON
+func recordFromValues(id int, values url.Values) (records.Record, error) {
+	obj := map[string]interface{}{"id": id}
+	for key := range values {
+		value := values.Get(key)
+		if !numericFields[key] {
+			obj[key] = value
+			continue
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Field %s is not a number: %q", key, value)
+		}
+		obj[key] = number
+	}
+
+	data, err := json.Marshal([]interface{}{obj})
+	if err != nil {
+		return nil, err
+	}
+
+	var r records.Records
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return r[0], nil
+}
+
+func validCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie("csrftoken")
+	if err != nil {
+		return false
+	}
+	return cookie.Value != "" && r.FormValue("csrfmiddlewaretoken") == cookie.Value
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
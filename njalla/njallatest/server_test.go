@@ -0,0 +1,113 @@
+package njallatest
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func TestProviderRoundTrip(t *testing.T) {
+	server := NewServer("user@example.com", "secret")
+	defer server.Close()
+
+	server.AddDomain("example.com", &records.RecordA{
+		ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800,
+	})
+	server.AddDomain("example.org")
+
+	p, err := server.Login()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	domains, err := p.GetDomains()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if expected := []string{"example.com", "example.org"}; !cmp.Equal(expected, domains) {
+		t.Errorf("Domains don't match:\n%s", cmp.Diff(expected, domains))
+	}
+
+	txt, _ := records.NewRecordTXT("www", "hello", 300)
+	if err := p.AddRecord("example.com", txt); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	stored, err := p.GetRecords("example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	added := stored.Filter(records.ByType("TXT"))
+	if len(stored) != 2 || len(added) != 1 || added[0].GetID() == 0 {
+		t.Fatalf("Record wasn't added:\n%s", stored)
+	}
+
+	updated := added[0].WithContent("bye")
+	if err := p.UpdateRecordTyped("example.com", updated); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.RemoveRecord("example.com", 1); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := records.Records{&records.RecordTXT{
+		ID: added[0].GetID(), Type: "TXT", Name: "www", Content: "bye", TTL: 300,
+	}}
+	if got := server.Records("example.com"); !cmp.Equal(expected, got) {
+		t.Errorf("Records don't match:\n%s", cmp.Diff(expected, got))
+	}
+
+	if server.Posts() != 3 {
+		t.Errorf("Expected 3 posts, got %d", server.Posts())
+	}
+}
+
+func TestLoginRequired(t *testing.T) {
+	server := NewServer("user@example.com", "secret")
+	defer server.Close()
+
+	p, err := provider.New()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	p.BaseURL = server.URL
+
+	if err := p.Login("user@example.com", "wrong"); err == nil {
+		t.Errorf("Login with a wrong password should fail")
+	}
+
+	if loggedIn, err := p.LoggedIn(); err != nil || loggedIn {
+		t.Errorf("Expected to be logged out, got %t, %v", loggedIn, err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	server := NewServer("user@example.com", "secret")
+	defer server.Close()
+	server.Capabilities.TTLs = []int{60, 43200}
+	server.AddDomain("example.com")
+
+	p, err := server.Login()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	caps, err := p.GetCapabilities("example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !cmp.Equal(server.Capabilities, caps) {
+		t.Errorf("Capabilities don't match:\n%s", cmp.Diff(server.Capabilities, caps))
+	}
+
+	a := &records.RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300}
+	if err := p.AddRecord("example.com", a); err == nil {
+		t.Errorf("A TTL missing from the capabilities was accepted")
+	}
+	if err := p.AddRecord("example.com", a.WithTTL(43200)); err != nil {
+		t.Errorf("%s", err)
+	}
+}
@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Denial is an attempt a policy didn't allow
+type Denial struct {
+	Time time.Time `json:"time"`
+	// Principal is the caller, see Policy
+	Principal string `json:"principal"`
+	// Frontend is how the attempt came in, such as `api` or `rfc2136`
+	Frontend  string    `json:"frontend,omitempty"`
+	Operation Operation `json:"operation"`
+	Domain    string    `json:"domain"`
+	// Name and Type of the record, if the attempt was on a record
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	RecordID int    `json:"record_id,omitempty"`
+	// Source is the address of the caller, if known
+	Source string `json:"source,omitempty"`
+}
+
+func (d Denial) String() string {
+	target := d.Domain
+	if d.Name != "" || d.Type != "" {
+		target = fmt.Sprintf("%s %s in %s", d.Type, d.Name, d.Domain)
+	}
+	return fmt.Sprintf("%s may not %s %s", d.Principal, d.Operation, target)
+}
+
+// DeniedError is returned by PolicyProvider for the attempts its policy
+// doesn't allow
+type DeniedError struct {
+	Denial Denial
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("Denied: %s", e.Denial)
+}
+
+// IsDenied returns true for the errors of denied attempts
+func IsDenied(err error) bool {
+	_, ok := err.(*DeniedError)
+	return ok
+}
+
+// Auditor records the denied attempts
+type Auditor interface {
+	Deny(d Denial)
+}
+
+// JSONAuditor writes every denial to a writer, as a line of JSON
+type JSONAuditor struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONAuditor returns a JSONAuditor writing to w
+func NewJSONAuditor(w io.Writer) *JSONAuditor {
+	return &JSONAuditor{w: w}
+}
+
+// Deny writes the denial. Write errors are ignored, since a denied attempt
+// is already refused either way
+func (a *JSONAuditor) Deny(d Denial) {
+	if d.Time.IsZero() {
+		d.Time = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,197 @@
+// Package policy limits what a caller may do with the records of a Njalla
+// account. A Policy is a list of rules granting operations on some domains,
+// names and record types, and a PolicyProvider enforces one on top of
+// provider.Provider, writing every denied attempt to an Auditor
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// Operation is something a rule may grant on records
+type Operation string
+
+// Operations a rule may grant
+const (
+	Read   Operation = "read"
+	Add    Operation = "add"
+	Update Operation = "update"
+	Delete Operation = "delete"
+)
+
+// Operations are all the operations, as granted by a rule without any
+var Operations = []Operation{Read, Add, Update, Delete}
+
+// Rule grants its operations on the records matching all its fields. An
+// empty field matches anything, so an empty rule grants everything
+type Rule struct {
+	// Domains are path.Match patterns on the domain, such as `*.org`
+	Domains []string `yaml:"domains" json:"domains,omitempty"`
+	// Names are path.Match patterns on the fully qualified name of the
+	// record, without the trailing dot, such as `_acme-challenge.*`. The
+	// domain itself is the name of its @ records
+	Names []string `yaml:"names" json:"names,omitempty"`
+	// Types are record types, such as TXT
+	Types []string `yaml:"types" json:"types,omitempty"`
+	// Operations granted, all of them if empty
+	Operations []Operation `yaml:"operations" json:"operations,omitempty"`
+}
+
+// Validate checks the patterns and operations of the rule
+func (r Rule) Validate() error {
+	patterns := append(append([]string{}, r.Domains...), r.Names...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid pattern %s: %s", pattern, err)
+		}
+	}
+
+	for _, op := range r.Operations {
+		switch op {
+		case Read, Add, Update, Delete:
+		default:
+			return fmt.Errorf(
+				"Unknown operation %s, use read, add, update or delete", op,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (r Rule) grants(op Operation) bool {
+	if len(r.Operations) == 0 {
+		return true
+	}
+	for _, granted := range r.Operations {
+		if granted == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesDomain(domain string) bool {
+	return matchesAny(r.Domains, normalize(domain))
+}
+
+func (r Rule) matchesRecord(domain, name, recordType string) bool {
+	if !matchesAny(r.Names, FQDN(domain, name)) {
+		return false
+	}
+
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, allowed := range r.Types {
+		if strings.EqualFold(allowed, recordType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is what a caller, such as an API token, may do
+type Policy struct {
+	// Principal names the caller in the audit entries
+	Principal string
+	Rules     []Rule
+}
+
+// New returns the policy of a principal, checking its rules
+func New(principal string, rules []Rule) (*Policy, error) {
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("Rule %d of %s: %s", i, principal, err)
+		}
+	}
+
+	return &Policy{Principal: principal, Rules: rules}, nil
+}
+
+// AllowsDomain returns true if some rule grants the operation on some of the
+// records of the domain
+func (p *Policy) AllowsDomain(op Operation, domain string) bool {
+	for _, rule := range p.Rules {
+		if rule.grants(op) && rule.matchesDomain(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAny returns true if some rule grants any operation on some of the
+// records of the domain
+func (p *Policy) AllowsAny(domain string) bool {
+	for _, op := range Operations {
+		if p.AllowsDomain(op, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsName returns true if some rule matches the domain and the fully
+// qualified name, whatever the operation and type. The name doesn't have to
+// be in the domain, so names outside of it can be told apart from names
+// the policy doesn't allow
+func (p *Policy) AllowsName(domain, fqdn string) bool {
+	for _, rule := range p.Rules {
+		if rule.matchesDomain(domain) && matchesAny(rule.Names, normalize(fqdn)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns true if some rule grants the operation on a record with the
+// name, relative to the domain as in Njalla, and type
+func (p *Policy) Allows(op Operation, domain, name, recordType string) bool {
+	for _, rule := range p.Rules {
+		if rule.grants(op) && rule.matchesDomain(domain) &&
+			rule.matchesRecord(domain, name, recordType) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRecord is the same as Allows, taking the name and type of a record
+func (p *Policy) AllowsRecord(
+	op Operation, domain string, record records.Record,
+) bool {
+	return p.Allows(op, domain, record.GetName(), record.GetType())
+}
+
+// FQDN returns the fully qualified name, lower cased and without the
+// trailing dot, of a record name relative to the domain
+func FQDN(domain, name string) string {
+	name = records.CanonicalName(domain, name)
+	if name == "@" {
+		return normalize(domain)
+	}
+	return name + "." + normalize(domain)
+}
+
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// matchesAny returns true if the value matches one of the patterns, or if
+// there are none
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(normalize(pattern), value); err == nil &&
+			matched {
+			return true
+		}
+	}
+	return false
+}
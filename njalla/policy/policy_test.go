@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func TestAllows(t *testing.T) {
+	p, err := New("certbot", []Rule{
+		{
+			Domains:    []string{"example.com"},
+			Names:      []string{"_acme-challenge.*"},
+			Types:      []string{"txt"},
+			Operations: []Operation{Read, Add, Delete},
+		},
+		{Domains: []string{"*.org"}, Operations: []Operation{Read}},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	tests := []struct {
+		op                       Operation
+		domain, name, recordType string
+		expected                 bool
+	}{
+		{Add, "example.com", "_acme-challenge", "TXT", true},
+		{Add, "Example.com.", "_acme-challenge.www", "TXT", true},
+		{Delete, "example.com", "_acme-challenge.example.com.", "TXT", true},
+		{Update, "example.com", "_acme-challenge", "TXT", false},
+		{Add, "example.com", "_acme-challenge", "A", false},
+		{Add, "example.com", "www", "TXT", false},
+		{Add, "example.com", "@", "TXT", false},
+		{Add, "example.net", "_acme-challenge", "TXT", false},
+		{Read, "example.org", "www", "A", true},
+		{Delete, "example.org", "www", "A", false},
+	}
+
+	for _, test := range tests {
+		got := p.Allows(test.op, test.domain, test.name, test.recordType)
+		if got != test.expected {
+			t.Errorf(
+				"%s %s %s in %s: expected %v", test.op, test.recordType,
+				test.name, test.domain, test.expected,
+			)
+		}
+	}
+
+	if !p.AllowsDomain(Add, "example.com") || p.AllowsDomain(Add, "example.org") {
+		t.Errorf("Unexpected domain operations")
+	}
+}
+
+func TestEmptyRuleAllowsEverything(t *testing.T) {
+	p, _ := New("admin", []Rule{{}})
+	if !p.Allows(Delete, "example.com", "@", "NS") {
+		t.Errorf("Empty rule should allow everything")
+	}
+
+	p, _ = New("nobody", nil)
+	if p.Allows(Read, "example.com", "@", "NS") {
+		t.Errorf("No rules should allow nothing")
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Names: []string{"[invalid"}},
+		{Operations: []Operation{"wipe"}},
+	} {
+		if _, err := New("test", []Rule{rule}); err == nil {
+			t.Errorf("Expected an error for %+v", rule)
+		}
+	}
+}
+
+func newTestProvider(t *testing.T, rules []Rule) (
+	*njallatest.Server, *PolicyProvider, *bytes.Buffer,
+) {
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain(
+		"example.com",
+		&records.RecordA{Type: "A", Name: "www", Content: "1.1.1.1", TTL: 300},
+		&records.RecordTXT{
+			Type: "TXT", Name: "_acme-challenge", Content: "old", TTL: 60,
+		},
+	)
+	njalla.AddDomain("example.net")
+
+	client, err := njalla.Login()
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	p, err := New("certbot", rules)
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	audit := &bytes.Buffer{}
+	provider := NewPolicyProvider(client, p, NewJSONAuditor(audit))
+	provider.Frontend = "test"
+	provider.Source = "192.0.2.1"
+	return njalla, provider, audit
+}
+
+func TestPolicyProvider(t *testing.T) {
+	njalla, provider, audit := newTestProvider(t, []Rule{{
+		Domains: []string{"example.com"},
+		Names:   []string{"_acme-challenge.*"},
+		Types:   []string{"TXT"},
+	}})
+	defer njalla.Close()
+
+	domains, err := provider.GetDomains()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !cmp.Equal([]string{"example.com"}, domains) {
+		t.Errorf("Unexpected domains: %v", domains)
+	}
+
+	stored, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(stored) != 1 || stored[0].GetName() != "_acme-challenge" {
+		t.Errorf("Only the challenge should be readable: %s", stored)
+	}
+	challenge := stored[0]
+
+	if _, err := provider.GetRecords("example.net"); !IsDenied(err) {
+		t.Errorf("Expected reading example.net to be denied, got %v", err)
+	}
+
+	txt, _ := records.NewRecordTXT("_acme-challenge", "new", 60)
+	if err := provider.AddRecord("example.com", txt); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	a, _ := records.NewRecordA("_acme-challenge", "1.1.1.1", 60)
+	if err := provider.AddRecord("example.com", a); !IsDenied(err) {
+		t.Errorf("Expected adding an A record to be denied, got %v", err)
+	}
+
+	// Renaming out of the allowed names is denied
+	renamed := challenge.WithName("www")
+	if err := provider.UpdateRecordTyped("example.com", renamed); !IsDenied(err) {
+		t.Errorf("Expected renaming to be denied, got %v", err)
+	}
+
+	www := njalla.Records("example.com").Filter(records.ByName("www"))[0]
+	err = provider.RemoveRecords(
+		"example.com", []int{challenge.GetID(), www.GetID()},
+	)
+	if !IsDenied(err) {
+		t.Errorf("Expected removing www to be denied, got %v", err)
+	}
+	if len(njalla.Records("example.com")) != 3 {
+		t.Errorf("A partly denied removal removed records")
+	}
+
+	if err := provider.RemoveRecord("example.com", challenge.GetID()); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 audit entries, got:\n%s", audit)
+	}
+
+	var last Denial
+	if err := json.Unmarshal([]byte(lines[3]), &last); err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected := Denial{
+		Time: last.Time, Principal: "certbot", Frontend: "test",
+		Operation: Delete, Domain: "example.com", Name: "www", Type: "A",
+		RecordID: www.GetID(), Source: "192.0.2.1",
+	}
+	if !cmp.Equal(expected, last) {
+		t.Errorf("Audit entry doesn't match:\n%s", cmp.Diff(expected, last))
+	}
+}
@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// Client is the part of provider.Provider a PolicyProvider wraps
+type Client interface {
+	GetDomains() ([]string, error)
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	UpdateRecordTyped(domain string, record records.Record) error
+	RemoveRecords(domain string, recordIDs []int) error
+}
+
+// PolicyProvider has the same methods as provider.Provider, but only does
+// what its policy allows. Denied attempts return a *DeniedError, and are
+// written to the auditor, if any
+type PolicyProvider struct {
+	client  Client
+	policy  *Policy
+	auditor Auditor
+
+	// Frontend and Source are copied into the audit entries
+	Frontend string
+	Source   string
+}
+
+// NewPolicyProvider returns a PolicyProvider enforcing the policy on top of
+// the client. The auditor may be nil
+func NewPolicyProvider(
+	client Client, policy *Policy, auditor Auditor,
+) *PolicyProvider {
+	return &PolicyProvider{client: client, policy: policy, auditor: auditor}
+}
+
+// Policy returns the policy being enforced
+func (p *PolicyProvider) Policy() *Policy {
+	return p.policy
+}
+
+// GetDomains returns the domains the policy grants any operation on
+func (p *PolicyProvider) GetDomains() ([]string, error) {
+	domains, err := p.client.GetDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if p.policy.AllowsAny(domain) {
+			allowed = append(allowed, domain)
+		}
+	}
+	return allowed, nil
+}
+
+// GetRecords returns the records of the domain the policy allows reading.
+// It's denied if none could be read
+func (p *PolicyProvider) GetRecords(domain string) (records.Records, error) {
+	if !p.policy.AllowsDomain(Read, domain) {
+		return nil, p.deny(Denial{Operation: Read, Domain: domain})
+	}
+
+	stored, err := p.client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return stored.Filter(func(record records.Record) bool {
+		return p.policy.AllowsRecord(Read, domain, record)
+	}), nil
+}
+
+// GetCapabilities returns the Capabilities of the domain, if the client can
+// get them, or the defaults. The policy doesn't limit them, since they're
+// the same for every domain
+func (p *PolicyProvider) GetCapabilities(
+	domain string,
+) (structures.Capabilities, error) {
+	return records.CapabilitiesOf(p.client, domain)
+}
+
+// AddRecord adds the record if the policy allows adding it
+func (p *PolicyProvider) AddRecord(domain string, record records.Record) error {
+	if err := p.Check(Add, domain, record); err != nil {
+		return err
+	}
+
+	return p.client.AddRecord(domain, record)
+}
+
+// UpdateRecordTyped updates the record if the policy allows updating both
+// the record as stored and as it'd be afterwards, so a record can't be
+// renamed out of, or into, what the policy allows
+func (p *PolicyProvider) UpdateRecordTyped(
+	domain string, record records.Record,
+) error {
+	stored, err := p.client.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+
+	current, exists := stored.FindByID(record.GetID())
+	if !exists {
+		return fmt.Errorf(
+			"Record %d doesn't exist in %s", record.GetID(), domain,
+		)
+	}
+
+	for _, r := range []records.Record{current, record} {
+		if err := p.Check(Update, domain, r); err != nil {
+			return err
+		}
+	}
+
+	return p.client.UpdateRecordTyped(domain, record)
+}
+
+// RemoveRecord removes the record if the policy allows it
+func (p *PolicyProvider) RemoveRecord(domain string, recordID int) error {
+	return p.RemoveRecords(domain, []int{recordID})
+}
+
+// RemoveRecords removes the records only if the policy allows removing all
+// of them. Every denied one is audited
+func (p *PolicyProvider) RemoveRecords(domain string, recordIDs []int) error {
+	stored, err := p.client.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+
+	var denied error
+	for _, id := range recordIDs {
+		record, exists := stored.FindByID(id)
+		if !exists {
+			continue
+		}
+		if err := p.Check(Delete, domain, record); err != nil && denied == nil {
+			denied = err
+		}
+	}
+	if denied != nil {
+		return denied
+	}
+
+	return p.client.RemoveRecords(domain, recordIDs)
+}
+
+// Check returns a *DeniedError, after auditing it, if the policy doesn't
+// allow the operation on the record. Frontends use it to check a whole
+// change before making any part of it
+func (p *PolicyProvider) Check(
+	op Operation, domain string, record records.Record,
+) error {
+	if p.policy.AllowsRecord(op, domain, record) {
+		return nil
+	}
+	return p.deny(recordDenial(op, domain, record))
+}
+
+// Deny audits a denial decided by the frontend and returns its error
+func (p *PolicyProvider) Deny(d Denial) error {
+	return p.deny(d)
+}
+
+// deny audits the denial and returns its error
+func (p *PolicyProvider) deny(d Denial) error {
+	d.Time = time.Now().UTC()
+	d.Principal = p.policy.Principal
+	d.Frontend = p.Frontend
+	d.Source = p.Source
+
+	if p.auditor != nil {
+		p.auditor.Deny(d)
+	}
+	return &DeniedError{Denial: d}
+}
+
+func recordDenial(op Operation, domain string, record records.Record) Denial {
+	return Denial{
+		Operation: op,
+		Domain:    domain,
+		Name:      record.GetName(),
+		Type:      record.GetType(),
+		RecordID:  record.GetID(),
+	}
+}
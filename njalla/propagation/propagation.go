@@ -0,0 +1,394 @@
+// Package propagation waits for record changes to be served by every
+// authoritative name server of a zone, by querying them directly
+package propagation
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Expectation is what a name server must answer for a name and type. Every
+// record in Present must be in the answer, and none of those in Absent.
+// Records are compared ignoring their TTL
+type Expectation struct {
+	Name    string
+	Type    uint16
+	Present []dns.RR
+	Absent  []dns.RR
+}
+
+// Expect groups records to be added and removed into Expectations, one for
+// each name and type
+func Expect(present, absent []dns.RR) []Expectation {
+	expectations := make([]Expectation, 0)
+	index := make(map[string]int)
+
+	find := func(rr dns.RR) *Expectation {
+		header := rr.Header()
+		key := fmt.Sprintf(
+			"%s %d", strings.ToLower(dns.Fqdn(header.Name)), header.Rrtype,
+		)
+
+		i, exists := index[key]
+		if !exists {
+			i = len(expectations)
+			index[key] = i
+			expectations = append(expectations, Expectation{
+				Name: dns.Fqdn(header.Name), Type: header.Rrtype,
+			})
+		}
+		return &expectations[i]
+	}
+
+	for _, rr := range present {
+		e := find(rr)
+		e.Present = append(e.Present, rr)
+	}
+	for _, rr := range absent {
+		e := find(rr)
+		e.Absent = append(e.Absent, rr)
+	}
+
+	return expectations
+}
+
+// Nameserver is an authoritative name server of a zone
+type Nameserver struct {
+	Name    string
+	Address string
+}
+
+func (n Nameserver) String() string {
+	if n.Name == "" || n.Name == n.Address {
+		return n.Address
+	}
+	return fmt.Sprintf("%s (%s)", n.Name, n.Address)
+}
+
+// Report is the result of querying a name server once
+type Report struct {
+	Attempt    int
+	Nameserver Nameserver
+	// Ready is true once the name server answers as expected
+	Ready bool
+	// Err is set if the query failed, such as on a timeout
+	Err error
+}
+
+// Waiter checks name servers until they serve the expected records
+type Waiter struct {
+	// Resolvers are the recursive resolvers, as host:port, used to find the
+	// name servers of a zone
+	Resolvers []string
+	// Nameservers, as host:port, are queried instead of those found through
+	// the resolvers if set
+	Nameservers []string
+	// Port of the name servers found through the resolvers
+	Port string
+
+	// Timeout is how long to wait for every name server to be ready,
+	// querying them every Interval. Each query times out after QueryTimeout
+	Timeout      time.Duration
+	Interval     time.Duration
+	QueryTimeout time.Duration
+
+	// Progress, if set, is called with the result of every query
+	Progress func(Report)
+}
+
+// New returns a Waiter using the resolvers in /etc/resolv.conf, or
+// Cloudflare's if there's none
+func New() *Waiter {
+	resolvers := []string{"1.1.1.1:53"}
+	if conf, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil &&
+		len(conf.Servers) > 0 {
+		resolvers = make([]string, len(conf.Servers))
+		for i, server := range conf.Servers {
+			resolvers[i] = net.JoinHostPort(server, conf.Port)
+		}
+	}
+
+	return &Waiter{
+		Resolvers:    resolvers,
+		Port:         "53",
+		Timeout:      5 * time.Minute,
+		Interval:     5 * time.Second,
+		QueryTimeout: 5 * time.Second,
+	}
+}
+
+// FindNameservers looks up the NS records of a zone and the addresses of
+// each name server
+func (w *Waiter) FindNameservers(zone string) ([]Nameserver, error) {
+	if len(w.Nameservers) > 0 {
+		nameservers := make([]Nameserver, len(w.Nameservers))
+		for i, address := range w.Nameservers {
+			nameservers[i] = Nameserver{Name: address, Address: address}
+		}
+		return nameservers, nil
+	}
+
+	resp, err := w.resolve(dns.Fqdn(zone), dns.TypeNS)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Couldn't find the name servers of %s: %s", zone, err,
+		)
+	}
+
+	// Use the glue records when the resolver sends them
+	glue := make(map[string][]string)
+	for _, rr := range resp.Extra {
+		switch v := rr.(type) {
+		case *dns.A:
+			name := strings.ToLower(v.Hdr.Name)
+			glue[name] = append(glue[name], v.A.String())
+		case *dns.AAAA:
+			name := strings.ToLower(v.Hdr.Name)
+			glue[name] = append(glue[name], v.AAAA.String())
+		}
+	}
+
+	nameservers := make([]Nameserver, 0)
+	for _, rr := range resp.Answer {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		addresses, found := glue[strings.ToLower(ns.Ns)]
+		if !found {
+			addresses, err = w.lookupAddresses(ns.Ns)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, address := range addresses {
+			nameservers = append(nameservers, Nameserver{
+				Name: ns.Ns, Address: net.JoinHostPort(address, w.Port),
+			})
+		}
+	}
+
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("No name servers found for %s", zone)
+	}
+
+	sort.Slice(nameservers, func(i, j int) bool {
+		if nameservers[i].Name != nameservers[j].Name {
+			return nameservers[i].Name < nameservers[j].Name
+		}
+		return nameservers[i].Address < nameservers[j].Address
+	})
+
+	return nameservers, nil
+}
+
+// Wait queries every name server of the zone until all of them answer as
+// expected, or fails once Timeout passes. A name server with several
+// addresses is ready once any of them answers as expected, so an address
+// that can't be reached, such as an IPv6 one from a host without IPv6,
+// doesn't hold it back
+func (w *Waiter) Wait(zone string, expectations ...Expectation) error {
+	nameservers, err := w.FindNameservers(zone)
+	if err != nil {
+		return err
+	}
+
+	// Group the addresses of every name server, keeping their order
+	names := make([]string, 0)
+	addresses := make(map[string][]Nameserver)
+	for _, nameserver := range nameservers {
+		if _, exists := addresses[nameserver.Name]; !exists {
+			names = append(names, nameserver.Name)
+		}
+		addresses[nameserver.Name] = append(
+			addresses[nameserver.Name], nameserver,
+		)
+	}
+
+	deadline := time.Now().Add(w.Timeout)
+	pending := names
+
+	for attempt := 1; ; attempt++ {
+		waiting := make([]string, 0, len(pending))
+		for _, name := range pending {
+			if !w.checkAny(attempt, addresses[name], expectations) {
+				waiting = append(waiting, name)
+			}
+		}
+
+		pending = waiting
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if time.Now().Add(w.Interval).After(deadline) {
+			waited := make([]string, 0, len(pending))
+			for _, name := range pending {
+				for _, nameserver := range addresses[name] {
+					waited = append(waited, nameserver.String())
+				}
+			}
+			return fmt.Errorf(
+				"Timed out after %s waiting for %s", w.Timeout,
+				strings.Join(waited, ", "),
+			)
+		}
+
+		time.Sleep(w.Interval)
+	}
+}
+
+// checkAny checks the addresses of a name server in turn, returning true as
+// soon as one of them answers as expected. Every query is reported
+func (w *Waiter) checkAny(
+	attempt int, nameservers []Nameserver, expectations []Expectation,
+) bool {
+	for _, nameserver := range nameservers {
+		ready, err := w.check(nameserver, expectations)
+		if w.Progress != nil {
+			w.Progress(Report{
+				Attempt: attempt, Nameserver: nameserver, Ready: ready,
+				Err: err,
+			})
+		}
+
+		if ready {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns true if a name server answers as expected for every
+// expectation
+func (w *Waiter) check(
+	nameserver Nameserver, expectations []Expectation,
+) (bool, error) {
+	for _, expectation := range expectations {
+		answer, err := w.query(
+			nameserver.Address, expectation.Name, expectation.Type,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		for _, rr := range expectation.Present {
+			if !contains(answer, rr) {
+				return false, nil
+			}
+		}
+		for _, rr := range expectation.Absent {
+			if contains(answer, rr) {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// query asks a name server directly, over UDP and then TCP if the answer
+// doesn't fit, returning the answer records of the name and type
+func (w *Waiter) query(address, name string, qtype uint16) ([]dns.RR, error) {
+	name = dns.Fqdn(name)
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+
+	client := &dns.Client{Net: "udp", Timeout: w.QueryTimeout}
+	resp, _, err := client.Exchange(m, address)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(m, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf(
+			"%s answered %s", address, dns.RcodeToString[resp.Rcode],
+		)
+	}
+
+	answer := make([]dns.RR, 0, len(resp.Answer))
+	for _, rr := range resp.Answer {
+		header := rr.Header()
+		if header.Rrtype == qtype && strings.EqualFold(header.Name, name) {
+			answer = append(answer, rr)
+		}
+	}
+	return answer, nil
+}
+
+// resolve asks the recursive resolvers in turn until one answers
+func (w *Waiter) resolve(name string, qtype uint16) (*dns.Msg, error) {
+	if len(w.Resolvers) == 0 {
+		return nil, fmt.Errorf("No resolvers configured")
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+
+	client := &dns.Client{Timeout: w.QueryTimeout}
+
+	var lastErr error
+	for _, resolver := range w.Resolvers {
+		resp, _, err := client.Exchange(m, resolver)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf(
+				"%s answered %s", resolver, dns.RcodeToString[resp.Rcode],
+			)
+			continue
+		}
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// lookupAddresses returns the IPv4 and IPv6 addresses of a host
+func (w *Waiter) lookupAddresses(host string) ([]string, error) {
+	addresses := make([]string, 0)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := w.resolve(dns.Fqdn(host), qtype)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't resolve %s: %s", host, err)
+		}
+
+		for _, rr := range resp.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				addresses = append(addresses, v.A.String())
+			case *dns.AAAA:
+				addresses = append(addresses, v.AAAA.String())
+			}
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", host)
+	}
+	return addresses, nil
+}
+
+func contains(answer []dns.RR, rr dns.RR) bool {
+	for _, candidate := range answer {
+		if dns.IsDuplicate(candidate, rr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package propagation
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testServer is an in-process DNS server answering from a mutable set of
+// records, over UDP and TCP on the same port
+type testServer struct {
+	Address string
+
+	mu      sync.Mutex
+	records []dns.RR
+	queries map[string]int
+
+	servers []*dns.Server
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{queries: make(map[string]int)}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.Address = udp.LocalAddr().String()
+
+	tcp, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		udp.Close()
+		t.Fatalf("%s", err)
+	}
+
+	handler := dns.HandlerFunc(s.serveDNS)
+	for _, server := range []*dns.Server{
+		{PacketConn: udp, Handler: handler},
+		{Listener: tcp, Handler: handler},
+	} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go server.ActivateAndServe()
+		<-started
+		s.servers = append(s.servers, server)
+	}
+
+	return s
+}
+
+func (s *testServer) Close() {
+	for _, server := range s.servers {
+		server.Shutdown()
+	}
+}
+
+func (s *testServer) Set(records ...string) {
+	rrs := make([]dns.RR, len(records))
+	for i, record := range records {
+		rrs[i] = mustRR(record)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = rrs
+}
+
+func (s *testServer) Queries(network string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[network]
+}
+
+func (s *testServer) serveDNS(w dns.ResponseWriter, req *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	network := w.LocalAddr().Network()
+	s.queries[network]++
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+
+	question := req.Question[0]
+	for _, rr := range s.records {
+		header := rr.Header()
+		if header.Rrtype == question.Qtype &&
+			strings.EqualFold(header.Name, question.Name) {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+
+	// Glue for the name servers
+	if question.Qtype == dns.TypeNS {
+		for _, rr := range s.records {
+			if a, ok := rr.(*dns.A); ok && strings.HasPrefix(a.Hdr.Name, "ns") {
+				m.Extra = append(m.Extra, rr)
+			}
+		}
+	}
+
+	if network == "udp" {
+		m.Truncate(dns.MinMsgSize)
+	}
+	w.WriteMsg(m)
+}
+
+func mustRR(record string) dns.RR {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func newTestWaiter(s *testServer) *Waiter {
+	w := New()
+	w.Nameservers = []string{s.Address}
+	w.Timeout = 2 * time.Second
+	w.Interval = 10 * time.Millisecond
+	w.QueryTimeout = time.Second
+	return w
+}
+
+func TestWaitForAddedRecord(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.Set(`_acme-challenge.example.com. 60 IN TXT "other"`)
+
+	w := newTestWaiter(s)
+
+	var reports []Report
+	w.Progress = func(r Report) { reports = append(reports, r) }
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Set(
+			`_acme-challenge.example.com. 60 IN TXT "other"`,
+			`_acme-challenge.example.com. 60 IN TXT "token"`,
+		)
+	}()
+
+	// The TTL doesn't have to match
+	expected := mustRR(`_acme-challenge.example.com. 300 IN TXT "token"`)
+	if err := w.Wait("example.com", Expect([]dns.RR{expected}, nil)...); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("Expected several attempts, got %+v", reports)
+	}
+	if last := reports[len(reports)-1]; !last.Ready || last.Err != nil {
+		t.Errorf("Last report should be ready: %+v", last)
+	}
+	if first := reports[0]; first.Ready || first.Attempt != 1 {
+		t.Errorf("First report shouldn't be ready: %+v", first)
+	}
+}
+
+func TestWaitForRemovedRecord(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.Set(`www.example.com. 300 IN A 1.1.1.1`)
+
+	w := newTestWaiter(s)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Set()
+	}()
+
+	removed := mustRR(`www.example.com. 300 IN A 1.1.1.1`)
+	if err := w.Wait("example.com", Expect(nil, []dns.RR{removed})...); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	w := newTestWaiter(s)
+	w.Timeout = 100 * time.Millisecond
+
+	expected := mustRR(`www.example.com. 300 IN A 1.1.1.1`)
+	err := w.Wait("example.com", Expect([]dns.RR{expected}, nil)...)
+	if err == nil || !strings.Contains(err.Error(), s.Address) {
+		t.Errorf("Expected a timeout naming the server, got %v", err)
+	}
+}
+
+func TestWaitFallsBackToTCP(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	records := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		records = append(records, fmt.Sprintf(
+			`big.example.com. 300 IN TXT "%s-%d"`, strings.Repeat("x", 50), i,
+		))
+	}
+	s.Set(records...)
+
+	w := newTestWaiter(s)
+
+	expected := mustRR(records[19])
+	if err := w.Wait("example.com", Expect([]dns.RR{expected}, nil)...); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if s.Queries("tcp") == 0 {
+		t.Errorf("Truncated answer wasn't retried over TCP")
+	}
+}
+
+func TestFindNameservers(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.Set(
+		`example.com. 3600 IN NS ns2.example.com.`,
+		`example.com. 3600 IN NS ns1.example.com.`,
+		`ns1.example.com. 3600 IN A 127.0.0.1`,
+		`ns2.example.com. 3600 IN A 127.0.0.2`,
+	)
+
+	_, port, _ := net.SplitHostPort(s.Address)
+
+	w := New()
+	w.Resolvers = []string{s.Address}
+	w.Port = port
+
+	nameservers, err := w.FindNameservers("example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []Nameserver{
+		{Name: "ns1.example.com.", Address: net.JoinHostPort("127.0.0.1", port)},
+		{Name: "ns2.example.com.", Address: net.JoinHostPort("127.0.0.2", port)},
+	}
+	if len(nameservers) != 2 || nameservers[0] != expected[0] ||
+		nameservers[1] != expected[1] {
+		t.Errorf("Unexpected name servers: %+v", nameservers)
+	}
+}
+
+func TestWaitWithUnreachableAddress(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.Set(
+		`example.com. 3600 IN NS ns1.example.com.`,
+		`ns1.example.com. 3600 IN A 127.0.0.1`,
+		`ns1.example.com. 3600 IN A 127.0.0.10`,
+		`www.example.com. 300 IN A 1.1.1.1`,
+	)
+
+	_, port, _ := net.SplitHostPort(s.Address)
+
+	w := newTestWaiter(s)
+	w.Nameservers = nil
+	w.Resolvers = []string{s.Address}
+	w.Port = port
+
+	var failed []Report
+	w.Progress = func(r Report) {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	// Nothing listens on 127.0.0.10, which is checked first, but ns1
+	// answers on its other address
+	expected := mustRR(`www.example.com. 300 IN A 1.1.1.1`)
+	if err := w.Wait("example.com", Expect([]dns.RR{expected}, nil)...); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(failed) == 0 {
+		t.Errorf("The unreachable address wasn't checked")
+	}
+}
+
+func TestExpectGroupsByNameAndType(t *testing.T) {
+	expectations := Expect(
+		[]dns.RR{
+			mustRR(`a.example.com. 60 IN TXT "one"`),
+			mustRR(`A.example.com. 60 IN TXT "two"`),
+			mustRR(`a.example.com. 60 IN A 1.1.1.1`),
+		},
+		[]dns.RR{mustRR(`a.example.com. 60 IN TXT "old"`)},
+	)
+
+	if len(expectations) != 2 {
+		t.Fatalf("Expected 2 expectations, got %+v", expectations)
+	}
+	if len(expectations[0].Present) != 2 || len(expectations[0].Absent) != 1 {
+		t.Errorf("Unexpected TXT expectation: %+v", expectations[0])
+	}
+}
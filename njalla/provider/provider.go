@@ -6,10 +6,13 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
 )
 
 // Provider struct
@@ -171,6 +174,98 @@ func (p *Provider) GetRecords(domain string) (records.Records, error) {
 	return r, nil
 }
 
+// redirectForm selects the add record form of Redirect records
+const redirectForm = "form#add-Redirect"
+
+// GetCapabilities scrapes the values Njalla accepts for TTLs, priorities,
+// redirect types and SSHFP fields from the add record form of a domain.
+// If the form couldn't be scraped, such as when the session expired or any
+// of the fields has no values, it returns an error along with
+// structures.DefaultCapabilities, so the result can always be used for
+// validation
+func (p *Provider) GetCapabilities(domain string) (
+	structures.Capabilities, error,
+) {
+	caps := structures.DefaultCapabilities()
+
+	_, err := getCSRFToken(p.jar, p.BaseURL)
+	if err != nil {
+		return caps, err
+	}
+
+	resp, respErr := p.client.Get(p.getDomainURL(domain))
+	if respErr != nil {
+		return caps, respErr
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return caps, fmt.Errorf(
+			"Getting the capabilities of %s failed with status code %d",
+			domain, resp.StatusCode,
+		)
+	}
+
+	// Without a valid session Njalla redirects to the sign in page
+	if strings.HasPrefix(resp.Request.URL.Path, "/signin/") {
+		return caps, fmt.Errorf(
+			"Getting the capabilities of %s requires being logged in", domain,
+		)
+	}
+
+	doc, docErr := goquery.NewDocumentFromReader(resp.Body)
+	if docErr != nil {
+		return caps, docErr
+	}
+
+	// Every record type but Redirect has a TTL, so a page without any TTL
+	// doesn't have the add record form
+	ttls := selectOptions(doc.Find(`select[name="ttl"]`))
+	if len(ttls) == 0 {
+		return caps, fmt.Errorf(
+			"Couldn't find the add record form of %s", domain,
+		)
+	}
+
+	// Both priorities and redirect types are sent as `prio`, so they're told
+	// apart by the form of the Redirect records
+	priorities := selectOptions(doc.Find(`select[name="prio"]`).FilterFunction(
+		func(i int, s *goquery.Selection) bool {
+			return s.Closest(redirectForm).Length() == 0
+		},
+	))
+	redirectTypes := selectOptions(
+		doc.Find(redirectForm + ` select[name="prio"]`),
+	)
+	algorithms := selectOptions(doc.Find(`select[name="ssh_algorithm"]`))
+	sshTypes := selectOptions(doc.Find(`select[name="ssh_type"]`))
+
+	scraped := []struct {
+		field  string
+		values []int
+	}{
+		{"priorities", priorities},
+		{"redirect types", redirectTypes},
+		{"SSH algorithms", algorithms},
+		{"SSH types", sshTypes},
+	}
+	for _, s := range scraped {
+		if len(s.values) == 0 {
+			return caps, fmt.Errorf(
+				"Couldn't find the %s accepted by %s", s.field, domain,
+			)
+		}
+	}
+
+	caps.TTLs = ttls
+	caps.Priorities = priorities
+	caps.RedirectTypes = redirectTypes
+	caps.SSHAlgorithms = algorithms
+	caps.SSHTypes = sshTypes
+
+	return caps, nil
+}
+
 // AddRecord creates a new record in Njalla. It accepts only those record
 // types defined in records/records
 func (p *Provider) AddRecord(domain string, record records.Record) error {
@@ -233,29 +328,103 @@ func (p *Provider) UpdateRecord(
 		return recErr
 	}
 
-	updateMap := make(map[string]map[string]string)
+	updateValues := make(map[int]url.Values)
 	for _, storedRecord := range storedRecords {
-		key := fmt.Sprintf("%d", storedRecord.GetID())
 		content := storedRecord.GetURLValues()
 
 		if storedRecord.GetID() == recordID {
 			content = record
 		}
 
-		// On update the ID is used to create a new map under that ID
-		// And Type is not included in that inner map
-		content.Del("id")
-		content.Del("type")
+		updateValues[storedRecord.GetID()] = content
+	}
+
+	jsonRecords, jsonErr := records.EncodeUpdateValues(updateValues)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	values := url.Values{}
+	values.Set("action", "update")
+	values.Set("csrfmiddlewaretoken", csrftoken)
+	values.Set("records", string(jsonRecords))
+
+	resp, respErr := postForm(p.client, p.getDomainURL(domain), values)
+	if respErr != nil {
+		return respErr
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf(
+			"Updating record %+v failed with status code %d",
+			record, resp.StatusCode,
+		)
+	}
+
+	return nil
+}
+
+// UpdateRecordTyped updates a record in Njalla taking the Record itself,
+// rather than hand-edited url.Values. The record to update is matched by its
+// ID, so take a Record from GetRecords and modify it through its With*
+// methods, like:
+//
+//	updated := record.WithContent("new content").WithTTL(structures.TTL300)
+//	err := p.UpdateRecordTyped("mydomain.com", updated)
+func (p *Provider) UpdateRecordTyped(domain string, record records.Record) error {
+	return p.UpdateRecord(domain, record.GetID(), record.GetURLValues())
+}
+
+// UpdateRecords replaces the stored records with the IDs the updates are
+// keyed by, and removes the records with one of the removeIDs, all in a
+// single update operation, so Njalla either makes every change or none.
+// Since Njalla's update operation doesn't take the record type, an update
+// must keep the type of the record it replaces
+func (p *Provider) UpdateRecords(
+	domain string, updates map[int]records.Record, removeIDs []int,
+) error {
+	csrftoken, err := getCSRFToken(p.jar, p.BaseURL)
+	if err != nil {
+		return err
+	}
+
+	storedRecords, recErr := p.GetRecords(domain)
+	if recErr != nil {
+		return recErr
+	}
+
+	for id, record := range updates {
+		stored, exists := storedRecords.FindByID(id)
+		if !exists {
+			return fmt.Errorf("Record %d doesn't exist in %s", id, domain)
+		}
+		if stored.GetType() != record.GetType() {
+			return fmt.Errorf(
+				"Record %d is a %s record, it can't become a %s record", id,
+				stored.GetType(), record.GetType(),
+			)
+		}
+	}
+
+	toRemove := make(map[int]bool)
+	for _, recordID := range removeIDs {
+		toRemove[recordID] = true
+	}
 
-		m := make(map[string]string)
-		for k, v := range content {
-			m[k] = v[0]
+	updateValues := make(map[int]url.Values)
+	for _, storedRecord := range storedRecords {
+		id := storedRecord.GetID()
+		if toRemove[id] {
+			continue
 		}
 
-		updateMap[key] = m
+		updateValues[id] = storedRecord.GetURLValues()
+		if record, exists := updates[id]; exists {
+			updateValues[id] = record.GetURLValues()
+		}
 	}
 
-	jsonRecords, jsonErr := json.Marshal(updateMap)
+	jsonRecords, jsonErr := records.EncodeUpdateValues(updateValues)
 	if jsonErr != nil {
 		return jsonErr
 	}
@@ -272,8 +441,8 @@ func (p *Provider) UpdateRecord(
 
 	if resp.StatusCode != 200 {
 		return fmt.Errorf(
-			"Updating record %+v failed with status code %d",
-			record, resp.StatusCode,
+			"Updating %d and removing %d records failed with status code %d",
+			len(updates), len(removeIDs), resp.StatusCode,
 		)
 	}
 
@@ -285,6 +454,12 @@ func (p *Provider) UpdateRecord(
 // an update operation. An update operation that keeps all the records but the
 // one you want to remove.
 func (p *Provider) RemoveRecord(domain string, recordID int) error {
+	return p.RemoveRecords(domain, []int{recordID})
+}
+
+// RemoveRecords is the same as RemoveRecord, but it removes every record
+// with one of the given IDs in a single update operation
+func (p *Provider) RemoveRecords(domain string, recordIDs []int) error {
 	csrftoken, err := getCSRFToken(p.jar, p.BaseURL)
 	if err != nil {
 		return err
@@ -295,41 +470,26 @@ func (p *Provider) RemoveRecord(domain string, recordID int) error {
 		return recErr
 	}
 
-	updateMap := make(map[string]map[string]string)
-	for _, storedRecord := range storedRecords {
-		key := fmt.Sprintf("%d", storedRecord.GetID())
-		content := storedRecord.GetURLValues()
+	toRemove := make(map[int]bool)
+	for _, recordID := range recordIDs {
+		toRemove[recordID] = true
+	}
 
-		if storedRecord.GetID() == recordID {
+	remaining := make(records.Records, 0, len(storedRecords))
+	for _, storedRecord := range storedRecords {
+		if toRemove[storedRecord.GetID()] {
 			continue
 		}
-
-		// On update the ID is used to create a new map under that ID
-		// And Type is not included in that inner map
-		content.Del("id")
-		content.Del("type")
-
-		m := make(map[string]string)
-		for k, v := range content {
-			m[k] = v[0]
-		}
-
-		updateMap[key] = m
+		remaining = append(remaining, storedRecord)
 	}
 
-	var jsonRecords string
-	if len(updateMap) == 0 {
-		jsonRecords = "{}"
-	} else {
-		jsonRecordsTmp, jsonErr := json.Marshal(updateMap)
-		if jsonErr != nil {
-			return jsonErr
-		}
-		jsonRecords = string(jsonRecordsTmp)
+	jsonRecords, jsonErr := records.EncodeUpdatePayload(remaining)
+	if jsonErr != nil {
+		return jsonErr
 	}
 
 	values := url.Values{}
-	values.Set("records", jsonRecords)
+	values.Set("records", string(jsonRecords))
 	values.Set("action", "update")
 	values.Set("csrfmiddlewaretoken", csrftoken)
 
@@ -340,14 +500,40 @@ func (p *Provider) RemoveRecord(domain string, recordID int) error {
 
 	if resp.StatusCode != 200 {
 		return fmt.Errorf(
-			"Removing record %d failed with status code %d",
-			recordID, resp.StatusCode,
+			"Removing records %v failed with status code %d",
+			recordIDs, resp.StatusCode,
 		)
 	}
 
 	return nil
 }
 
+// selectOptions returns the sorted, unique int values of the options of the
+// given selects. Options with non int values are ignored
+func selectOptions(selects *goquery.Selection) []int {
+	seen := make(map[int]bool)
+	values := make([]int, 0)
+
+	selects.Find("option").
+		Each(func(i int, s *goquery.Selection) {
+			raw, exists := s.Attr("value")
+			if !exists {
+				raw = s.Text()
+			}
+
+			value, err := strconv.Atoi(strings.TrimSpace(raw))
+			if err != nil || seen[value] {
+				return
+			}
+
+			seen[value] = true
+			values = append(values, value)
+		})
+
+	sort.Ints(values)
+	return values
+}
+
 func postForm(
 	client http.Client, path string, data url.Values,
 ) (*http.Response, error) {
@@ -1,10 +1,16 @@
 package provider
 
 import (
-	// "fmt"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
-	// "github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
 )
 
 func TestCreation(t *testing.T) {
@@ -40,6 +46,259 @@ func TestDomainURL(t *testing.T) {
 	}
 }
 
+func TestGetCapabilities(t *testing.T) {
+	page := `<html><body>
+<form id="add-A">
+	<select name="ttl">
+		<option value="60">1 minute</option>
+		<option value="3600">1 hour</option>
+		<option value="43200">12 hours</option>
+	</select>
+</form>
+<form id="add-MX">
+	<select name="ttl"><option value="60">1 minute</option></select>
+	<select name="prio">
+		<option value="10">10</option>
+		<option value="0">0</option>
+	</select>
+</form>
+<form id="add-Redirect">
+	<select name="prio">
+		<option value="301">301</option>
+		<option value="302">302</option>
+		<option value="307">307</option>
+		<option value="308">308</option>
+	</select>
+</form>
+<form id="add-SSHFP">
+	<select name="ttl"><option value="60">1 minute</option></select>
+	<select name="ssh_algorithm">
+		<option value="1">RSA</option>
+		<option value="4">Ed25519</option>
+	</select>
+	<select name="ssh_type"><option value="2">SHA-256</option></select>
+</form>
+</body></html>`
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/domains/mydomain.com/" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, page)
+		},
+	))
+	defer server.Close()
+
+	provider, err := New()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	provider.BaseURL = server.URL
+
+	caps, err := provider.GetCapabilities("mydomain.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := structures.DefaultCapabilities()
+	expected.TTLs = []int{60, 3600, 43200}
+	expected.Priorities = []int{0, 10}
+	expected.RedirectTypes = []int{301, 302, 307, 308}
+	expected.SSHAlgorithms = []int{1, 4}
+	expected.SSHTypes = []int{2}
+
+	if !cmp.Equal(expected, caps) {
+		t.Errorf("Capabilities don't match:\n%s", cmp.Diff(expected, caps))
+	}
+}
+
+func TestGetCapabilitiesErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/signin/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><form></form></body></html>")
+	})
+	mux.HandleFunc("/domains/expired.com/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/signin/", http.StatusFound)
+	})
+	mux.HandleFunc("/domains/broken.com/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/domains/changed.com/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>No forms here</body></html>")
+	})
+	mux.HandleFunc("/domains/noredirect.com/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><form id="add-MX">
+<select name="ttl"><option value="60">1 minute</option></select>
+<select name="prio"><option value="10">10</option></select>
+</form></body></html>`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider, err := New()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	provider.BaseURL = server.URL
+
+	for _, domain := range []string{
+		"expired.com", "broken.com", "changed.com", "noredirect.com",
+	} {
+		caps, err := provider.GetCapabilities(domain)
+		if err == nil {
+			t.Errorf("Capabilities of %s didn't fail", domain)
+		}
+
+		expected := structures.DefaultCapabilities()
+		if !cmp.Equal(expected, caps) {
+			t.Errorf(
+				"Capabilities of %s aren't the defaults:\n%s",
+				domain, cmp.Diff(expected, caps),
+			)
+		}
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/signin/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			http.SetCookie(w, &http.Cookie{
+				Name: "sessionid", Value: "abc", Path: "/",
+			})
+		}
+		fmt.Fprint(
+			w, `<input name="csrfmiddlewaretoken" value="token">`,
+		)
+	})
+	mux.HandleFunc("/domains/", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("sessionid"); err != nil ||
+			cookie.Value != "abc" {
+			http.Redirect(w, r, "/signin/", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider, _ := New()
+	provider.BaseURL = server.URL
+
+	if loggedIn, err := provider.LoggedIn(); err != nil || loggedIn {
+		t.Fatalf("Provider shouldn't be logged in yet: %v", err)
+	}
+
+	if err := provider.Login("email", "password"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var session bytes.Buffer
+	if err := provider.SaveSession(&session); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	restored, _ := New()
+	restored.BaseURL = server.URL
+	if err := restored.LoadSession(&session); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if loggedIn, err := restored.LoggedIn(); err != nil || !loggedIn {
+		t.Errorf("Restored provider should be logged in: %v", err)
+	}
+}
+
+func TestRemoveRecordsSinglePost(t *testing.T) {
+	page := `<script>
+var records = [
+	{"type": "A", "name": "@", "id": 1, "content": "1.1.1.1", "ttl": 10800},
+	{"type": "TXT", "name": "a", "id": 2, "content": "one", "ttl": 300},
+	{"type": "TXT", "name": "a", "id": 3, "content": "two", "ttl": 300}
+];
+</script>`
+
+	posts := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				r.ParseForm()
+				posts = append(posts, r.PostForm.Get("records"))
+			}
+			fmt.Fprint(w, page)
+		},
+	))
+	defer server.Close()
+
+	provider, _ := New()
+	provider.BaseURL = server.URL
+
+	if err := provider.RemoveRecords("mydomain.com", []int{2, 3}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{`{"1":{"content":"1.1.1.1","name":"@","ttl":"10800"}}`}
+	if !cmp.Equal(expected, posts) {
+		t.Errorf("Posts don't match:\n%s", cmp.Diff(expected, posts))
+	}
+}
+
+func TestUpdateRecordsSinglePost(t *testing.T) {
+	page := `<script>
+var records = [
+	{"type": "A", "name": "@", "id": 1, "content": "1.1.1.1", "ttl": 10800},
+	{"type": "TXT", "name": "a", "id": 2, "content": "one", "ttl": 300},
+	{"type": "TXT", "name": "a", "id": 3, "content": "two", "ttl": 300}
+];
+</script>`
+
+	posts := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				r.ParseForm()
+				posts = append(posts, r.PostForm.Get("records"))
+			}
+			fmt.Fprint(w, page)
+		},
+	))
+	defer server.Close()
+
+	provider, _ := New()
+	provider.BaseURL = server.URL
+
+	updated := &records.RecordTXT{
+		Type: "TXT", Name: "a", Content: "three", TTL: 60,
+	}
+	err := provider.UpdateRecords(
+		"mydomain.com", map[int]records.Record{2: updated}, []int{3},
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{
+		`{"1":{"content":"1.1.1.1","name":"@","ttl":"10800"},` +
+			`"2":{"content":"three","name":"a","ttl":"60"}}`,
+	}
+	if !cmp.Equal(expected, posts) {
+		t.Errorf("Posts don't match:\n%s", cmp.Diff(expected, posts))
+	}
+
+	// The type of a record can't change
+	a := &records.RecordA{Type: "A", Name: "a", Content: "1.1.1.1", TTL: 60}
+	err = provider.UpdateRecords(
+		"mydomain.com", map[int]records.Record{2: a}, nil,
+	)
+	if err == nil || len(posts) != 1 {
+		t.Errorf("Expected an error without posting, got %v", err)
+	}
+}
+
 // func TestUpdateDomain(t *testing.T) {
 // 	provider, _ := New()
 // 	provider.Login("email", `password`)
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sessionCookie is a cookie as stored by SaveSession
+type sessionCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SaveSession writes the session cookies of a logged in Provider as JSON, so
+// they can be restored with LoadSession instead of logging in again. The
+// cookies give full access to the account, so keep them private
+func (p *Provider) SaveSession(w io.Writer) error {
+	parsedURL, err := url.Parse(p.BaseURL)
+	if err != nil {
+		return err
+	}
+
+	cookies := make([]sessionCookie, 0)
+	for _, cookie := range p.jar.Cookies(parsedURL) {
+		cookies = append(cookies, sessionCookie{
+			Name: cookie.Name, Value: cookie.Value,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(cookies)
+}
+
+// LoadSession restores the session cookies written by SaveSession. The
+// session may have expired since, so check it with LoggedIn
+func (p *Provider) LoadSession(r io.Reader) error {
+	parsedURL, err := url.Parse(p.BaseURL)
+	if err != nil {
+		return err
+	}
+
+	var stored []sessionCookie
+	if err := json.NewDecoder(r).Decode(&stored); err != nil {
+		return err
+	}
+
+	cookies := make([]*http.Cookie, len(stored))
+	for i, cookie := range stored {
+		cookies[i] = &http.Cookie{Name: cookie.Name, Value: cookie.Value, Path: "/"}
+	}
+	p.jar.SetCookies(parsedURL, cookies)
+
+	return nil
+}
+
+// LoggedIn checks if the Provider has a valid session. Njalla redirects to
+// the sign in page any request that needs a session when there's none
+func (p *Provider) LoggedIn() (bool, error) {
+	resp, err := p.client.Get(p.getURL("/domains/"))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return false, nil
+	}
+
+	return !strings.HasPrefix(resp.Request.URL.Path, "/signin/"), nil
+}
@@ -0,0 +1,240 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// Client is the part of provider.Provider needed to plan and apply changes
+type Client interface {
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	UpdateRecords(
+		domain string, updates map[int]records.Record, removeIDs []int,
+	) error
+}
+
+// Update is a record whose name, type and content are unchanged, but some
+// other field, like the TTL, has to change
+type Update struct {
+	From records.Record `json:"from"`
+	To   records.Record `json:"to"`
+}
+
+// Plan holds the changes needed to bring a domain to its desired state
+type Plan struct {
+	Domain  string          `json:"domain"`
+	Creates records.Records `json:"create"`
+	Updates []Update        `json:"update"`
+	Deletes records.Records `json:"delete"`
+}
+
+// Empty returns true if the domain is already in its desired state
+func (p *Plan) Empty() bool {
+	return len(p.Creates) == 0 && len(p.Updates) == 0 && len(p.Deletes) == 0
+}
+
+func (p *Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:\n", p.Domain)
+
+	if p.Empty() {
+		b.WriteString("  No changes\n")
+		return b.String()
+	}
+
+	for _, record := range p.Deletes {
+		fmt.Fprintf(&b, "  - %s\n", describe(record))
+	}
+	for _, update := range p.Updates {
+		fmt.Fprintf(
+			&b, "  ~ %s\n      %s\n", describe(update.From),
+			describeChanges(update.From, update.To),
+		)
+	}
+	for _, record := range p.Creates {
+		fmt.Fprintf(&b, "  + %s\n", describe(record))
+	}
+
+	fmt.Fprintf(
+		&b, "  %d to create, %d to update, %d to delete\n",
+		len(p.Creates), len(p.Updates), len(p.Deletes),
+	)
+
+	return b.String()
+}
+
+// JSON renders the plan as indented JSON
+func (p *Plan) JSON() (string, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// NewPlan compares the current records of a domain, as returned by
+// GetRecords, with the desired ones. Records are matched on their name, type
+// and content, ignoring IDs. Matched records with any other field changed
+// become updates, desired records with no match become creates, and current
+// records with no match become deletes
+func NewPlan(domain string, current, desired records.Records) *Plan {
+	plan := &Plan{
+		Domain:  domain,
+		Creates: make(records.Records, 0),
+		Updates: make([]Update, 0),
+		Deletes: make(records.Records, 0),
+	}
+
+	unmatched := make(map[string]records.Records)
+	for _, record := range current {
+		key := records.MatchKey(domain, record)
+		unmatched[key] = append(unmatched[key], record)
+	}
+
+	for _, record := range desired {
+		key := records.MatchKey(domain, record)
+		candidates := unmatched[key]
+		if len(candidates) == 0 {
+			plan.Creates = append(plan.Creates, record)
+			continue
+		}
+
+		// Prefer an identical record so duplicates don't become updates
+		index := 0
+		for i, candidate := range candidates {
+			if len(changedFields(candidate, record)) == 0 {
+				index = i
+				break
+			}
+		}
+
+		match := candidates[index]
+		unmatched[key] = append(candidates[:index:index], candidates[index+1:]...)
+
+		if len(changedFields(match, record)) > 0 {
+			plan.Updates = append(plan.Updates, Update{From: match, To: record})
+		}
+	}
+
+	for _, record := range current {
+		key := records.MatchKey(domain, record)
+		for _, leftover := range unmatched[key] {
+			if leftover == record {
+				plan.Deletes = append(plan.Deletes, record)
+				break
+			}
+		}
+	}
+
+	return plan
+}
+
+// Apply makes the changes of the plan through the client. Deletes and
+// updates are sent together in a single update operation, so Njalla either
+// makes all of them or none. Creates come after, so records like a CNAME
+// can be replaced by other records at the same name
+func (p *Plan) Apply(client Client) error {
+	if len(p.Deletes) > 0 || len(p.Updates) > 0 {
+		updates := make(map[int]records.Record, len(p.Updates))
+		for _, update := range p.Updates {
+			updates[update.From.GetID()] = update.To
+		}
+
+		removeIDs := make([]int, len(p.Deletes))
+		for i, record := range p.Deletes {
+			removeIDs[i] = record.GetID()
+		}
+
+		err := client.UpdateRecords(p.Domain, updates, removeIDs)
+		if err != nil {
+			return fmt.Errorf(
+				"Couldn't delete %d and update %d record(s): %s",
+				len(p.Deletes), len(p.Updates), err,
+			)
+		}
+	}
+
+	for _, record := range p.Creates {
+		if err := client.AddRecord(p.Domain, record); err != nil {
+			return fmt.Errorf("Couldn't create %s: %s", describe(record), err)
+		}
+	}
+
+	return nil
+}
+
+// PlanState fetches the current records of every domain in the state and
+// plans the changes for each of them
+func PlanState(client Client, state *State) ([]*Plan, error) {
+	plans := make([]*Plan, 0, len(state.Domains))
+	for _, domain := range state.DomainNames() {
+		current, err := client.GetRecords(domain)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Couldn't fetch records for %s: %s", domain, err,
+			)
+		}
+
+		plans = append(plans, NewPlan(domain, current, state.Domains[domain]))
+	}
+	return plans, nil
+}
+
+// changedFields returns the fields, other than id, name, type and content,
+// that differ between two records
+func changedFields(from, to records.Record) []string {
+	fromValues := from.GetURLValues()
+	toValues := to.GetURLValues()
+
+	keys := make(map[string]bool)
+	for key := range fromValues {
+		keys[key] = true
+	}
+	for key := range toValues {
+		keys[key] = true
+	}
+
+	changed := make([]string, 0)
+	for key := range keys {
+		switch key {
+		case "id", "name", "type", "content":
+			continue
+		}
+
+		if fromValues.Get(key) != toValues.Get(key) {
+			changed = append(changed, key)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+func describe(record records.Record) string {
+	description := fmt.Sprintf("%s %s", record.GetType(), record.GetName())
+	if content := record.GetContent(); content != "" {
+		description += fmt.Sprintf(" %q", content)
+	}
+	if id := record.GetID(); id != 0 {
+		description += fmt.Sprintf(" (id %d)", id)
+	}
+	return description
+}
+
+func describeChanges(from, to records.Record) string {
+	fromValues := from.GetURLValues()
+	toValues := to.GetURLValues()
+
+	changes := make([]string, 0)
+	for _, key := range changedFields(from, to) {
+		changes = append(changes, fmt.Sprintf(
+			"%s: %s => %s", key, fromValues.Get(key), toValues.Get(key),
+		))
+	}
+	return strings.Join(changes, ", ")
+}
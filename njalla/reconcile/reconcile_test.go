@@ -0,0 +1,187 @@
+package reconcile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+type fakeClient struct {
+	records map[string]records.Records
+	calls   []string
+}
+
+func (c *fakeClient) GetRecords(domain string) (records.Records, error) {
+	return c.records[domain], nil
+}
+
+func (c *fakeClient) AddRecord(domain string, record records.Record) error {
+	c.calls = append(c.calls, fmt.Sprintf(
+		"add %s %s %s", domain, record.GetType(), record.GetName(),
+	))
+	return nil
+}
+
+func (c *fakeClient) UpdateRecords(
+	domain string, updates map[int]records.Record, removeIDs []int,
+) error {
+	ids := make([]int, 0, len(updates))
+	for id := range updates {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	call := fmt.Sprintf("update %s remove=%v", domain, removeIDs)
+	for _, id := range ids {
+		call += fmt.Sprintf(
+			" %d:ttl=%s", id, updates[id].GetURLValues().Get("ttl"),
+		)
+	}
+	c.calls = append(c.calls, call)
+	return nil
+}
+
+const stateYAML = `
+domains:
+  example.com:
+    - type: A
+      name: "@"
+      content: 1.1.1.1
+      ttl: 300
+    - type: MX
+      name: example.com.
+      content: MAIL.protonmail.ch.
+      ttl: 10800
+      prio: 10
+    - type: TXT
+      name: www
+      content: hello
+      ttl: 10800
+`
+
+func TestLoadState(t *testing.T) {
+	state, err := LoadState(strings.NewReader(stateYAML))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := records.Records{
+		&records.RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300},
+		&records.RecordMX{
+			Type: "MX", Name: "example.com.", Content: "MAIL.protonmail.ch.",
+			TTL: 10800, Priority: 10,
+		},
+		&records.RecordTXT{Type: "TXT", Name: "www", Content: "hello", TTL: 10800},
+	}
+
+	if got := state.Domains["example.com"]; !cmp.Equal(expected, got) {
+		t.Errorf("State doesn't match:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestLoadStateJSON(t *testing.T) {
+	js := `{"domains": {"example.com": [
+		{"type": "TXT", "name": "@", "content": "hello", "ttl": 300}
+	]}}`
+
+	state, err := LoadState(strings.NewReader(js))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(state.Domains["example.com"]) != 1 {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+}
+
+func TestValidateStateWithCapabilities(t *testing.T) {
+	js := `{"domains": {"example.com": [
+		{"type": "TXT", "name": "@", "content": "hello", "ttl": 43200}
+	]}}`
+
+	state, err := LoadState(strings.NewReader(js))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err := state.Validate(); err == nil {
+		t.Errorf("A TTL missing from the default capabilities was accepted")
+	}
+
+	caps := structures.DefaultCapabilities()
+	caps.TTLs = []int{300, 43200}
+	err = state.ValidateWith(map[string]structures.Capabilities{
+		"example.com": caps,
+	})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	client := &fakeClient{records: map[string]records.Records{
+		"example.com": {
+			&records.RecordA{
+				ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800,
+			},
+			&records.RecordMX{
+				ID: 2, Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+				TTL: 10800, Priority: 10,
+			},
+			&records.RecordTXT{
+				ID: 3, Type: "TXT", Name: "www", Content: "HELLO", TTL: 10800,
+			},
+		},
+	}}
+
+	state, err := LoadState(strings.NewReader(stateYAML))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	plans, err := PlanState(client, state)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	plan := plans[0]
+	if len(plan.Creates) != 1 || len(plan.Updates) != 1 ||
+		len(plan.Deletes) != 1 {
+		t.Fatalf("Unexpected plan:\n%s", plan)
+	}
+
+	if err := plan.Apply(client); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{
+		"update example.com remove=[3] 1:ttl=300",
+		"add example.com TXT www",
+	}
+
+	if !cmp.Equal(expected, client.calls) {
+		t.Errorf("Calls don't match:\n%s", cmp.Diff(expected, client.calls))
+	}
+}
+
+func TestPlanNoChanges(t *testing.T) {
+	current := records.Records{
+		&records.RecordTXT{ID: 1, Type: "TXT", Name: "@", Content: "a", TTL: 300},
+		&records.RecordTXT{ID: 2, Type: "TXT", Name: "@", Content: "a", TTL: 60},
+	}
+	desired := records.Records{
+		&records.RecordTXT{Type: "TXT", Name: "@", Content: "a", TTL: 60},
+		&records.RecordTXT{Type: "TXT", Name: "@", Content: "a", TTL: 300},
+	}
+
+	plan := NewPlan("example.com", current, desired)
+	if !plan.Empty() {
+		t.Errorf("Expected an empty plan:\n%s", plan)
+	}
+}
@@ -0,0 +1,129 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// State is the desired state of one or more domains, as read from a YAML or
+// JSON file like:
+//
+//	domains:
+//	  mydomain.com:
+//	    - type: A
+//	      name: "@"
+//	      content: 1.1.1.1
+//	      ttl: 10800
+//	    - type: MX
+//	      name: "@"
+//	      content: mail.protonmail.ch
+//	      ttl: 10800
+//	      prio: 10
+//
+// Records use the same fields as Njalla's own records, without the `id`
+type State struct {
+	Domains map[string]records.Records `json:"domains"`
+}
+
+// DomainNames returns the domains in the state, sorted
+func (s *State) DomainNames() []string {
+	names := make([]string, 0, len(s.Domains))
+	for name := range s.Domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks every record of every domain in the state against the
+// default values Njalla accepts
+func (s *State) Validate() error {
+	return s.ValidateWith(nil)
+}
+
+// ValidateWith is the same as Validate, but the records of every domain are
+// checked against its Capabilities in caps. Domains missing from caps are
+// checked against the defaults
+func (s *State) ValidateWith(caps map[string]structures.Capabilities) error {
+	for _, domain := range s.DomainNames() {
+		domainCaps, ok := caps[domain]
+		if !ok {
+			domainCaps = structures.DefaultCapabilities()
+		}
+
+		err := records.ValidateZoneWith(s.Domains[domain], domainCaps)
+		if err != nil {
+			return fmt.Errorf("Domain %s is not valid: %s", domain, err)
+		}
+	}
+	return nil
+}
+
+// LoadState reads a State from YAML or JSON. Since JSON is valid YAML, both
+// are parsed the same way
+func LoadState(r io.Reader) (*State, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// Records only know how to unmarshal from JSON, so go through it
+	converted, err := toJSONCompatible(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(converted)
+	if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(jsonData, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// toJSONCompatible converts the map[interface{}]interface{} YAML creates for
+// objects into map[string]interface{}, which can be marshalled into JSON
+func toJSONCompatible(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, inner := range v {
+			converted, err := toJSONCompatible(inner)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprintf("%v", key)] = converted
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, inner := range v {
+			converted, err := toJSONCompatible(inner)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = converted
+		}
+		return l, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,263 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldChange is a single field that differs between two records
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Modification is a record present in both sides of a Diff, with some of
+// its fields changed
+type Modification struct {
+	Old     Record        `json:"old"`
+	New     Record        `json:"new"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Changeset is the result of comparing two Records
+type Changeset struct {
+	Added    Records        `json:"added"`
+	Removed  Records        `json:"removed"`
+	Modified []Modification `json:"modified"`
+}
+
+// Empty returns true if both sides of the Diff were semantically the same
+func (c Changeset) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// Unified renders the changeset in a unified diff like format, with every
+// record as a zone file line
+func (c Changeset) Unified() string {
+	var b strings.Builder
+
+	for _, record := range c.Removed {
+		fmt.Fprintf(&b, "-%s\n", diffLine(record))
+	}
+	for _, modification := range c.Modified {
+		fmt.Fprintf(&b, "-%s\n", diffLine(modification.Old))
+		fmt.Fprintf(&b, "+%s\n", diffLine(modification.New))
+		for _, change := range modification.Changes {
+			fmt.Fprintf(
+				&b, "#   %s: %q => %q\n", change.Field, change.Old, change.New,
+			)
+		}
+	}
+	for _, record := range c.Added {
+		fmt.Fprintf(&b, "+%s\n", diffLine(record))
+	}
+
+	return b.String()
+}
+
+// JSON renders the changeset as indented JSON
+func (c Changeset) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// Diff compares two Records semantically. IDs are ignored, `@` and empty
+// names are the same, names and hostname contents, like CNAME targets, are
+// compared case insensitively and without trailing dots, and any other
+// content, like TXT, is compared as is. Use DiffZone to also treat fully
+// qualified names as their relative counterparts
+func Diff(old, new Records) Changeset {
+	return DiffZone("", old, new)
+}
+
+// DiffZone is the same as Diff, but names are also normalized against the
+// given origin, so `www.mydomain.com.` and `www` are the same name for
+// `mydomain.com`
+func DiffZone(origin string, old, new Records) Changeset {
+	changeset := Changeset{
+		Added:    make(Records, 0),
+		Removed:  make(Records, 0),
+		Modified: make([]Modification, 0),
+	}
+
+	remainingOld := append(Records{}, old...)
+	remainingNew := make(Records, 0, len(new))
+
+	// Drop records that are semantically identical on both sides
+	for _, record := range new {
+		index := indexOf(remainingOld, func(candidate Record) bool {
+			return len(fieldChanges(origin, candidate, record)) == 0
+		})
+		if index == -1 {
+			remainingNew = append(remainingNew, record)
+			continue
+		}
+		remainingOld = removeIndex(remainingOld, index)
+	}
+
+	// Records with the same name, type and content, but some other field
+	// changed, like the TTL, are modifications
+	unmatchedNew := make(Records, 0, len(remainingNew))
+	for _, record := range remainingNew {
+		key := MatchKey(origin, record)
+		index := indexOf(remainingOld, func(candidate Record) bool {
+			return MatchKey(origin, candidate) == key
+		})
+		if index == -1 {
+			unmatchedNew = append(unmatchedNew, record)
+			continue
+		}
+
+		changeset.Modified = append(changeset.Modified, Modification{
+			Old:     remainingOld[index],
+			New:     record,
+			Changes: fieldChanges(origin, remainingOld[index], record),
+		})
+		remainingOld = removeIndex(remainingOld, index)
+	}
+
+	// If a single record of a name and type is left on each side, its
+	// content was modified. Otherwise there's no telling which record became
+	// which, so they're reported as removed and added
+	byNameType := func(r Records) map[string]Records {
+		grouped := make(map[string]Records)
+		for _, record := range r {
+			key := CanonicalName(origin, record.GetName()) + "\x00" +
+				record.GetType()
+			grouped[key] = append(grouped[key], record)
+		}
+		return grouped
+	}
+
+	oldGroups := byNameType(remainingOld)
+	newGroups := byNameType(unmatchedNew)
+
+	for _, record := range unmatchedNew {
+		key := CanonicalName(origin, record.GetName()) + "\x00" +
+			record.GetType()
+		if len(oldGroups[key]) == 1 && len(newGroups[key]) == 1 {
+			changeset.Modified = append(changeset.Modified, Modification{
+				Old:     oldGroups[key][0],
+				New:     record,
+				Changes: fieldChanges(origin, oldGroups[key][0], record),
+			})
+			delete(oldGroups, key)
+			continue
+		}
+		changeset.Added = append(changeset.Added, record)
+	}
+
+	for _, record := range remainingOld {
+		key := CanonicalName(origin, record.GetName()) + "\x00" +
+			record.GetType()
+		if _, exists := oldGroups[key]; exists {
+			changeset.Removed = append(changeset.Removed, record)
+		}
+	}
+
+	return changeset
+}
+
+// CanonicalName converts a record name into the relative form Njalla uses,
+// so `@`, an empty name and, if origin is given, the origin itself or any
+// fully qualified name in it, are comparable
+func CanonicalName(origin, name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	origin = strings.TrimSuffix(strings.ToLower(origin), ".")
+
+	if name == "" || name == "@" || (origin != "" && name == origin) {
+		return "@"
+	}
+
+	if origin != "" {
+		return strings.TrimSuffix(name, "."+origin)
+	}
+	return name
+}
+
+// CanonicalContent returns the content of a record in a comparable form.
+// Hostnames, like CNAME or MX targets, are case insensitive and their
+// trailing dot is dropped. Any other content is returned as is
+func CanonicalContent(record Record) string {
+	switch record.GetType() {
+	case "CNAME", "MX", "NS", "PTR", "SRV":
+		return strings.TrimSuffix(strings.ToLower(record.GetContent()), ".")
+	default:
+		return record.GetContent()
+	}
+}
+
+// MatchKey identifies a record by its canonical name, type and content,
+// ignoring its ID and any other field
+func MatchKey(origin string, record Record) string {
+	return strings.Join([]string{
+		CanonicalName(origin, record.GetName()),
+		record.GetType(),
+		CanonicalContent(record),
+	}, "\x00")
+}
+
+// fieldChanges returns every field, other than the ID, that semantically
+// differs between two records, sorted by field name
+func fieldChanges(origin string, old, new Record) []FieldChange {
+	oldValues := old.GetURLValues()
+	newValues := new.GetURLValues()
+
+	fields := make(map[string]bool)
+	for field := range oldValues {
+		fields[field] = true
+	}
+	for field := range newValues {
+		fields[field] = true
+	}
+	delete(fields, "id")
+
+	changes := make([]FieldChange, 0)
+	for field := range fields {
+		oldValue, newValue := oldValues.Get(field), newValues.Get(field)
+
+		var same bool
+		switch field {
+		case "name":
+			same = CanonicalName(origin, oldValue) ==
+				CanonicalName(origin, newValue)
+		case "content":
+			same = old.GetType() == new.GetType() &&
+				CanonicalContent(old) == CanonicalContent(new)
+		default:
+			same = oldValue == newValue
+		}
+
+		if !same {
+			changes = append(changes, FieldChange{
+				Field: field, Old: oldValue, New: newValue,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+func diffLine(record Record) string {
+	line, _ := zoneLine(record)
+	return line
+}
+
+func indexOf(r Records, match func(Record) bool) int {
+	for i, record := range r {
+		if match(record) {
+			return i
+		}
+	}
+	return -1
+}
+
+func removeIndex(r Records, index int) Records {
+	return append(r[:index:index], r[index+1:]...)
+}
@@ -0,0 +1,117 @@
+package records
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffSemanticEquality(t *testing.T) {
+	old := Records{
+		&RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&RecordCNAME{
+			ID: 2, Type: "CNAME", Name: "WWW", Content: "Example.org.", TTL: 300,
+		},
+		&RecordTXT{ID: 3, Type: "TXT", Name: "@", Content: "hello", TTL: 300},
+	}
+	new := Records{
+		&RecordTXT{Type: "TXT", Name: "example.com.", Content: "hello", TTL: 300},
+		&RecordA{Type: "A", Name: "", Content: "1.1.1.1", TTL: 10800},
+		&RecordCNAME{
+			Type: "CNAME", Name: "www.example.com", Content: "example.org",
+			TTL: 300,
+		},
+	}
+
+	if changeset := DiffZone("example.com", old, new); !changeset.Empty() {
+		t.Errorf("Expected no changes:\n%s", changeset.Unified())
+	}
+}
+
+func TestDiffTXTIsCaseSensitive(t *testing.T) {
+	old := Records{
+		&RecordTXT{ID: 1, Type: "TXT", Name: "@", Content: "hello", TTL: 300},
+		&RecordTXT{ID: 2, Type: "TXT", Name: "@", Content: "other", TTL: 300},
+	}
+	new := Records{
+		&RecordTXT{Type: "TXT", Name: "@", Content: "HELLO", TTL: 300},
+		&RecordTXT{Type: "TXT", Name: "@", Content: "other", TTL: 300},
+	}
+
+	changeset := Diff(old, new)
+	if len(changeset.Modified) != 1 || len(changeset.Added) != 0 ||
+		len(changeset.Removed) != 0 {
+		t.Fatalf("Unexpected changeset:\n%s", changeset.Unified())
+	}
+
+	expected := []FieldChange{{Field: "content", Old: "hello", New: "HELLO"}}
+	if got := changeset.Modified[0].Changes; !cmp.Equal(expected, got) {
+		t.Errorf("Changes don't match:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestDiffAddedRemovedModified(t *testing.T) {
+	old := Records{
+		&RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&RecordMX{
+			ID: 2, Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+			TTL: 10800, Priority: 10,
+		},
+		&RecordTXT{ID: 3, Type: "TXT", Name: "a", Content: "one", TTL: 300},
+		&RecordTXT{ID: 4, Type: "TXT", Name: "a", Content: "two", TTL: 300},
+	}
+	new := Records{
+		&RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300},
+		&RecordMX{
+			Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+			TTL: 10800, Priority: 20,
+		},
+		&RecordTXT{Type: "TXT", Name: "a", Content: "three", TTL: 300},
+		&RecordTXT{Type: "TXT", Name: "a", Content: "four", TTL: 300},
+		&RecordAAAA{Type: "AAAA", Name: "@", Content: "::1", TTL: 300},
+	}
+
+	changeset := Diff(old, new)
+
+	if len(changeset.Modified) != 2 || len(changeset.Added) != 3 ||
+		len(changeset.Removed) != 2 {
+		t.Fatalf("Unexpected changeset:\n%s", changeset.Unified())
+	}
+
+	expected := strings.Join([]string{
+		"-a\t300\tIN\tTXT\t\"one\"",
+		"-a\t300\tIN\tTXT\t\"two\"",
+		"-@\t10800\tIN\tA\t1.1.1.1",
+		"+@\t300\tIN\tA\t1.1.1.1",
+		"#   ttl: \"10800\" => \"300\"",
+		"-@\t10800\tIN\tMX\t10 mail.protonmail.ch.",
+		"+@\t10800\tIN\tMX\t20 mail.protonmail.ch.",
+		"#   prio: \"10\" => \"20\"",
+		"+a\t300\tIN\tTXT\t\"three\"",
+		"+a\t300\tIN\tTXT\t\"four\"",
+		"+@\t300\tIN\tAAAA\t::1",
+		"",
+	}, "\n")
+
+	if got := changeset.Unified(); got != expected {
+		t.Errorf("Unified diff doesn't match:\n%s", cmp.Diff(expected, got))
+	}
+
+	js, err := changeset.JSON()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(js, &decoded); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, key := range []string{"added", "removed", "modified"} {
+		if _, exists := decoded[key]; !exists {
+			t.Errorf("JSON is missing key %s: %s", key, js)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// EncodeUpdatePayload converts Records into the JSON Njalla expects for its
+// `update` action. Njalla doesn't take a list of records, but an object keyed
+// by record ID, where every value is the record with its `id` and `type`
+// fields removed, and the rest of the fields converted to strings:
+//
+//	{"123": {"name": "@", "content": "1.1.1.1", "ttl": "10800"}}
+//
+// Any record left out of the payload is removed by Njalla
+func EncodeUpdatePayload(r Records) ([]byte, error) {
+	values := make(map[int]url.Values)
+	for _, record := range r {
+		if _, exists := values[record.GetID()]; exists {
+			return nil, fmt.Errorf(
+				"Duplicated record ID [%d] in update payload", record.GetID(),
+			)
+		}
+		values[record.GetID()] = record.GetURLValues()
+	}
+
+	return EncodeUpdateValues(values)
+}
+
+// EncodeUpdateValues is the same as EncodeUpdatePayload, but it takes the
+// url.Values for every record keyed by the record ID. This is useful when
+// some record has been modified through its url.Values directly
+func EncodeUpdateValues(values map[int]url.Values) ([]byte, error) {
+	updateMap := make(map[string]map[string]string)
+	for id, content := range values {
+		m := make(map[string]string)
+		for k, v := range content {
+			// On update the ID is used as the key for the inner map
+			// And Type is not included in that inner map
+			if k == "id" || k == "type" || len(v) == 0 {
+				continue
+			}
+			m[k] = v[0]
+		}
+
+		updateMap[strconv.Itoa(id)] = m
+	}
+
+	return json.Marshal(updateMap)
+}
+
+// DecodeUpdatePayload parses a payload created by EncodeUpdatePayload back
+// into the url.Values of every record, keyed by record ID. Since the payload
+// doesn't include the `type` field, it can't be decoded back into Records
+func DecodeUpdatePayload(data []byte) (map[int]url.Values, error) {
+	var updateMap map[string]map[string]string
+	if err := json.Unmarshal(data, &updateMap); err != nil {
+		return nil, err
+	}
+
+	values := make(map[int]url.Values)
+	for key, content := range updateMap {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("Record ID is not a valid int value: %s", key)
+		}
+
+		v := url.Values{}
+		for field, value := range content {
+			v.Set(field, value)
+		}
+		values[id] = v
+	}
+
+	return values, nil
+}
@@ -0,0 +1,132 @@
+package records
+
+import (
+	"net/url"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// roundTrips checks that a record encoded into an update payload decodes
+// back into its own url.Values, minus the `id` and `type` fields
+func roundTrips(t *testing.T, record Record) bool {
+	payload, err := EncodeUpdatePayload(Records{record})
+	if err != nil {
+		t.Logf("Encoding %+v failed: %s", record, err)
+		return false
+	}
+
+	decoded, err := DecodeUpdatePayload(payload)
+	if err != nil {
+		t.Logf("Decoding %s failed: %s", payload, err)
+		return false
+	}
+
+	expected := record.GetURLValues()
+	expected.Del("id")
+	expected.Del("type")
+
+	got, exists := decoded[record.GetID()]
+	if !exists || len(decoded) != 1 {
+		t.Logf("Decoded payload %s has wrong keys: %+v", payload, decoded)
+		return false
+	}
+
+	if !cmp.Equal(expected, got) {
+		t.Logf("Round trip doesn't match:\n%s", cmp.Diff(expected, got))
+		return false
+	}
+
+	return true
+}
+
+func TestUpdatePayloadRoundTrip(t *testing.T) {
+	properties := map[string]interface{}{
+		"A":        func(r RecordA) bool { return roundTrips(t, r) },
+		"AAAA":     func(r RecordAAAA) bool { return roundTrips(t, r) },
+		"CNAME":    func(r RecordCNAME) bool { return roundTrips(t, r) },
+		"MX":       func(r RecordMX) bool { return roundTrips(t, r) },
+		"TXT":      func(r RecordTXT) bool { return roundTrips(t, r) },
+		"SRV":      func(r RecordSRV) bool { return roundTrips(t, r) },
+		"CAA":      func(r RecordCAA) bool { return roundTrips(t, r) },
+		"PTR":      func(r RecordPTR) bool { return roundTrips(t, r) },
+		"NS":       func(r RecordNS) bool { return roundTrips(t, r) },
+		"TLSA":     func(r RecordTLSA) bool { return roundTrips(t, r) },
+		"Redirect": func(r RecordRedirect) bool { return roundTrips(t, r) },
+		"Dynamic":  func(r RecordDynamic) bool { return roundTrips(t, r) },
+		"SSHFP":    func(r RecordSSHFP) bool { return roundTrips(t, r) },
+	}
+
+	for name, property := range properties {
+		property := property
+		t.Run(name, func(t *testing.T) {
+			if err := quick.Check(property, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUpdatePayloadFormat(t *testing.T) {
+	r := Records{
+		&RecordMX{
+			ID: 4, Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+			TTL: 10800, Priority: 10,
+		},
+	}
+
+	payload, err := EncodeUpdatePayload(r)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := `{"4":{"content":"mail.protonmail.ch","name":"@","prio":"10","ttl":"10800"}}`
+	if string(payload) != expected {
+		t.Errorf("Payload %s doesn't match expected %s", payload, expected)
+	}
+}
+
+func TestUpdatePayloadEmpty(t *testing.T) {
+	payload, err := EncodeUpdatePayload(Records{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if string(payload) != "{}" {
+		t.Errorf("Empty payload should be {}, got %s", payload)
+	}
+}
+
+func TestUpdatePayloadDuplicatedIDFails(t *testing.T) {
+	r := Records{
+		&RecordTXT{ID: 1, Type: "TXT", Name: "@", Content: "a", TTL: 300},
+		&RecordTXT{ID: 1, Type: "TXT", Name: "@", Content: "b", TTL: 300},
+	}
+
+	if _, err := EncodeUpdatePayload(r); err == nil {
+		t.Fatalf("Duplicated IDs should fail")
+	}
+}
+
+func TestDecodeUpdatePayloadInvalidID(t *testing.T) {
+	_, err := DecodeUpdatePayload([]byte(`{"abc": {"name": "@"}}`))
+	if err == nil {
+		t.Fatalf("Non numeric ID should fail")
+	}
+}
+
+func TestEncodeUpdateValuesDropsIDAndType(t *testing.T) {
+	values := map[int]url.Values{
+		7: {"id": {"7"}, "type": {"TXT"}, "name": {"@"}},
+	}
+
+	payload, err := EncodeUpdateValues(values)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if string(payload) != `{"7":{"name":"@"}}` {
+		t.Errorf("Unexpected payload %s", payload)
+	}
+}
@@ -0,0 +1,114 @@
+package records
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Predicate selects records, see Records.Filter
+type Predicate func(Record) bool
+
+// ByType selects records of a type, such as "TXT", case insensitively
+func ByType(recordType string) Predicate {
+	return func(record Record) bool {
+		return strings.EqualFold(record.GetType(), recordType)
+	}
+}
+
+// ByName selects records with a name, case insensitively. `@` and an empty
+// name both select the records of the domain itself
+func ByName(name string) Predicate {
+	name = CanonicalName("", name)
+	return func(record Record) bool {
+		return CanonicalName("", record.GetName()) == name
+	}
+}
+
+// ContentMatches selects records whose content matches a regular expression
+func ContentMatches(re *regexp.Regexp) Predicate {
+	return func(record Record) bool {
+		return re.MatchString(record.GetContent())
+	}
+}
+
+// Filter returns the records matching all the given predicates, keeping
+// their order
+func (r Records) Filter(predicates ...Predicate) Records {
+	filtered := make(Records, 0)
+
+RecordLoop:
+	for _, record := range r {
+		for _, predicate := range predicates {
+			if !predicate(record) {
+				continue RecordLoop
+			}
+		}
+		filtered = append(filtered, record)
+	}
+
+	return filtered
+}
+
+// FindByID returns the record with the given ID, and whether it was found
+func (r Records) FindByID(id int) (Record, bool) {
+	for _, record := range r {
+		if record.GetID() == id {
+			return record, true
+		}
+	}
+	return nil, false
+}
+
+// GroupByName groups the records by their lower cased name, with `@` for
+// the domain itself
+func (r Records) GroupByName() map[string]Records {
+	grouped := make(map[string]Records)
+	for _, record := range r {
+		name := CanonicalName("", record.GetName())
+		grouped[name] = append(grouped[name], record)
+	}
+	return grouped
+}
+
+// GroupByType groups the records by their type
+func (r Records) GroupByType() map[string]Records {
+	grouped := make(map[string]Records)
+	for _, record := range r {
+		grouped[record.GetType()] = append(grouped[record.GetType()], record)
+	}
+	return grouped
+}
+
+// Sort sorts the records in place by name, with `@` first, then type, then
+// content and lastly ID, so the order is always the same for the same records
+func (r Records) Sort() {
+	r.SortBy(func(a, b Record) bool {
+		aName := CanonicalName("", a.GetName())
+		bName := CanonicalName("", b.GetName())
+		if aName != bName {
+			if aName == "@" || bName == "@" {
+				return aName == "@"
+			}
+			return aName < bName
+		}
+
+		if a.GetType() != b.GetType() {
+			return a.GetType() < b.GetType()
+		}
+
+		if a.GetContent() != b.GetContent() {
+			return a.GetContent() < b.GetContent()
+		}
+
+		return a.GetID() < b.GetID()
+	})
+}
+
+// SortBy sorts the records in place with the given less function, keeping
+// the order of equal records
+func (r Records) SortBy(less func(a, b Record) bool) {
+	sort.SliceStable(r, func(i, j int) bool {
+		return less(r[i], r[j])
+	})
+}
@@ -0,0 +1,88 @@
+package records
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func queryRecords() Records {
+	return Records{
+		&RecordTXT{
+			ID: 1, Type: "TXT", Name: "_acme-challenge", Content: "token-b",
+			TTL: 60,
+		},
+		&RecordA{ID: 2, Type: "A", Name: "www", Content: "1.1.1.1", TTL: 10800},
+		&RecordTXT{
+			ID: 3, Type: "TXT", Name: "_ACME-challenge", Content: "token-a",
+			TTL: 60,
+		},
+		&RecordTXT{ID: 4, Type: "TXT", Name: "@", Content: "v=spf1", TTL: 300},
+		&RecordA{ID: 5, Type: "A", Name: "", Content: "1.1.1.1", TTL: 10800},
+	}
+}
+
+func ids(r Records) []int {
+	result := make([]int, len(r))
+	for i, record := range r {
+		result[i] = record.GetID()
+	}
+	return result
+}
+
+func TestFilter(t *testing.T) {
+	r := queryRecords()
+
+	filtered := r.Filter(
+		ByType("txt"), ByName("_acme-challenge"),
+		ContentMatches(regexp.MustCompile("^token-")),
+	)
+	if expected := []int{1, 3}; !cmp.Equal(expected, ids(filtered)) {
+		t.Errorf("Filtered IDs don't match:\n%s", cmp.Diff(expected, ids(filtered)))
+	}
+
+	if apex := r.Filter(ByName("@")); !cmp.Equal([]int{4, 5}, ids(apex)) {
+		t.Errorf("Unexpected apex records: %v", ids(apex))
+	}
+
+	if all := r.Filter(); len(all) != len(r) {
+		t.Errorf("Filter without predicates should keep every record")
+	}
+}
+
+func TestFindByID(t *testing.T) {
+	r := queryRecords()
+
+	if record, found := r.FindByID(4); !found || record.GetContent() != "v=spf1" {
+		t.Errorf("Record 4 wasn't found")
+	}
+
+	if _, found := r.FindByID(99); found {
+		t.Errorf("Record 99 shouldn't be found")
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	r := queryRecords()
+
+	byName := r.GroupByName()
+	if len(byName) != 3 || len(byName["_acme-challenge"]) != 2 ||
+		len(byName["@"]) != 2 {
+		t.Errorf("Unexpected groups by name: %+v", byName)
+	}
+
+	byType := r.GroupByType()
+	if len(byType) != 2 || len(byType["TXT"]) != 3 || len(byType["A"]) != 2 {
+		t.Errorf("Unexpected groups by type: %+v", byType)
+	}
+}
+
+func TestSort(t *testing.T) {
+	r := queryRecords()
+	r.Sort()
+
+	if expected := []int{5, 4, 3, 1, 2}; !cmp.Equal(expected, ids(r)) {
+		t.Errorf("Sorted IDs don't match:\n%s", cmp.Diff(expected, ids(r)))
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
 )
 
+// defaultCapabilities are used by the NewRecord* constructors and Validate
+var defaultCapabilities = structures.DefaultCapabilities()
+
 // Records is an array of different record types that implement the Record
 // interface
 type Records []Record
@@ -23,61 +26,86 @@ func (r *Records) UnmarshalJSON(data []byte) error {
 	}
 
 	for _, x := range raw {
-		// Unmarshal into a map to check the "type" field
-		var obj map[string]interface{}
-		err := json.Unmarshal(x, &obj)
+		actual, err := UnmarshalRecord(x)
 		if err != nil {
 			return err
 		}
+		*r = append(*r, actual)
+	}
 
-		recordType := ""
-		if t, ok := obj["type"].(string); ok {
-			recordType = t
-		} else {
-			return fmt.Errorf("Record doesn't have field type: %v", obj)
-		}
+	return nil
+}
 
-		// Unmarshal again into the correct type
-		var actual Record
-		switch recordType {
-		case "A":
-			actual = &RecordA{}
-		case "AAAA":
-			actual = &RecordAAAA{}
-		case "CNAME":
-			actual = &RecordCNAME{}
-		case "MX":
-			actual = &RecordMX{}
-		case "TXT":
-			actual = &RecordTXT{}
-		case "SRV":
-			actual = &RecordSRV{}
-		case "CAA":
-			actual = &RecordCAA{}
-		case "PTR":
-			actual = &RecordPTR{}
-		case "NS":
-			actual = &RecordNS{}
-		case "TLSA":
-			actual = &RecordTLSA{}
-		case "Redirect":
-			actual = &RecordRedirect{}
-		case "Dynamic":
-			actual = &RecordDynamic{}
-		case "SSHFP":
-			actual = &RecordSSHFP{}
-		default:
-			return fmt.Errorf("Unknown record type: %s", recordType)
-		}
+// Types are the record types Njalla supports, in the order of its website
+var Types = []string{
+	"A", "AAAA", "CNAME", "MX", "TXT", "SRV", "CAA", "PTR", "NS", "TLSA",
+	"Redirect", "Dynamic", "SSHFP",
+}
 
-		err = json.Unmarshal(x, actual)
-		if err != nil {
-			return err
-		}
-		*r = append(*r, actual)
+// NewEmpty returns an empty record of the given type, one of Types, to
+// unmarshal into
+func NewEmpty(recordType string) (Record, error) {
+	switch recordType {
+	case "A":
+		return &RecordA{}, nil
+	case "AAAA":
+		return &RecordAAAA{}, nil
+	case "CNAME":
+		return &RecordCNAME{}, nil
+	case "MX":
+		return &RecordMX{}, nil
+	case "TXT":
+		return &RecordTXT{}, nil
+	case "SRV":
+		return &RecordSRV{}, nil
+	case "CAA":
+		return &RecordCAA{}, nil
+	case "PTR":
+		return &RecordPTR{}, nil
+	case "NS":
+		return &RecordNS{}, nil
+	case "TLSA":
+		return &RecordTLSA{}, nil
+	case "Redirect":
+		return &RecordRedirect{}, nil
+	case "Dynamic":
+		return &RecordDynamic{}, nil
+	case "SSHFP":
+		return &RecordSSHFP{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown record type: %s", recordType)
 	}
+}
 
-	return nil
+// UnmarshalRecord parses a single JSON record into its specific type, going
+// by its "type" field
+func UnmarshalRecord(data []byte) (Record, error) {
+	// Unmarshal into a map to check the "type" field
+	var obj map[string]interface{}
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, err
+	}
+
+	recordType := ""
+	if t, ok := obj["type"].(string); ok {
+		recordType = t
+	} else {
+		return nil, fmt.Errorf("Record doesn't have field type: %v", obj)
+	}
+
+	// Unmarshal again into the correct type
+	actual, err := NewEmpty(recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, actual)
+	if err != nil {
+		return nil, err
+	}
+
+	return actual, nil
 }
 
 func (r Records) String() string {
@@ -93,6 +121,88 @@ func (r Records) String() string {
 type Record interface {
 	GetURLValues() url.Values
 	GetID() int
+	GetType() string
+	GetName() string
+	GetTTL() int
+	GetContent() string
+
+	// Validate checks all the record fields, returning a *ValidationError
+	// with every problem found
+	Validate() error
+	// ValidateWith is the same as Validate, but it takes the accepted enum
+	// values from the given Capabilities rather than the constants
+	ValidateWith(caps structures.Capabilities) error
+
+	// Clone returns a copy of the record that can be modified without
+	// affecting the original
+	Clone() Record
+
+	// With* methods return a modified copy of the record, leaving the
+	// original untouched. Redirect records have no TTL and Dynamic records
+	// have no content, so WithTTL and WithContent return them unchanged.
+	// The fields only some types have are set with WithPriority and the
+	// other With* functions of this package
+	WithName(name string) Record
+	WithTTL(ttl int) Record
+	WithContent(content string) Record
+}
+
+// WithPriority returns a copy of an MX or SRV record with the given priority,
+// or an error for the other types
+func WithPriority(record Record, priority int) (Record, error) {
+	if r, ok := record.(interface{ WithPriority(int) Record }); ok {
+		return r.WithPriority(priority), nil
+	}
+	return nil, fmt.Errorf("%s records don't have a priority", record.GetType())
+}
+
+// WithWeight returns a copy of an SRV record with the given weight, or an
+// error for the other types
+func WithWeight(record Record, weight uint) (Record, error) {
+	if r, ok := record.(interface{ WithWeight(uint) Record }); ok {
+		return r.WithWeight(weight), nil
+	}
+	return nil, fmt.Errorf("%s records don't have a weight", record.GetType())
+}
+
+// WithPort returns a copy of an SRV record with the given port, or an error
+// for the other types
+func WithPort(record Record, port uint) (Record, error) {
+	if r, ok := record.(interface{ WithPort(uint) Record }); ok {
+		return r.WithPort(port), nil
+	}
+	return nil, fmt.Errorf("%s records don't have a port", record.GetType())
+}
+
+// WithRedirectType returns a copy of a Redirect record with the given
+// redirect type, or an error for the other types
+func WithRedirectType(record Record, redirectType int) (Record, error) {
+	if r, ok := record.(interface{ WithRedirectType(int) Record }); ok {
+		return r.WithRedirectType(redirectType), nil
+	}
+	return nil, fmt.Errorf(
+		"%s records don't have a redirect type", record.GetType(),
+	)
+}
+
+// WithSSHAlgorithm returns a copy of an SSHFP record with the given SSH
+// algorithm, or an error for the other types
+func WithSSHAlgorithm(record Record, sshAlgorithm int) (Record, error) {
+	if r, ok := record.(interface{ WithSSHAlgorithm(int) Record }); ok {
+		return r.WithSSHAlgorithm(sshAlgorithm), nil
+	}
+	return nil, fmt.Errorf(
+		"%s records don't have an SSH algorithm", record.GetType(),
+	)
+}
+
+// WithSSHType returns a copy of an SSHFP record with the given SSH type, or
+// an error for the other types
+func WithSSHType(record Record, sshType int) (Record, error) {
+	if r, ok := record.(interface{ WithSSHType(int) Record }); ok {
+		return r.WithSSHType(sshType), nil
+	}
+	return nil, fmt.Errorf("%s records don't have an SSH type", record.GetType())
 }
 
 // RecordA represents Njalla's A record
@@ -120,18 +230,70 @@ func (r RecordA) GetID() int {
 	return r.ID
 }
 
-// NewRecordA validates and creates a new RecordA
+// GetType exposes the record type, such as "A"
+func (r RecordA) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordA) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordA) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordA) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordA) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordA) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordA) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordA) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordA creates a new RecordA, checking all its fields with Validate
 func NewRecordA(name string, content string, ttl int) (RecordA, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordA{}, ttlErr
-	}
+	return NewRecordAWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordA{
+// NewRecordAWith is the same as NewRecordA, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordAWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordA, error) {
+	r := RecordA{
 		Type:    "A",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordA{}, err
+	}
+
+	return r, nil
 }
 
 // RecordAAAA represents Njalla's AAAA record
@@ -159,18 +321,70 @@ func (r RecordAAAA) GetID() int {
 	return r.ID
 }
 
-// NewRecordAAAA validates and creates a new RecordAAAA
+// GetType exposes the record type, such as "AAAA"
+func (r RecordAAAA) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordAAAA) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordAAAA) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordAAAA) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordAAAA) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordAAAA) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordAAAA) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordAAAA) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordAAAA creates a new RecordAAAA, checking all its fields with Validate
 func NewRecordAAAA(name string, content string, ttl int) (RecordAAAA, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordAAAA{}, ttlErr
-	}
+	return NewRecordAAAAWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordAAAA{
+// NewRecordAAAAWith is the same as NewRecordAAAA, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordAAAAWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordAAAA, error) {
+	r := RecordAAAA{
 		Type:    "AAAA",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordAAAA{}, err
+	}
+
+	return r, nil
 }
 
 // RecordCNAME represents Njalla's CNAME record
@@ -198,18 +412,70 @@ func (r RecordCNAME) GetID() int {
 	return r.ID
 }
 
-// NewRecordCNAME validates and creates a new RecordCNAME
+// GetType exposes the record type, such as "CNAME"
+func (r RecordCNAME) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordCNAME) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordCNAME) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordCNAME) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordCNAME) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordCNAME) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordCNAME) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordCNAME) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordCNAME creates a new RecordCNAME, checking all its fields with Validate
 func NewRecordCNAME(name string, content string, ttl int) (RecordCNAME, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordCNAME{}, ttlErr
-	}
+	return NewRecordCNAMEWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordCNAME{
+// NewRecordCNAMEWith is the same as NewRecordCNAME, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordCNAMEWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordCNAME, error) {
+	r := RecordCNAME{
 		Type:    "CNAME",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordCNAME{}, err
+	}
+
+	return r, nil
 }
 
 // RecordMX represents Njalla's MX record
@@ -239,23 +505,80 @@ func (r RecordMX) GetID() int {
 	return r.ID
 }
 
-// NewRecordMX validates and creates a new RecordMX
-func NewRecordMX(name string, content string, ttl int, priority int) (RecordMX, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordMX{}, ttlErr
-	}
+// GetType exposes the record type, such as "MX"
+func (r RecordMX) GetType() string {
+	return r.Type
+}
 
-	if priErr := checkValidPriority(priority); priErr != nil {
-		return RecordMX{}, priErr
-	}
+// GetName exposes the record name
+func (r RecordMX) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordMX) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordMX) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordMX) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordMX) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordMX) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordMX) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
 
-	return RecordMX{
+// WithPriority returns a copy of the record with the given priority
+func (r RecordMX) WithPriority(priority int) Record {
+	r.Priority = priority
+	return &r
+}
+
+// NewRecordMX creates a new RecordMX, checking all its fields with Validate
+func NewRecordMX(name string, content string, ttl int, priority int) (RecordMX, error) {
+	return NewRecordMXWith(
+		defaultCapabilities, name, content, ttl, priority,
+	)
+}
+
+// NewRecordMXWith is the same as NewRecordMX, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordMXWith(
+	caps structures.Capabilities,
+	name string, content string, ttl int, priority int,
+) (RecordMX, error) {
+	r := RecordMX{
 		Type:     "MX",
 		Name:     name,
 		Content:  content,
 		TTL:      ttl,
 		Priority: priority,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordMX{}, err
+	}
+
+	return r, nil
 }
 
 // RecordTXT represents Njalla's TXT record
@@ -283,18 +606,70 @@ func (r RecordTXT) GetID() int {
 	return r.ID
 }
 
-// NewRecordTXT validates and creates a new RecordTXT
+// GetType exposes the record type, such as "TXT"
+func (r RecordTXT) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordTXT) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordTXT) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordTXT) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordTXT) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordTXT) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordTXT) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordTXT) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordTXT creates a new RecordTXT, checking all its fields with Validate
 func NewRecordTXT(name string, content string, ttl int) (RecordTXT, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordTXT{}, ttlErr
-	}
+	return NewRecordTXTWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordTXT{
+// NewRecordTXTWith is the same as NewRecordTXT, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordTXTWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordTXT, error) {
+	r := RecordTXT{
 		Type:    "TXT",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordTXT{}, err
+	}
+
+	return r, nil
 }
 
 // RecordSRV represents Njalla's SRV record
@@ -328,20 +703,85 @@ func (r RecordSRV) GetID() int {
 	return r.ID
 }
 
-// NewRecordSRV validates and creates a new RecordSRV
+// GetType exposes the record type, such as "SRV"
+func (r RecordSRV) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordSRV) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordSRV) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordSRV) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordSRV) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordSRV) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordSRV) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordSRV) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// WithPriority returns a copy of the record with the given priority
+func (r RecordSRV) WithPriority(priority int) Record {
+	r.Priority = priority
+	return &r
+}
+
+// WithWeight returns a copy of the record with the given weight
+func (r RecordSRV) WithWeight(weight uint) Record {
+	r.Weight = weight
+	return &r
+}
+
+// WithPort returns a copy of the record with the given port
+func (r RecordSRV) WithPort(port uint) Record {
+	r.Port = port
+	return &r
+}
+
+// NewRecordSRV creates a new RecordSRV, checking all its fields with Validate
 func NewRecordSRV(
 	name string, content string, ttl int, priority int, weight uint,
 	port uint,
 ) (RecordSRV, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordSRV{}, ttlErr
-	}
-
-	if priErr := checkValidPriority(priority); priErr != nil {
-		return RecordSRV{}, priErr
-	}
+	return NewRecordSRVWith(
+		defaultCapabilities, name, content, ttl, priority, weight, port,
+	)
+}
 
-	return RecordSRV{
+// NewRecordSRVWith is the same as NewRecordSRV, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordSRVWith(
+	caps structures.Capabilities,
+	name string, content string, ttl int, priority int, weight uint,
+	port uint,
+) (RecordSRV, error) {
+	r := RecordSRV{
 		Type:     "SRV",
 		Name:     name,
 		Content:  content,
@@ -349,7 +789,12 @@ func NewRecordSRV(
 		Priority: priority,
 		Weight:   weight,
 		Port:     port,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordSRV{}, err
+	}
+
+	return r, nil
 }
 
 // RecordCAA represents Njalla's CAA record
@@ -377,18 +822,70 @@ func (r RecordCAA) GetID() int {
 	return r.ID
 }
 
-// NewRecordCAA validates and creates a new RecordCAA
+// GetType exposes the record type, such as "CAA"
+func (r RecordCAA) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordCAA) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordCAA) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordCAA) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordCAA) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordCAA) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordCAA) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordCAA) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordCAA creates a new RecordCAA, checking all its fields with Validate
 func NewRecordCAA(name string, content string, ttl int) (RecordCAA, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordCAA{}, ttlErr
-	}
+	return NewRecordCAAWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordCAA{
+// NewRecordCAAWith is the same as NewRecordCAA, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordCAAWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordCAA, error) {
+	r := RecordCAA{
 		Type:    "CAA",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordCAA{}, err
+	}
+
+	return r, nil
 }
 
 // RecordPTR represents Njalla's PTR record
@@ -416,18 +913,70 @@ func (r RecordPTR) GetID() int {
 	return r.ID
 }
 
-// NewRecordPTR validates and creates a new RecordPTR
+// GetType exposes the record type, such as "PTR"
+func (r RecordPTR) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordPTR) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordPTR) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordPTR) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordPTR) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordPTR) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordPTR) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordPTR) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordPTR creates a new RecordPTR, checking all its fields with Validate
 func NewRecordPTR(name string, content string, ttl int) (RecordPTR, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordPTR{}, ttlErr
-	}
+	return NewRecordPTRWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordPTR{
+// NewRecordPTRWith is the same as NewRecordPTR, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordPTRWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordPTR, error) {
+	r := RecordPTR{
 		Type:    "PTR",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordPTR{}, err
+	}
+
+	return r, nil
 }
 
 // RecordNS represents Njalla's NS record
@@ -455,18 +1004,70 @@ func (r RecordNS) GetID() int {
 	return r.ID
 }
 
-// NewRecordNS validates and creates a new RecordNS
+// GetType exposes the record type, such as "NS"
+func (r RecordNS) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordNS) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordNS) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordNS) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordNS) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordNS) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordNS) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordNS) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordNS creates a new RecordNS, checking all its fields with Validate
 func NewRecordNS(name string, content string, ttl int) (RecordNS, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordNS{}, ttlErr
-	}
+	return NewRecordNSWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordNS{
+// NewRecordNSWith is the same as NewRecordNS, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordNSWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordNS, error) {
+	r := RecordNS{
 		Type:    "NS",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordNS{}, err
+	}
+
+	return r, nil
 }
 
 // RecordTLSA represents Njalla's TLSA record
@@ -494,18 +1095,70 @@ func (r RecordTLSA) GetID() int {
 	return r.ID
 }
 
-// NewRecordTLSA validates and creates a new RecordTLSA
+// GetType exposes the record type, such as "TLSA"
+func (r RecordTLSA) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordTLSA) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordTLSA) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordTLSA) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordTLSA) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordTLSA) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordTLSA) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordTLSA) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// NewRecordTLSA creates a new RecordTLSA, checking all its fields with Validate
 func NewRecordTLSA(name string, content string, ttl int) (RecordTLSA, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordTLSA{}, ttlErr
-	}
+	return NewRecordTLSAWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordTLSA{
+// NewRecordTLSAWith is the same as NewRecordTLSA, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordTLSAWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordTLSA, error) {
+	r := RecordTLSA{
 		Type:    "TLSA",
 		Name:    name,
 		Content: content,
 		TTL:     ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordTLSA{}, err
+	}
+
+	return r, nil
 }
 
 // RecordRedirect represents Njalla's Redirect record
@@ -533,18 +1186,77 @@ func (r RecordRedirect) GetID() int {
 	return r.ID
 }
 
-// NewRecordRedirect validates and creates a new RecordRedirect
+// GetType exposes the record type, such as "Redirect"
+func (r RecordRedirect) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordRedirect) GetName() string {
+	return r.Name
+}
+
+// GetTTL always returns 0 since Redirect records don't have a TTL
+func (r RecordRedirect) GetTTL() int {
+	return 0
+}
+
+// GetContent exposes the redirect URL, which Njalla stores as the content
+func (r RecordRedirect) GetContent() string {
+	return r.URL
+}
+
+// Clone returns a copy of the record
+func (r RecordRedirect) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordRedirect) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns an unmodified copy since Redirect records don't have a TTL
+func (r RecordRedirect) WithTTL(ttl int) Record {
+	return &r
+}
+
+// WithContent returns a copy of the record with the given redirect URL
+func (r RecordRedirect) WithContent(content string) Record {
+	r.URL = content
+	return &r
+}
+
+// WithRedirectType returns a copy of the record with the given redirect type
+func (r RecordRedirect) WithRedirectType(redirectType int) Record {
+	r.RedirectType = redirectType
+	return &r
+}
+
+// NewRecordRedirect creates a new RecordRedirect, checking all its fields with Validate
 func NewRecordRedirect(name string, url string, redirectType int) (RecordRedirect, error) {
-	if rtypeErr := checkValidPriority(redirectType); rtypeErr != nil {
-		return RecordRedirect{}, rtypeErr
-	}
+	return NewRecordRedirectWith(
+		defaultCapabilities, name, url, redirectType,
+	)
+}
 
-	return RecordRedirect{
+// NewRecordRedirectWith is the same as NewRecordRedirect, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordRedirectWith(
+	caps structures.Capabilities, name string, url string, redirectType int,
+) (RecordRedirect, error) {
+	r := RecordRedirect{
 		Type:         "Redirect",
 		Name:         name,
 		URL:          url,
 		RedirectType: redirectType,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordRedirect{}, err
+	}
+
+	return r, nil
 }
 
 // RecordDynamic represents Njalla's Dynamic record
@@ -570,17 +1282,70 @@ func (r RecordDynamic) GetID() int {
 	return r.ID
 }
 
-// NewRecordDynamic validates and creates a new RecordDynamic
+// GetType exposes the record type, such as "Dynamic"
+func (r RecordDynamic) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordDynamic) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordDynamic) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent always returns an empty string since Dynamic records don't have
+// any content
+func (r RecordDynamic) GetContent() string {
+	return ""
+}
+
+// Clone returns a copy of the record
+func (r RecordDynamic) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordDynamic) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordDynamic) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns an unmodified copy since Dynamic records don't have any
+// content
+func (r RecordDynamic) WithContent(content string) Record {
+	return &r
+}
+
+// NewRecordDynamic creates a new RecordDynamic, checking all its fields with Validate
 func NewRecordDynamic(name string, content string, ttl int) (RecordDynamic, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordDynamic{}, ttlErr
-	}
+	return NewRecordDynamicWith(defaultCapabilities, name, content, ttl)
+}
 
-	return RecordDynamic{
+// NewRecordDynamicWith is the same as NewRecordDynamic, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordDynamicWith(
+	caps structures.Capabilities, name string, content string, ttl int,
+) (RecordDynamic, error) {
+	r := RecordDynamic{
 		Type: "Dynamic",
 		Name: name,
 		TTL:  ttl,
-	}, nil
+	}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordDynamic{}, err
+	}
+
+	return r, nil
 }
 
 // RecordSSHFP represents Njalla's SSHFP record
@@ -612,106 +1377,139 @@ func (r RecordSSHFP) GetID() int {
 	return r.ID
 }
 
-// NewRecordSSHFP validates and creates a new RecordSSHFP
+// GetType exposes the record type, such as "SSHFP"
+func (r RecordSSHFP) GetType() string {
+	return r.Type
+}
+
+// GetName exposes the record name
+func (r RecordSSHFP) GetName() string {
+	return r.Name
+}
+
+// GetTTL exposes the record TTL
+func (r RecordSSHFP) GetTTL() int {
+	return r.TTL
+}
+
+// GetContent exposes the record content
+func (r RecordSSHFP) GetContent() string {
+	return r.Content
+}
+
+// Clone returns a copy of the record
+func (r RecordSSHFP) Clone() Record {
+	return &r
+}
+
+// WithName returns a copy of the record with the given name
+func (r RecordSSHFP) WithName(name string) Record {
+	r.Name = name
+	return &r
+}
+
+// WithTTL returns a copy of the record with the given TTL
+func (r RecordSSHFP) WithTTL(ttl int) Record {
+	r.TTL = ttl
+	return &r
+}
+
+// WithContent returns a copy of the record with the given content
+func (r RecordSSHFP) WithContent(content string) Record {
+	r.Content = content
+	return &r
+}
+
+// WithSSHAlgorithm returns a copy of the record with the given SSH algorithm
+func (r RecordSSHFP) WithSSHAlgorithm(sshAlgorithm int) Record {
+	r.SSHAlgorithm = sshAlgorithm
+	return &r
+}
+
+// WithSSHType returns a copy of the record with the given SSH type
+func (r RecordSSHFP) WithSSHType(sshType int) Record {
+	r.SSHType = sshType
+	return &r
+}
+
+// NewRecordSSHFP creates a new RecordSSHFP, checking all its fields with Validate
 func NewRecordSSHFP(
 	name string, content string, ttl int, sshAlgorithm int, sshType int,
 ) (RecordSSHFP, error) {
-	if ttlErr := checkValidTTL(ttl); ttlErr != nil {
-		return RecordSSHFP{}, ttlErr
-	}
-
-	if sshAlgErr := checkValidSSHAlgorithm(sshAlgorithm); sshAlgErr != nil {
-		return RecordSSHFP{}, sshAlgErr
-	}
-
-	if sshTypeErr := checkValidSSHType(sshType); sshTypeErr != nil {
-		return RecordSSHFP{}, sshTypeErr
-	}
+	return NewRecordSSHFPWith(
+		defaultCapabilities, name, content, ttl, sshAlgorithm, sshType,
+	)
+}
 
-	return RecordSSHFP{
+// NewRecordSSHFPWith is the same as NewRecordSSHFP, but it checks the fields
+// with ValidateWith and the given Capabilities
+func NewRecordSSHFPWith(
+	caps structures.Capabilities,
+	name string, content string, ttl int, sshAlgorithm int, sshType int,
+) (RecordSSHFP, error) {
+	r := RecordSSHFP{
 		Type:         "SSHFP",
 		Name:         name,
 		TTL:          ttl,
 		SSHAlgorithm: sshAlgorithm,
 		SSHType:      sshType,
 		Content:      content,
-	}, nil
-}
-
-func checkValidTTL(ttl int) error {
-	valid := []int{
-		structures.TTL60, structures.TTL300, structures.TTL900,
-		structures.TTL10800, structures.TTL21600, structures.TTL86400,
 	}
-
-	for _, value := range valid {
-		if ttl == value {
-			return nil
-		}
+	if err := r.ValidateWith(caps); err != nil {
+		return RecordSSHFP{}, err
 	}
 
-	return fmt.Errorf("Given TTL [%d] is not valid: %+v", ttl, valid)
+	return r, nil
 }
 
-func checkValidPriority(priority int) error {
-	valid := []int{
-		structures.PRIORITY0, structures.PRIORITY1, structures.PRIORITY5,
-		structures.PRIORITY10, structures.PRIORITY20, structures.PRIORITY30,
-		structures.PRIORITY40, structures.PRIORITY50, structures.PRIORITY60,
+func checkValidTTL(ttl int, valid []int) error {
+	if !containsInt(valid, ttl) {
+		return fmt.Errorf("Given TTL [%d] is not valid: %+v", ttl, valid)
 	}
+	return nil
+}
 
-	for _, value := range valid {
-		if priority == value {
-			return nil
-		}
+func checkValidPriority(priority int, valid []int) error {
+	if !containsInt(valid, priority) {
+		return fmt.Errorf(
+			"Given Priority [%d] is not valid: %+v", priority, valid,
+		)
 	}
-
-	return fmt.Errorf(
-		"Given Priority [%d] is not valid: %+v", priority, valid,
-	)
+	return nil
 }
 
-func checkValidRedirectType(redirectType int) error {
-	valid := []int{structures.REDIRECTTYPE301, structures.REDIRECTTYPE302}
-
-	for _, value := range valid {
-		if redirectType == value {
-			return nil
-		}
+func checkValidRedirectType(redirectType int, valid []int) error {
+	if !containsInt(valid, redirectType) {
+		return fmt.Errorf(
+			"Given Redirect Type [%d] is not valid: %+v", redirectType, valid,
+		)
 	}
-
-	return fmt.Errorf(
-		"Given Redirect Type [%d] is not valid: %+v", redirectType, valid,
-	)
+	return nil
 }
 
-func checkValidSSHAlgorithm(sshAlgorithm int) error {
-	valid := []int{
-		structures.SSHALGORITHMRSA, structures.SSHALGORITHMDSA,
-		structures.SSHALGORITHMECDSA, structures.SSHALGORITHMED25519,
+func checkValidSSHAlgorithm(sshAlgorithm int, valid []int) error {
+	if !containsInt(valid, sshAlgorithm) {
+		return fmt.Errorf(
+			"Given SSH Algorithm [%d] is not valid: %+v", sshAlgorithm, valid,
+		)
 	}
+	return nil
+}
 
-	for _, value := range valid {
-		if sshAlgorithm == value {
-			return nil
-		}
+func checkValidSSHType(sshType int, valid []int) error {
+	if !containsInt(valid, sshType) {
+		return fmt.Errorf(
+			"Given SSH Type [%d] is not valid: %+v", sshType, valid,
+		)
 	}
-
-	return fmt.Errorf(
-		"Given SSH Algorithm [%d] is not valid: %+v", sshAlgorithm, valid,
-	)
+	return nil
 }
 
-func checkValidSSHType(sshType int) error {
-	valid := []int{structures.SSHTYPESSHA1, structures.SSHTYPESSHA256}
-
-	for _, value := range valid {
-		if sshType == value {
-			return nil
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
 	}
-
-	return fmt.Errorf(
-		"Given SSH Type [%d] is not valid: %+v", sshType, valid,
-	)
+	return false
 }
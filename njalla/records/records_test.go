@@ -222,3 +222,142 @@ func TestMissingTypeFails(t *testing.T) {
 		t.Fatalf("Test didn't fail as expected")
 	}
 }
+
+func TestWithReturnsModifiedCopy(t *testing.T) {
+	original := &RecordMX{
+		ID: 4, Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+		TTL: 10800, Priority: 10,
+	}
+
+	modified := original.WithName("mail").WithTTL(300).
+		WithContent("mailsec.protonmail.ch")
+
+	expected := &RecordMX{
+		ID: 4, Type: "MX", Name: "mail", Content: "mailsec.protonmail.ch",
+		TTL: 300, Priority: 10,
+	}
+
+	if !cmp.Equal(expected, modified) {
+		t.Errorf("Modified record doesn't match:\n%s", cmp.Diff(expected, modified))
+	}
+
+	if original.Name != "@" || original.TTL != 10800 ||
+		original.Content != "mail.protonmail.ch" {
+		t.Errorf("Original record was modified: %+v", original)
+	}
+}
+
+func TestWithTypedFields(t *testing.T) {
+	srv := &RecordSRV{
+		ID: 5, Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com",
+		TTL: 300, Priority: 10, Weight: 5, Port: 5060,
+	}
+
+	modified, err := WithPriority(srv, 20)
+	if err == nil {
+		modified, err = WithWeight(modified, 1)
+	}
+	if err == nil {
+		modified, err = WithPort(modified, 5061)
+	}
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := &RecordSRV{
+		ID: 5, Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com",
+		TTL: 300, Priority: 20, Weight: 1, Port: 5061,
+	}
+	if !cmp.Equal(expected, modified) {
+		t.Errorf("Modified record doesn't match:\n%s", cmp.Diff(expected, modified))
+	}
+	if srv.Priority != 10 {
+		t.Errorf("Original record was modified: %+v", srv)
+	}
+
+	sshfp, err := WithSSHType(&RecordSSHFP{Type: "SSHFP", SSHType: 1}, 2)
+	if err != nil || sshfp.(*RecordSSHFP).SSHType != 2 {
+		t.Errorf("SSH type wasn't set: %+v, %v", sshfp, err)
+	}
+	redirect, err := WithRedirectType(&RecordRedirect{Type: "Redirect"}, 302)
+	if err != nil || redirect.(*RecordRedirect).RedirectType != 302 {
+		t.Errorf("Redirect type wasn't set: %+v, %v", redirect, err)
+	}
+
+	a := &RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300}
+	if _, err := WithPriority(a, 10); err == nil {
+		t.Errorf("Expected an error setting the priority of an A record")
+	}
+	if _, err := WithSSHAlgorithm(a, 1); err == nil {
+		t.Errorf("Expected an error setting the SSH algorithm of an A record")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	cases := []struct {
+		record  Record
+		rType   string
+		name    string
+		ttl     int
+		content string
+	}{
+		{
+			&RecordTXT{ID: 1, Type: "TXT", Name: "@", Content: "v=spf1", TTL: 300},
+			"TXT", "@", 300, "v=spf1",
+		},
+		{
+			&RecordRedirect{
+				ID: 2, Type: "Redirect", Name: "www",
+				URL: "https://example.com", RedirectType: 301,
+			},
+			"Redirect", "www", 0, "https://example.com",
+		},
+		{
+			&RecordDynamic{ID: 3, Type: "Dynamic", Name: "home", TTL: 60},
+			"Dynamic", "home", 60, "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.rType, func(t *testing.T) {
+			r := c.record
+			if r.GetType() != c.rType || r.GetName() != c.name ||
+				r.GetTTL() != c.ttl || r.GetContent() != c.content {
+				t.Errorf("Accessors don't match for %+v", r)
+			}
+
+			if clone := r.Clone(); !cmp.Equal(r, clone) {
+				t.Errorf("Clone doesn't match:\n%s", cmp.Diff(r, clone))
+			}
+		})
+	}
+}
+
+func TestUnmarshalRecord(t *testing.T) {
+	record, err := UnmarshalRecord([]byte(
+		`{"id": 5, "type": "MX", "name": "@", "content": "mail", "ttl": 300, "prio": 10}`,
+	))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := &RecordMX{
+		ID: 5, Type: "MX", Name: "@", Content: "mail", TTL: 300, Priority: 10,
+	}
+	if !cmp.Equal(expected, record) {
+		t.Errorf("Record doesn't match:\n%s", cmp.Diff(expected, record))
+	}
+
+	for _, recordType := range Types {
+		empty, err := NewEmpty(recordType)
+		if err != nil {
+			t.Errorf("%s: %s", recordType, err)
+		} else if data, _ := json.Marshal(empty); len(data) == 0 {
+			t.Errorf("%s: empty record doesn't marshal", recordType)
+		}
+	}
+
+	if _, err := UnmarshalRecord([]byte(`{"type": "SOA"}`)); err == nil {
+		t.Errorf("Expected an error for an unknown type")
+	}
+}
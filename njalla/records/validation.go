@@ -0,0 +1,491 @@
+package records
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// FieldError is a single validation problem found in a record field. Field
+// is the path to the offending field, such as `content`, or `[3].content`
+// when the error comes from ValidateZone
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError groups all the problems found while validating one or more
+// records, so they can be reported at once rather than one at a time
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Error()
+	}
+	return fmt.Sprintf(
+		"%d validation error(s): %s",
+		len(e.Errors), strings.Join(messages, "; "),
+	)
+}
+
+// ValidateZone validates every record in Records, returning a
+// *ValidationError with the problems of all of them, or nil if they're all
+// valid. Field paths are prefixed with the index of the record, like
+// `[3].content`
+func ValidateZone(r Records) error {
+	return ValidateZoneWith(r, defaultCapabilities)
+}
+
+// ValidateZoneWith is the same as ValidateZone, but it takes the accepted
+// enum values from the given Capabilities, such as those returned by
+// Provider.GetCapabilities
+func ValidateZoneWith(r Records, caps structures.Capabilities) error {
+	v := &validator{}
+	for i, record := range r {
+		err := record.ValidateWith(caps)
+		if err == nil {
+			continue
+		}
+
+		prefix := fmt.Sprintf("[%d]", i)
+		if validationErr, ok := err.(*ValidationError); ok {
+			for _, fieldErr := range validationErr.Errors {
+				v.add(prefix+"."+fieldErr.Field, fieldErr.Message)
+			}
+		} else {
+			v.add(prefix, err.Error())
+		}
+	}
+
+	return v.err()
+}
+
+// CapabilitiesGetter is implemented by clients that can get the
+// Capabilities of a domain, like provider.Provider
+type CapabilitiesGetter interface {
+	GetCapabilities(domain string) (structures.Capabilities, error)
+}
+
+// CapabilitiesOf returns the Capabilities of a domain from the client if it
+// implements CapabilitiesGetter, or the defaults if it doesn't. When getting
+// them fails, the defaults are returned along with the error
+func CapabilitiesOf(
+	client interface{}, domain string,
+) (structures.Capabilities, error) {
+	getter, ok := client.(CapabilitiesGetter)
+	if !ok {
+		return structures.DefaultCapabilities(), nil
+	}
+
+	caps, err := getter.GetCapabilities(domain)
+	if err != nil {
+		return structures.DefaultCapabilities(), err
+	}
+	return caps, nil
+}
+
+// validator collects field errors for a record
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) add(field, message string) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: message})
+}
+
+// check adds err as an error for field, if err isn't nil
+func (v *validator) check(field string, err error) {
+	if err != nil {
+		v.add(field, err.Error())
+	}
+}
+
+// err returns the collected errors as a *ValidationError, or nil if there
+// weren't any
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// Validate checks all the fields of the record
+func (r RecordA) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordA) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidIPv4(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordAAAA) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordAAAA) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidIPv6(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordCNAME) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordCNAME) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidHostname(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordMX) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordMX) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidHostname(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	v.check("prio", checkValidPriority(r.Priority, caps.Priorities))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordTXT) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordTXT) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	if len(r.Content) == 0 {
+		v.add("content", "TXT content can't be empty")
+	}
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordSRV) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordSRV) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidHostname(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	v.check("prio", checkValidPriority(r.Priority, caps.Priorities))
+	if r.Weight > 65535 {
+		v.add("weight", fmt.Sprintf(
+			"Weight [%d] is out of range 0-65535", r.Weight,
+		))
+	}
+	if r.Port < 1 || r.Port > 65535 {
+		v.add("port", fmt.Sprintf(
+			"Port [%d] is out of range 1-65535", r.Port,
+		))
+	}
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordCAA) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordCAA) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidCAAContent(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordPTR) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordPTR) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidHostname(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordNS) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordNS) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidHostname(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordTLSA) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordTLSA) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidTLSAContent(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordRedirect) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordRedirect) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidRedirectURL(r.URL))
+	v.check("prio", checkValidRedirectType(r.RedirectType, caps.RedirectTypes))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordDynamic) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordDynamic) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	return v.err()
+}
+
+// Validate checks all the fields of the record
+func (r RecordSSHFP) Validate() error {
+	return r.ValidateWith(defaultCapabilities)
+}
+
+// ValidateWith checks all the fields of the record, using the given
+// Capabilities for the enum fields
+func (r RecordSSHFP) ValidateWith(caps structures.Capabilities) error {
+	v := &validator{}
+	v.check("name", checkValidName(r.Name))
+	v.check("content", checkValidHex(r.Content))
+	v.check("ttl", checkValidTTL(r.TTL, caps.TTLs))
+	v.check("ssh_algorithm", checkValidSSHAlgorithm(r.SSHAlgorithm, caps.SSHAlgorithms))
+	v.check("ssh_type", checkValidSSHType(r.SSHType, caps.SSHTypes))
+	return v.err()
+}
+
+func checkValidIPv4(content string) error {
+	ip := net.ParseIP(content)
+	if ip == nil || ip.To4() == nil || strings.Contains(content, ":") {
+		return fmt.Errorf("Given content [%s] is not a valid IPv4 address", content)
+	}
+	return nil
+}
+
+func checkValidIPv6(content string) error {
+	ip := net.ParseIP(content)
+	if ip == nil || !strings.Contains(content, ":") {
+		return fmt.Errorf("Given content [%s] is not a valid IPv6 address", content)
+	}
+	return nil
+}
+
+// checkValidName checks a record name, which is either `@` for the domain
+// itself, or a name relative to the domain. Underscores are allowed for
+// names like `_acme-challenge`, and the first label may be a `*` wildcard
+func checkValidName(name string) error {
+	if name == "@" {
+		return nil
+	}
+
+	if err := checkValidLabels(name, true); err != nil {
+		return fmt.Errorf("Given name [%s] is not valid: %s", name, err)
+	}
+	return nil
+}
+
+// checkValidHostname checks the target of records like CNAME or MX, which
+// may be fully qualified with a trailing dot
+func checkValidHostname(hostname string) error {
+	if err := checkValidLabels(strings.TrimSuffix(hostname, "."), false); err != nil {
+		return fmt.Errorf("Given hostname [%s] is not valid: %s", hostname, err)
+	}
+	return nil
+}
+
+func checkValidLabels(name string, allowWildcard bool) error {
+	if len(name) == 0 {
+		return fmt.Errorf("it is empty")
+	}
+
+	if len(name) > 253 {
+		return fmt.Errorf("it is longer than 253 characters")
+	}
+
+	for i, label := range strings.Split(name, ".") {
+		if label == "*" && allowWildcard && i == 0 {
+			continue
+		}
+
+		if len(label) == 0 {
+			return fmt.Errorf("it has an empty label")
+		}
+
+		if len(label) > 63 {
+			return fmt.Errorf("label [%s] is longer than 63 characters", label)
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("label [%s] starts or ends with a hyphen", label)
+		}
+
+		for _, c := range label {
+			isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+				(c >= '0' && c <= '9')
+			if !isAlnum && c != '-' && c != '_' {
+				return fmt.Errorf(
+					"label [%s] has invalid character [%c]", label, c,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkValidHex(content string) error {
+	if len(content) == 0 {
+		return fmt.Errorf("Given content is empty")
+	}
+
+	if _, err := hex.DecodeString(content); err != nil {
+		return fmt.Errorf("Given content [%s] is not valid hex", content)
+	}
+	return nil
+}
+
+// checkValidCAAContent checks a CAA content such as `0 issue "letsencrypt.org"`
+func checkValidCAAContent(content string) error {
+	parts := strings.SplitN(content, " ", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf(
+			"Given CAA content [%s] should be `<flags> <tag> <value>`", content,
+		)
+	}
+
+	if _, err := checkUintRange(parts[0], 255); err != nil {
+		return fmt.Errorf("Given CAA flags [%s] are not valid: %s", parts[0], err)
+	}
+
+	switch parts[1] {
+	case "issue", "issuewild", "iodef":
+	default:
+		return fmt.Errorf(
+			"Given CAA tag [%s] is not valid: [issue issuewild iodef]", parts[1],
+		)
+	}
+
+	return nil
+}
+
+// checkValidTLSAContent checks a TLSA content such as `3 1 1 <hex data>`
+func checkValidTLSAContent(content string) error {
+	parts := strings.Fields(content)
+	if len(parts) != 4 {
+		return fmt.Errorf(
+			"Given TLSA content [%s] should be `<usage> <selector> <matching type> <data>`",
+			content,
+		)
+	}
+
+	limits := []struct {
+		field string
+		max   int
+	}{{"usage", 3}, {"selector", 1}, {"matching type", 2}}
+
+	for i, limit := range limits {
+		if _, err := checkUintRange(parts[i], limit.max); err != nil {
+			return fmt.Errorf(
+				"Given TLSA %s [%s] is not valid: %s", limit.field, parts[i], err,
+			)
+		}
+	}
+
+	return checkValidHex(parts[3])
+}
+
+func checkValidRedirectURL(content string) error {
+	u, err := url.Parse(content)
+	if err != nil || u.Host == "" ||
+		(u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf(
+			"Given redirect URL [%s] is not a valid http(s) URL", content,
+		)
+	}
+	return nil
+}
+
+func checkUintRange(value string, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("expected a number in range 0-%d", max)
+	}
+	return n, nil
+}
@@ -0,0 +1,218 @@
+package records
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+func TestValidRecordsPass(t *testing.T) {
+	r := Records{
+		&RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 3600},
+		&RecordAAAA{
+			Type: "AAAA", Name: "www",
+			Content: "2001:0db8:85a3:0000:0000:8a2e:0370:7334", TTL: 10800,
+		},
+		&RecordCNAME{
+			Type: "CNAME", Name: "*.dev", Content: "example.com.", TTL: 10800,
+		},
+		&RecordMX{
+			Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+			TTL: 10800, Priority: 10,
+		},
+		&RecordTXT{
+			Type: "TXT", Name: "_acme-challenge", Content: "token", TTL: 60,
+		},
+		&RecordSRV{
+			Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com",
+			TTL: 10800, Priority: 10, Weight: 5, Port: 5060,
+		},
+		&RecordCAA{
+			Type: "CAA", Name: "@", Content: `0 issue "letsencrypt.org"`,
+			TTL: 10800,
+		},
+		&RecordPTR{Type: "PTR", Name: "1", Content: "example.com", TTL: 10800},
+		&RecordNS{
+			Type: "NS", Name: "sub", Content: "3-get.njalla.fo", TTL: 10800,
+		},
+		&RecordTLSA{
+			Type: "TLSA", Name: "_443._tcp", Content: "3 1 1 ABCDEF0123",
+			TTL: 10800,
+		},
+		&RecordRedirect{
+			Type: "Redirect", Name: "@", URL: "https://example.com",
+			RedirectType: 301,
+		},
+		&RecordDynamic{Type: "Dynamic", Name: "home", TTL: 60},
+		&RecordSSHFP{
+			Type: "SSHFP", Name: "@", Content: "abcdef0123", TTL: 10800,
+			SSHAlgorithm: 4, SSHType: 2,
+		},
+	}
+
+	if err := ValidateZone(r); err != nil {
+		t.Errorf("Valid zone failed validation: %s", err)
+	}
+}
+
+func TestValidateZoneReportsAllErrors(t *testing.T) {
+	r := Records{
+		&RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&RecordA{Type: "A", Name: "-bad", Content: "::1", TTL: 10800},
+		&RecordAAAA{Type: "AAAA", Name: "@", Content: "1.1.1.1", TTL: 1},
+		&RecordSRV{
+			Type: "SRV", Name: "_sip._tcp", Content: "sip..example.com",
+			TTL: 10800, Priority: 10, Port: 70000,
+		},
+		&RecordRedirect{
+			Type: "Redirect", Name: "@", URL: "example.com", RedirectType: 10,
+		},
+	}
+
+	err := ValidateZone(r)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+
+	fields := make([]string, len(validationErr.Errors))
+	for i, fieldErr := range validationErr.Errors {
+		fields[i] = fieldErr.Field
+	}
+
+	expected := []string{
+		"[1].name", "[1].content",
+		"[2].content", "[2].ttl",
+		"[3].content", "[3].port",
+		"[4].content", "[4].prio",
+	}
+
+	if !cmp.Equal(expected, fields) {
+		t.Errorf("Field paths don't match:\n%s", cmp.Diff(expected, fields))
+	}
+}
+
+func TestLongLabelsFail(t *testing.T) {
+	long := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	record := RecordCNAME{
+		Type: "CNAME", Name: long, Content: "example.com", TTL: 10800,
+	}
+
+	if err := record.Validate(); err == nil {
+		t.Errorf("Label longer than 63 characters should fail")
+	}
+}
+
+func TestConstructorsAcceptTTL3600(t *testing.T) {
+	if _, err := NewRecordA("@", "1.1.1.1", 3600); err != nil {
+		t.Errorf("TTL 3600 should be valid: %s", err)
+	}
+}
+
+func TestRedirectConstructorChecksRedirectType(t *testing.T) {
+	if _, err := NewRecordRedirect("@", "https://example.com", 301); err != nil {
+		t.Errorf("Redirect type 301 should be valid: %s", err)
+	}
+
+	if _, err := NewRecordRedirect("@", "https://example.com", 10); err == nil {
+		t.Errorf("Redirect type 10 should be invalid")
+	}
+}
+
+func TestConstructorsValidateContent(t *testing.T) {
+	if _, err := NewRecordA("@", "not an IP", 3600); err == nil {
+		t.Errorf("A record with an invalid address should fail")
+	}
+	if _, err := NewRecordCNAME("www", "not a host!", 3600); err == nil {
+		t.Errorf("CNAME record with an invalid target should fail")
+	}
+	if _, err := NewRecordMX("@", "", 3600, 10); err == nil {
+		t.Errorf("MX record without a host should fail")
+	}
+	if _, err := NewRecordSRV("_sip._tcp", "sip.example.com", 3600, 10, 5, 0); err == nil {
+		t.Errorf("SRV record with port 0 should fail")
+	}
+}
+
+func TestConstructorsReportAllErrors(t *testing.T) {
+	_, err := NewRecordMX("@", "mail.example.com", 7200, 15)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+
+	fields := make([]string, len(validationErr.Errors))
+	for i, fieldErr := range validationErr.Errors {
+		fields[i] = fieldErr.Field
+	}
+	if expected := []string{"ttl", "prio"}; !cmp.Equal(expected, fields) {
+		t.Errorf("Fields don't match:\n%s", cmp.Diff(expected, fields))
+	}
+}
+
+func TestConstructorsWithCapabilities(t *testing.T) {
+	caps := structures.DefaultCapabilities()
+	caps.TTLs = []int{60, 43200}
+
+	if _, err := NewRecordAWith(caps, "@", "1.1.1.1", 43200); err != nil {
+		t.Errorf("TTL 43200 should be valid for the capabilities: %s", err)
+	}
+	if _, err := NewRecordAWith(caps, "@", "1.1.1.1", 3600); err == nil {
+		t.Errorf("TTL 3600 should be invalid for the capabilities")
+	}
+}
+
+func TestValidateWithCapabilities(t *testing.T) {
+	record := RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 43200}
+
+	if err := record.Validate(); err == nil {
+		t.Errorf("TTL 43200 shouldn't be valid with the default capabilities")
+	}
+
+	caps := structures.DefaultCapabilities()
+	caps.TTLs = append(caps.TTLs, 43200)
+
+	if err := record.ValidateWith(caps); err != nil {
+		t.Errorf("TTL 43200 should be valid with the given capabilities: %s", err)
+	}
+
+	if err := ValidateZoneWith(Records{&record}, caps); err != nil {
+		t.Errorf("Zone should be valid with the given capabilities: %s", err)
+	}
+}
+
+type capabilitiesClient struct {
+	caps structures.Capabilities
+	err  error
+}
+
+func (c capabilitiesClient) GetCapabilities(
+	domain string,
+) (structures.Capabilities, error) {
+	return c.caps, c.err
+}
+
+func TestCapabilitiesOf(t *testing.T) {
+	defaults := structures.DefaultCapabilities()
+	live := structures.DefaultCapabilities()
+	live.TTLs = []int{60, 43200}
+
+	caps, err := CapabilitiesOf(struct{}{}, "example.com")
+	if err != nil || !cmp.Equal(defaults, caps) {
+		t.Errorf("Expected the defaults without a getter, got %+v, %v", caps, err)
+	}
+
+	caps, err = CapabilitiesOf(capabilitiesClient{caps: live}, "example.com")
+	if err != nil || !cmp.Equal(live, caps) {
+		t.Errorf("Expected the client capabilities, got %+v, %v", caps, err)
+	}
+
+	failing := capabilitiesClient{caps: live, err: fmt.Errorf("Expired")}
+	caps, err = CapabilitiesOf(failing, "example.com")
+	if err == nil || !cmp.Equal(defaults, caps) {
+		t.Errorf("Expected the defaults and an error, got %+v, %v", caps, err)
+	}
+}
@@ -0,0 +1,128 @@
+package records
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// txtChunkSize is the maximum length of a single character-string in a TXT
+// record, as defined in RFC 1035
+const txtChunkSize = 255
+
+// WriteZoneFile renders Records as an RFC 1035 zone file for the given
+// origin, such as `mydomain.com`. Njalla only types with no DNS counterpart,
+// like Redirect and Dynamic, are written as comments so the file is still
+// valid while keeping a trace of them
+func (r Records) WriteZoneFile(w io.Writer, origin string) error {
+	buf := bufio.NewWriter(w)
+
+	fmt.Fprintf(buf, "$ORIGIN %s\n", fqdn(origin))
+	for _, record := range r {
+		line, supported := zoneLine(record)
+		if !supported {
+			fmt.Fprintf(buf, "; %s\n", line)
+			continue
+		}
+		fmt.Fprintln(buf, line)
+	}
+
+	return buf.Flush()
+}
+
+// zoneLine renders a single record as a zone file line. If the record type
+// has no DNS counterpart, it returns a description of the record instead and
+// false
+func zoneLine(record Record) (string, bool) {
+	name := record.GetName()
+	if name == "" {
+		name = "@"
+	}
+
+	// Go through url.Values for the type specific fields so both records and
+	// pointers to records are handled
+	values := record.GetURLValues()
+	content := record.GetContent()
+
+	var rdata string
+	switch record.GetType() {
+	case "A", "AAAA", "CAA", "TLSA":
+		rdata = content
+	case "CNAME", "PTR", "NS":
+		rdata = fqdn(content)
+	case "MX":
+		rdata = fmt.Sprintf("%s %s", values.Get("prio"), fqdn(content))
+	case "TXT":
+		rdata = quoteTXT(content)
+	case "SRV":
+		rdata = fmt.Sprintf(
+			"%s %s %s %s", values.Get("prio"), values.Get("weight"),
+			values.Get("port"), fqdn(content),
+		)
+	case "SSHFP":
+		rdata = fmt.Sprintf(
+			"%s %s %s", values.Get("ssh_algorithm"), values.Get("ssh_type"),
+			content,
+		)
+	case "Redirect":
+		return fmt.Sprintf(
+			"Njalla Redirect: %s %s %s", name, values.Get("prio"), content,
+		), false
+	case "Dynamic":
+		return fmt.Sprintf("Njalla Dynamic: %s %d", name, record.GetTTL()), false
+	default:
+		return fmt.Sprintf(
+			"Unsupported record type %s: %s", record.GetType(), name,
+		), false
+	}
+
+	return fmt.Sprintf(
+		"%s\t%d\tIN\t%s\t%s", name, record.GetTTL(), record.GetType(), rdata,
+	), true
+}
+
+// fqdn makes a hostname absolute by adding the trailing dot, since Njalla
+// takes record contents as absolute names
+func fqdn(hostname string) string {
+	if strings.HasSuffix(hostname, ".") {
+		return hostname
+	}
+	return hostname + "."
+}
+
+// quoteTXT splits TXT content into quoted character-strings of at most 255
+// bytes, escaping quotes, backslashes and non printable bytes
+func quoteTXT(content string) string {
+	if len(content) == 0 {
+		return `""`
+	}
+
+	chunks := make([]string, 0, len(content)/txtChunkSize+1)
+	for start := 0; start < len(content); start += txtChunkSize {
+		end := start + txtChunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+
+		var b strings.Builder
+		b.WriteByte('"')
+		for i := start; i < end; i++ {
+			c := content[i]
+			switch {
+			case c == '"' || c == '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case c < ' ' || c > '~':
+				fmt.Fprintf(&b, "\\%03d", c)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+
+		chunks = append(chunks, b.String())
+	}
+
+	return strings.Join(chunks, " ")
+}
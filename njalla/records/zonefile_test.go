@@ -0,0 +1,91 @@
+package records
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteZoneFile(t *testing.T) {
+	r := Records{
+		&RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&RecordAAAA{
+			ID: 2, Type: "AAAA", Name: "www", Content: "2001:db8::1", TTL: 300,
+		},
+		&RecordCNAME{
+			ID: 3, Type: "CNAME", Name: "blog", Content: "example.org", TTL: 60,
+		},
+		&RecordMX{
+			ID: 4, Type: "MX", Name: "@", Content: "mail.protonmail.ch",
+			TTL: 10800, Priority: 10,
+		},
+		&RecordTXT{
+			ID: 5, Type: "TXT", Name: "@", Content: `v=spf1 "quoted" \ ~all`,
+			TTL: 10800,
+		},
+		&RecordSRV{
+			ID: 6, Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com.",
+			TTL: 10800, Priority: 10, Weight: 5, Port: 5060,
+		},
+		&RecordCAA{
+			ID: 7, Type: "CAA", Name: "@", Content: `0 issue "letsencrypt.org"`,
+			TTL: 10800,
+		},
+		&RecordPTR{ID: 8, Type: "PTR", Name: "1", Content: "example.com", TTL: 10800},
+		&RecordNS{
+			ID: 9, Type: "NS", Name: "sub", Content: "3-get.njalla.fo", TTL: 10800,
+		},
+		&RecordTLSA{
+			ID: 10, Type: "TLSA", Name: "_443._tcp", Content: "3 1 1 ABCDEF",
+			TTL: 10800,
+		},
+		&RecordRedirect{
+			ID: 11, Type: "Redirect", Name: "@", URL: "https://example.org",
+			RedirectType: 301,
+		},
+		&RecordDynamic{ID: 12, Type: "Dynamic", Name: "home", TTL: 60},
+		&RecordSSHFP{
+			ID: 13, Type: "SSHFP", Name: "@", Content: "abcdef", TTL: 10800,
+			SSHAlgorithm: 4, SSHType: 2,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteZoneFile(&buf, "example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.com.",
+		"@\t10800\tIN\tA\t1.1.1.1",
+		"www\t300\tIN\tAAAA\t2001:db8::1",
+		"blog\t60\tIN\tCNAME\texample.org.",
+		"@\t10800\tIN\tMX\t10 mail.protonmail.ch.",
+		`@	10800	IN	TXT	"v=spf1 \"quoted\" \\ ~all"`,
+		"_sip._tcp\t10800\tIN\tSRV\t10 5 5060 sip.example.com.",
+		"@\t10800\tIN\tCAA\t0 issue \"letsencrypt.org\"",
+		"1\t10800\tIN\tPTR\texample.com.",
+		"sub\t10800\tIN\tNS\t3-get.njalla.fo.",
+		"_443._tcp\t10800\tIN\tTLSA\t3 1 1 ABCDEF",
+		"; Njalla Redirect: @ 301 https://example.org",
+		"; Njalla Dynamic: home 60",
+		"@\t10800\tIN\tSSHFP\t4 2 abcdef",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != expected {
+		t.Errorf("Zone file doesn't match:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestQuoteTXTChunks(t *testing.T) {
+	content := strings.Repeat("a", 300)
+	expected := `"` + strings.Repeat("a", 255) + `" "` +
+		strings.Repeat("a", 45) + `"`
+
+	if got := quoteTXT(content); got != expected {
+		t.Errorf("TXT chunks don't match:\n%s", cmp.Diff(expected, got))
+	}
+}
@@ -0,0 +1,439 @@
+package records
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// ImportIssue is a zone file entry that couldn't be imported as it was
+type ImportIssue struct {
+	Entry   string
+	Message string
+	// Skipped is true if the entry wasn't imported at all, rather than
+	// imported with some change such as a different TTL
+	Skipped bool
+}
+
+func (i ImportIssue) String() string {
+	action := "adjusted"
+	if i.Skipped {
+		action = "skipped"
+	}
+	return fmt.Sprintf("%s: %s (%s)", action, i.Message, i.Entry)
+}
+
+// ImportReport lists all the issues found while importing a zone file
+type ImportReport struct {
+	Issues []ImportIssue
+}
+
+func (r *ImportReport) add(rr dns.RR, skipped bool, format string, a ...interface{}) {
+	r.Issues = append(r.Issues, ImportIssue{
+		Entry:   strings.Replace(rr.String(), "\t", " ", -1),
+		Message: fmt.Sprintf(format, a...),
+		Skipped: skipped,
+	})
+}
+
+// ParseZoneFile parses an RFC 1035 zone file into Records, through the
+// NewRecord* constructors. $ORIGIN and $TTL directives are honoured, and
+// origin is used for relative names until an $ORIGIN is found.
+//
+// Entries Njalla can't hold, such as SOA records or unsupported types, are
+// skipped and listed in the returned report. TTLs and MX and SRV priorities
+// Njalla doesn't accept are changed to the closest one it does, and also
+// listed in the report. Entries whose priority can't be changed without
+// changing their order among the other records of their RRset are skipped
+func ParseZoneFile(r io.Reader, origin string) (Records, *ImportReport, error) {
+	return ParseZoneFileWith(r, origin, defaultCapabilities)
+}
+
+// ParseZoneFileWith is the same as ParseZoneFile, but it takes the accepted
+// enum values from the given Capabilities, such as those returned by
+// Provider.GetCapabilities
+func ParseZoneFileWith(
+	r io.Reader, origin string, caps structures.Capabilities,
+) (Records, *ImportReport, error) {
+	zoneOrigin := dns.Fqdn(strings.ToLower(origin))
+	parser := dns.NewZoneParser(r, zoneOrigin, "")
+
+	entries := make([]importEntry, 0)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		record, err := FromRRWith(rr, zoneOrigin, caps)
+		entries = append(entries, importEntry{rr: rr, record: record, err: err})
+	}
+
+	if err := parser.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	reordered := reorderedPriorities(entries)
+
+	result := make(Records, 0)
+	report := &ImportReport{}
+
+	for i, e := range entries {
+		if e.err != nil {
+			report.add(e.rr, true, "%s", e.err)
+			continue
+		}
+
+		priority, hasPriority := rrPriority(e.rr)
+		if reordered[i] {
+			report.add(
+				e.rr, true,
+				"Priority %d would become %d, which changes its order among "+
+					"the other %s records of the name",
+				priority, recordPriority(e.record), e.record.GetType(),
+			)
+			continue
+		}
+
+		if ttl := int(e.rr.Header().Ttl); ttl != e.record.GetTTL() {
+			report.add(
+				e.rr, false, "TTL %d isn't accepted by Njalla, using %d",
+				ttl, e.record.GetTTL(),
+			)
+		}
+
+		if adjusted := recordPriority(e.record); hasPriority &&
+			adjusted != priority {
+			report.add(
+				e.rr, false, "Priority %d isn't accepted by Njalla, using %d",
+				priority, adjusted,
+			)
+		}
+
+		result = append(result, e.record)
+	}
+
+	return result, report, nil
+}
+
+// FromRR converts a DNS resource record into a Record for the given zone,
+// through the NewRecord* constructors. The TTL, and the priority of MX and
+// SRV records, are changed to the closest ones Njalla accepts
+func FromRR(rr dns.RR, origin string) (Record, error) {
+	return FromRRWith(rr, origin, defaultCapabilities)
+}
+
+// FromRRWith is the same as FromRR, but through the NewRecord*With
+// constructors with the given Capabilities, such as those returned by
+// Provider.GetCapabilities
+func FromRRWith(
+	rr dns.RR, origin string, caps structures.Capabilities,
+) (Record, error) {
+	header := rr.Header()
+	origin = dns.Fqdn(strings.ToLower(origin))
+
+	name, err := relativeName(header.Name, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ClosestTTLWith(caps, int(header.Ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	priority, hasPriority := rrPriority(rr)
+	if hasPriority {
+		if priority, err = ClosestPriorityWith(caps, priority); err != nil {
+			return nil, err
+		}
+	}
+
+	switch v := rr.(type) {
+	case *dns.A:
+		return wrap(NewRecordAWith(caps, name, v.A.String(), ttl))
+	case *dns.AAAA:
+		return wrap(NewRecordAAAAWith(caps, name, v.AAAA.String(), ttl))
+	case *dns.CNAME:
+		return wrap(NewRecordCNAMEWith(caps, name, unFqdn(v.Target), ttl))
+	case *dns.MX:
+		return wrap(NewRecordMXWith(caps, name, unFqdn(v.Mx), ttl, priority))
+	case *dns.TXT:
+		return wrap(NewRecordTXTWith(caps, name, unescapeTXT(v.Txt), ttl))
+	case *dns.SRV:
+		return wrap(NewRecordSRVWith(
+			caps, name, unFqdn(v.Target), ttl, priority, uint(v.Weight),
+			uint(v.Port),
+		))
+	case *dns.CAA:
+		return wrap(NewRecordCAAWith(
+			caps, name, fmt.Sprintf("%d %s %q", v.Flag, v.Tag, v.Value), ttl,
+		))
+	case *dns.PTR:
+		return wrap(NewRecordPTRWith(caps, name, unFqdn(v.Ptr), ttl))
+	case *dns.NS:
+		if name == "@" {
+			return nil, fmt.Errorf("NS records for the domain are managed by Njalla")
+		}
+		return wrap(NewRecordNSWith(caps, name, unFqdn(v.Ns), ttl))
+	case *dns.TLSA:
+		return wrap(NewRecordTLSAWith(
+			caps, name,
+			fmt.Sprintf(
+				"%d %d %d %s", v.Usage, v.Selector, v.MatchingType,
+				v.Certificate,
+			),
+			ttl,
+		))
+	case *dns.SSHFP:
+		return wrap(NewRecordSSHFPWith(
+			caps, name, v.FingerPrint, ttl, int(v.Algorithm), int(v.Type),
+		))
+	default:
+		return nil, fmt.Errorf(
+			"Record type %s isn't supported by Njalla",
+			dns.TypeToString[header.Rrtype],
+		)
+	}
+}
+
+// ToRR converts a Record into a DNS resource record for the given zone, as
+// it would be served by Njalla's name servers. Njalla only types with no DNS
+// counterpart, like Redirect and Dynamic, can't be converted
+func ToRR(record Record, origin string) (dns.RR, error) {
+	line, supported := zoneLine(record)
+	if !supported {
+		return nil, fmt.Errorf("%s has no DNS counterpart", line)
+	}
+
+	parser := dns.NewZoneParser(strings.NewReader(line), dns.Fqdn(origin), "")
+	rr, ok := parser.Next()
+	if !ok {
+		if err := parser.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Couldn't convert record: %s", line)
+	}
+
+	return rr, nil
+}
+
+// wrap turns the result of a NewRecord* constructor into a Record pointer,
+// the same as those returned when parsing Njalla's records
+func wrap(record interface{}, err error) (Record, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := record.(type) {
+	case RecordA:
+		return &r, nil
+	case RecordAAAA:
+		return &r, nil
+	case RecordCNAME:
+		return &r, nil
+	case RecordMX:
+		return &r, nil
+	case RecordTXT:
+		return &r, nil
+	case RecordSRV:
+		return &r, nil
+	case RecordCAA:
+		return &r, nil
+	case RecordPTR:
+		return &r, nil
+	case RecordNS:
+		return &r, nil
+	case RecordTLSA:
+		return &r, nil
+	case RecordRedirect:
+		return &r, nil
+	case RecordDynamic:
+		return &r, nil
+	case RecordSSHFP:
+		return &r, nil
+	default:
+		return nil, fmt.Errorf("Unexpected record %+v", record)
+	}
+}
+
+// relativeName converts an absolute name into one relative to origin, the
+// way Njalla expects record names, such as `www` or `@`
+func relativeName(name, origin string) (string, error) {
+	name = strings.ToLower(name)
+
+	if name == origin {
+		return "@", nil
+	}
+
+	if !strings.HasSuffix(name, "."+origin) {
+		return "", fmt.Errorf("Name %s is outside of the zone %s", name, origin)
+	}
+
+	return strings.TrimSuffix(name, "."+origin), nil
+}
+
+// unescapeTXT joins the character-strings of a TXT record, undoing the
+// `\X` and `\DDD` escapes miekg/dns keeps in them
+func unescapeTXT(txt []string) string {
+	var b strings.Builder
+	for _, chunk := range txt {
+		for i := 0; i < len(chunk); i++ {
+			c := chunk[i]
+			if c != '\\' || i+1 == len(chunk) {
+				b.WriteByte(c)
+				continue
+			}
+
+			if i+3 < len(chunk) && isDigits(chunk[i+1:i+4]) {
+				value, _ := strconv.Atoi(chunk[i+1 : i+4])
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+
+			b.WriteByte(chunk[i+1])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func unFqdn(hostname string) string {
+	return strings.TrimSuffix(hostname, ".")
+}
+
+// ClosestTTL returns the TTL Njalla accepts closest to the given one, in
+// seconds, preferring the highest one on ties
+func ClosestTTL(ttl int) int {
+	closest, _ := ClosestTTLWith(defaultCapabilities, ttl)
+	return closest
+}
+
+// ClosestTTLWith is the same as ClosestTTL, but it takes the accepted TTLs
+// from the given Capabilities. It fails if there are none
+func ClosestTTLWith(caps structures.Capabilities, ttl int) (int, error) {
+	return closestValue("TTL", ttl, caps.TTLs)
+}
+
+// ClosestPriority returns the MX and SRV priority Njalla accepts closest to
+// the given one, preferring the highest one on ties
+func ClosestPriority(priority int) int {
+	closest, _ := ClosestPriorityWith(defaultCapabilities, priority)
+	return closest
+}
+
+// ClosestPriorityWith is the same as ClosestPriority, but it takes the
+// accepted priorities from the given Capabilities. It fails if there are none
+func ClosestPriorityWith(
+	caps structures.Capabilities, priority int,
+) (int, error) {
+	return closestValue("priority", priority, caps.Priorities)
+}
+
+// importEntry is a zone file entry and the result of converting it
+type importEntry struct {
+	rr     dns.RR
+	record Record
+	err    error
+}
+
+// reorderedPriorities returns the indexes of the entries whose priority was
+// changed in a way that changes their order within their RRset, such as 15
+// and 20 both becoming 20
+func reorderedPriorities(entries []importEntry) map[int]bool {
+	rrsets := make(map[string][]int)
+	for i, e := range entries {
+		if _, ok := rrPriority(e.rr); ok && e.err == nil {
+			header := e.rr.Header()
+			key := fmt.Sprintf(
+				"%s %d", strings.ToLower(header.Name), header.Rrtype,
+			)
+			rrsets[key] = append(rrsets[key], i)
+		}
+	}
+
+	reordered := make(map[int]bool)
+	for _, rrset := range rrsets {
+		for _, i := range rrset {
+			original, _ := rrPriority(entries[i].rr)
+			adjusted := recordPriority(entries[i].record)
+			if original == adjusted {
+				continue
+			}
+
+			for _, j := range rrset {
+				otherOriginal, _ := rrPriority(entries[j].rr)
+				otherAdjusted := recordPriority(entries[j].record)
+				if sign(original-otherOriginal) != sign(adjusted-otherAdjusted) {
+					reordered[i] = true
+					break
+				}
+			}
+		}
+	}
+	return reordered
+}
+
+// recordPriority returns the priority of a Record, or 0 if it has none
+func recordPriority(record Record) int {
+	priority, _ := strconv.Atoi(record.GetURLValues().Get("prio"))
+	return priority
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// rrPriority returns the priority of MX and SRV resource records
+func rrPriority(rr dns.RR) (int, bool) {
+	switch v := rr.(type) {
+	case *dns.MX:
+		return int(v.Preference), true
+	case *dns.SRV:
+		return int(v.Priority), true
+	default:
+		return 0, false
+	}
+}
+
+// closestValue returns the value in valid closest to value, preferring the
+// highest one on ties. It fails if valid is empty, such as when scraping the
+// capabilities found none
+func closestValue(field string, value int, valid []int) (int, error) {
+	if len(valid) == 0 {
+		return 0, fmt.Errorf("There's no accepted %s to use for %d", field, value)
+	}
+
+	closest := valid[0]
+	for _, v := range valid {
+		if abs(v-value) < abs(closest-value) ||
+			(abs(v-value) == abs(closest-value) && v > closest) {
+			closest = v
+		}
+	}
+	return closest, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,331 @@
+package records
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+func TestParseZoneFile(t *testing.T) {
+	zone := `$TTL 3600
+@	IN	SOA	ns1.example.org. admin.example.com. 1 7200 3600 1209600 3600
+@	IN	NS	ns1.example.org.
+@	IN	A	1.1.1.1
+www	300	IN	AAAA	2001:db8::1
+blog.example.com.	IN	CNAME	example.org.
+@	IN	MX	10 mail.protonmail.ch.
+@	IN	TXT	"v=spf1 include:_spf.protonmail.ch" " ~all"
+_sip._tcp	IN	SRV	10 5 5060 sip.example.com.
+@	IN	CAA	0 issue "letsencrypt.org"
+@	IN	SSHFP	4 2 ABCDEF
+old	7200	IN	A	2.2.2.2
+@	IN	HINFO	"PC" "Linux"
+$ORIGIN sub.example.com.
+mail	IN	A	3.3.3.3
+`
+
+	r, report, err := ParseZoneFile(strings.NewReader(zone), "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := Records{
+		&RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 3600},
+		&RecordAAAA{Type: "AAAA", Name: "www", Content: "2001:db8::1", TTL: 300},
+		&RecordCNAME{Type: "CNAME", Name: "blog", Content: "example.org", TTL: 3600},
+		&RecordMX{
+			Type: "MX", Name: "@", Content: "mail.protonmail.ch", TTL: 3600,
+			Priority: 10,
+		},
+		&RecordTXT{
+			Type: "TXT", Name: "@",
+			Content: "v=spf1 include:_spf.protonmail.ch ~all", TTL: 3600,
+		},
+		&RecordSRV{
+			Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com",
+			TTL: 3600, Priority: 10, Weight: 5, Port: 5060,
+		},
+		&RecordCAA{
+			Type: "CAA", Name: "@", Content: `0 issue "letsencrypt.org"`,
+			TTL: 3600,
+		},
+		&RecordSSHFP{
+			Type: "SSHFP", Name: "@", Content: "ABCDEF", TTL: 3600,
+			SSHAlgorithm: 4, SSHType: 2,
+		},
+		&RecordA{Type: "A", Name: "old", Content: "2.2.2.2", TTL: 10800},
+		&RecordA{Type: "A", Name: "mail.sub", Content: "3.3.3.3", TTL: 3600},
+	}
+
+	if !cmp.Equal(expected, r) {
+		t.Errorf("Parsed records don't match:\n%s", cmp.Diff(expected, r))
+	}
+
+	skipped := 0
+	adjusted := 0
+	for _, issue := range report.Issues {
+		if issue.Skipped {
+			skipped++
+		} else {
+			adjusted++
+		}
+	}
+
+	// SOA, apex NS and HINFO are skipped, and the 7200 TTL is adjusted
+	if skipped != 3 || adjusted != 1 {
+		t.Errorf("Unexpected report: %+v", report.Issues)
+	}
+}
+
+func TestParseZoneFileRoundTrip(t *testing.T) {
+	r := Records{
+		&RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 10800},
+		&RecordTXT{
+			Type: "TXT", Name: "long", Content: strings.Repeat("x", 300),
+			TTL: 300,
+		},
+		&RecordMX{
+			Type: "MX", Name: "@", Content: "mail.protonmail.ch", TTL: 10800,
+			Priority: 20,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteZoneFile(&buf, "example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	parsed, report, err := ParseZoneFile(&buf, "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(report.Issues) != 0 {
+		t.Errorf("Unexpected issues: %+v", report.Issues)
+	}
+
+	if !cmp.Equal(r, parsed) {
+		t.Errorf("Round trip doesn't match:\n%s", cmp.Diff(r, parsed))
+	}
+}
+
+func TestParseZoneFileTXTEscapes(t *testing.T) {
+	zone := `@	300	IN	TXT	"v=spf1 include:\"spf.example.com\" -all"
+dkim	300	IN	TXT	"k=rsa\; p=MIGf" "back\\slash\059"
+`
+
+	r, report, err := ParseZoneFile(strings.NewReader(zone), "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("Unexpected issues: %+v", report.Issues)
+	}
+
+	expected := Records{
+		&RecordTXT{
+			Type: "TXT", Name: "@", TTL: 300,
+			Content: `v=spf1 include:"spf.example.com" -all`,
+		},
+		&RecordTXT{
+			Type: "TXT", Name: "dkim", TTL: 300,
+			Content: `k=rsa; p=MIGfback\slash;`,
+		},
+	}
+	if !cmp.Equal(expected, r) {
+		t.Fatalf("Parsed records don't match:\n%s", cmp.Diff(expected, r))
+	}
+
+	// Exporting and importing again gives back the same content
+	var buf bytes.Buffer
+	if err := r.WriteZoneFile(&buf, "example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	parsed, _, err := ParseZoneFile(&buf, "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !cmp.Equal(expected, parsed) {
+		t.Errorf("Round trip doesn't match:\n%s", cmp.Diff(expected, parsed))
+	}
+}
+
+func TestParseZoneFileWithCapabilities(t *testing.T) {
+	zone := `@	43200	IN	A	1.1.1.1
+www	300	IN	A	2.2.2.2
+`
+
+	caps := structures.DefaultCapabilities()
+	caps.TTLs = []int{60, 43200}
+
+	r, report, err := ParseZoneFileWith(strings.NewReader(zone), "example.com", caps)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := Records{
+		&RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 43200},
+		&RecordA{Type: "A", Name: "www", Content: "2.2.2.2", TTL: 60},
+	}
+	if !cmp.Equal(expected, r) {
+		t.Errorf("Parsed records don't match:\n%s", cmp.Diff(expected, r))
+	}
+
+	// Only the 300 TTL isn't accepted by the given capabilities
+	if len(report.Issues) != 1 || report.Issues[0].Skipped {
+		t.Errorf("Unexpected report: %+v", report.Issues)
+	}
+}
+
+func TestParseZoneFilePriorities(t *testing.T) {
+	zone := `@	3600	IN	MX	15	mail.example.com.
+_sip._tcp	3600	IN	SRV	100 5 5060 sip.example.com.
+`
+
+	r, report, err := ParseZoneFile(strings.NewReader(zone), "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Priorities are rounded like TTLs, preferring the highest on ties
+	expected := Records{
+		&RecordMX{
+			Type: "MX", Name: "@", Content: "mail.example.com", TTL: 3600,
+			Priority: 20,
+		},
+		&RecordSRV{
+			Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com",
+			TTL: 3600, Priority: 60, Weight: 5, Port: 5060,
+		},
+	}
+	if !cmp.Equal(expected, r) {
+		t.Errorf("Parsed records don't match:\n%s", cmp.Diff(expected, r))
+	}
+
+	if len(report.Issues) != 2 {
+		t.Fatalf("Unexpected report: %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if issue.Skipped {
+			t.Errorf("Entry was skipped rather than adjusted: %s", issue)
+		}
+	}
+}
+
+func TestParseZoneFileKeepsPriorityOrder(t *testing.T) {
+	zone := `@	3600	IN	MX	15	backup.example.com.
+@	3600	IN	MX	20	mail.example.com.
+sub	3600	IN	MX	10	mail.example.com.
+sub	3600	IN	MX	15	backup.example.com.
+_sip._tcp	3600	IN	SRV	100 5 5060 a.example.com.
+_sip._tcp	3600	IN	SRV	200 5 5060 b.example.com.
+`
+
+	r, report, err := ParseZoneFile(strings.NewReader(zone), "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// 15 would tie with 20, and 100 and 200 would both become 60, but 10
+	// and 15 keep their order as 10 and 20
+	expected := Records{
+		&RecordMX{
+			Type: "MX", Name: "@", Content: "mail.example.com", TTL: 3600,
+			Priority: 20,
+		},
+		&RecordMX{
+			Type: "MX", Name: "sub", Content: "mail.example.com", TTL: 3600,
+			Priority: 10,
+		},
+		&RecordMX{
+			Type: "MX", Name: "sub", Content: "backup.example.com", TTL: 3600,
+			Priority: 20,
+		},
+	}
+	if !cmp.Equal(expected, r) {
+		t.Errorf("Parsed records don't match:\n%s", cmp.Diff(expected, r))
+	}
+
+	skipped := 0
+	for _, issue := range report.Issues {
+		if issue.Skipped {
+			skipped++
+		}
+	}
+	if skipped != 3 || len(report.Issues) != 4 {
+		t.Errorf("Unexpected report: %+v", report.Issues)
+	}
+}
+
+func TestToRR(t *testing.T) {
+	mx := &RecordMX{
+		Type: "MX", Name: "@", Content: "mail.protonmail.ch", TTL: 10800,
+		Priority: 20,
+	}
+
+	rr, err := ToRR(mx, "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := "example.com.\t10800\tIN\tMX\t20 mail.protonmail.ch."
+	if rr.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, rr.String())
+	}
+
+	back, err := FromRR(rr, "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !cmp.Equal(Record(mx), back) {
+		t.Errorf("Round trip doesn't match:\n%s", cmp.Diff(Record(mx), back))
+	}
+
+	redirect := &RecordRedirect{
+		Type: "Redirect", Name: "@", URL: "https://example.org",
+		RedirectType: 301,
+	}
+	if _, err := ToRR(redirect, "example.com"); err == nil {
+		t.Errorf("Redirect records shouldn't convert")
+	}
+}
+
+func TestClosestValue(t *testing.T) {
+	valid := []int{60, 300, 900, 3600}
+	cases := map[int]int{0: 60, 60: 60, 200: 300, 600: 900, 100000: 3600}
+
+	for value, expected := range cases {
+		got, err := closestValue("TTL", value, valid)
+		if err != nil || got != expected {
+			t.Errorf("Closest to %d should be %d, got %d: %v", value, expected, got, err)
+		}
+	}
+
+	if _, err := closestValue("TTL", 300, nil); err == nil {
+		t.Errorf("No accepted values should fail")
+	}
+}
+
+func TestParseZoneFileWithEmptyCapabilities(t *testing.T) {
+	zone := `@	300	IN	A	1.1.1.1
+@	300	IN	MX	10	mail.example.com.
+`
+
+	caps := structures.DefaultCapabilities()
+	caps.Priorities = nil
+
+	r, report, err := ParseZoneFileWith(strings.NewReader(zone), "example.com", caps)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Only the MX record needs a priority
+	if len(r) != 1 || len(report.Issues) != 1 || !report.Issues[0].Skipped {
+		t.Errorf("Unexpected result %s, report: %+v", r, report.Issues)
+	}
+}
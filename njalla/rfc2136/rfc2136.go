@@ -0,0 +1,239 @@
+// Package rfc2136 is a DNS UPDATE (RFC 2136) gateway to Njalla. It accepts
+// TSIG signed update messages, checks their prerequisites against the
+// current records, and applies the changes as Njalla updates and adds, so
+// tools like nsupdate or certbot-dns-rfc2136 can manage Njalla domains.
+// What each key may change is enforced as a policy, see the policy package
+package rfc2136
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/acme"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// Client is the part of provider.Provider needed to apply updates, which a
+// policy.PolicyProvider can wrap
+type Client interface {
+	policy.Client
+	UpdateRecords(
+		domain string, updates map[int]records.Record, removeIDs []int,
+	) error
+}
+
+// Key is a TSIG key allowed to send updates, along with what it may change
+type Key struct {
+	// Name of the key, such as `certbot.`
+	Name string `yaml:"name"`
+	// Algorithm, such as `hmac-sha256.`
+	Algorithm string `yaml:"algorithm"`
+	// Secret is the base64 encoded shared secret
+	Secret string `yaml:"secret"`
+	// Zones the key may read and change, as path.Match patterns. Empty
+	// means every domain of the account
+	Zones []string `yaml:"zones"`
+	// Names the key may read and change within its zones, as path.Match
+	// patterns on the fully qualified name without the trailing dot, such
+	// as `_acme-challenge.*example.com`. Empty means any name
+	Names []string `yaml:"names"`
+	// Types the key may read and change, such as TXT. Empty means any type
+	Types []string `yaml:"types"`
+
+	policy *policy.Policy
+}
+
+// Server handles DNS messages, see ServeDNS
+type Server struct {
+	client Client
+	keys   map[string]*Key
+
+	// Logger, if set, gets a line for every update and every refused one
+	Logger *log.Logger
+	// Auditor, if set, gets every change refused by the policy of a key
+	Auditor policy.Auditor
+
+	// mu serialises updates, since each one reads the current records and
+	// then changes them
+	mu sync.Mutex
+}
+
+// NewServer returns a Server applying the updates signed by any of the keys
+// through the client
+func NewServer(client Client, keys []Key) (*Server, error) {
+	s := &Server{client: client, keys: make(map[string]*Key)}
+
+	for i := range keys {
+		key := keys[i]
+		if key.Name == "" {
+			return nil, fmt.Errorf("TSIG key %d has no name", i)
+		}
+		if _, err := base64.StdEncoding.DecodeString(key.Secret); err != nil {
+			return nil, fmt.Errorf(
+				"TSIG key %s has an invalid secret: %s", key.Name, err,
+			)
+		}
+
+		key.Name = dns.Fqdn(strings.ToLower(key.Name))
+		if key.Algorithm == "" {
+			key.Algorithm = dns.HmacSHA256
+		}
+		key.Algorithm = dns.Fqdn(strings.ToLower(key.Algorithm))
+
+		switch key.Algorithm {
+		case dns.HmacMD5, dns.HmacSHA1, dns.HmacSHA256, dns.HmacSHA512:
+		default:
+			return nil, fmt.Errorf(
+				"TSIG key %s has an unsupported algorithm %s",
+				key.Name, key.Algorithm,
+			)
+		}
+
+		p, err := policy.New(key.Name, []policy.Rule{
+			{Domains: key.Zones, Names: key.Names, Types: key.Types},
+		})
+		if err != nil {
+			return nil, err
+		}
+		key.policy = p
+
+		s.keys[key.Name] = &key
+	}
+
+	return s, nil
+}
+
+// TsigSecrets returns the secrets to set as dns.Server's TsigSecret, so the
+// server verifies the signatures before calling ServeDNS
+func (s *Server) TsigSecrets() map[string]string {
+	secrets := make(map[string]string, len(s.keys))
+	for name, key := range s.keys {
+		secrets[name] = key.Secret
+	}
+	return secrets
+}
+
+// AcceptMsg is a dns.MsgAcceptFunc that, unlike dns.DefaultMsgAcceptFunc,
+// lets UPDATE messages through. Use it as the MsgAcceptFunc of dns.Server
+func AcceptMsg(dh dns.Header) dns.MsgAcceptAction {
+	// The QR bit is the highest one of the flags
+	if dh.Bits&(1<<15) == 0 && int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+		return dns.MsgAccept
+	}
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+// ListenAndServe serves DNS on the address over both UDP and TCP, until
+// either fails
+func (s *Server) ListenAndServe(addr string) error {
+	errs := make(chan error, 2)
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{
+			Addr: addr, Net: network, Handler: s,
+			TsigSecret: s.TsigSecrets(), MsgAcceptFunc: AcceptMsg,
+		}
+		go func() { errs <- server.ListenAndServe() }()
+	}
+	return <-errs
+}
+
+// ServeDNS answers UPDATE messages, and SOA queries so clients can find the
+// zone a name belongs to. Anything else is refused
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	key, rcode := s.authenticate(w, req)
+	if rcode == dns.RcodeSuccess {
+		switch req.Opcode {
+		case dns.OpcodeUpdate:
+			rcode = s.update(key, req, w.RemoteAddr().String())
+		case dns.OpcodeQuery:
+			rcode = s.query(req, m)
+		default:
+			rcode = dns.RcodeNotImplemented
+		}
+	}
+	m.Rcode = rcode
+
+	// Answers are signed with the same key, unless the signature failed
+	if t := req.IsTsig(); t != nil && key != nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, 300, time.Now().Unix())
+	}
+
+	w.WriteMsg(m)
+}
+
+// authenticate returns the key the message is signed with, if any. Bad
+// signatures give NOTAUTH
+func (s *Server) authenticate(w dns.ResponseWriter, req *dns.Msg) (*Key, int) {
+	t := req.IsTsig()
+	if t == nil {
+		return nil, dns.RcodeSuccess
+	}
+
+	key, exists := s.keys[strings.ToLower(t.Hdr.Name)]
+	if !exists || w.TsigStatus() != nil ||
+		!strings.EqualFold(t.Algorithm, key.Algorithm) {
+		s.logf("%s: bad TSIG signature with key %s", w.RemoteAddr(), t.Hdr.Name)
+		return nil, dns.RcodeNotAuth
+	}
+
+	return key, dns.RcodeSuccess
+}
+
+// query answers SOA queries for the domains of the account, with a made up
+// SOA record, since that's all clients need to find the zone
+func (s *Server) query(req *dns.Msg, m *dns.Msg) int {
+	if len(req.Question) != 1 || req.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeRefused
+	}
+	name := strings.ToLower(req.Question[0].Name)
+
+	domains, err := s.client.GetDomains()
+	if err != nil {
+		s.logf("Couldn't get domains: %s", err)
+		return dns.RcodeServerFailure
+	}
+
+	zone, err := acme.FindZone(domains, name)
+	if err != nil {
+		return dns.RcodeRefused
+	}
+	zone = dns.Fqdn(strings.ToLower(zone))
+
+	m.Authoritative = true
+	soa := &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600,
+		},
+		Ns:      "ns1.njalla.no.",
+		Mbox:    "hostmaster." + zone,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 10800,
+		Retry:   3600,
+		Expire:  604800,
+		Minttl:  3600,
+	}
+
+	// Names below the zone get an empty answer, with the SOA as authority
+	if name == zone {
+		m.Answer = append(m.Answer, soa)
+	} else {
+		m.Ns = append(m.Ns, soa)
+	}
+	return dns.RcodeSuccess
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
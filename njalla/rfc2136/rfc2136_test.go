@@ -0,0 +1,456 @@
+package rfc2136
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/miekg/dns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+const (
+	testKey    = "certbot."
+	testSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+	otherKey   = "other."
+)
+
+type testEnv struct {
+	njalla  *njallatest.Server
+	dns     *dns.Server
+	address string
+	audit   *testAuditor
+}
+
+// testAuditor keeps the denials, which are written by the server goroutines
+type testAuditor struct {
+	mu      sync.Mutex
+	denials []policy.Denial
+}
+
+func (a *testAuditor) Deny(d policy.Denial) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.denials = append(a.denials, d)
+}
+
+func (a *testAuditor) Denials() []policy.Denial {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]policy.Denial{}, a.denials...)
+}
+
+func (e *testEnv) Close() {
+	e.dns.Shutdown()
+	e.njalla.Close()
+}
+
+// testKeys are the keys of newTestEnv: certbot. may only change challenges
+// and www of example.com, and other. may only change example.org
+var testKeys = []Key{
+	{
+		Name: testKey, Secret: testSecret, Zones: []string{"example.com"},
+		Names: []string{"_acme-challenge.*example.com", "www.example.com"},
+	},
+	{Name: otherKey, Secret: testSecret, Zones: []string{"example.org"}},
+}
+
+// newTestEnv returns a gateway to a fake Njalla, accepting the keys, or
+// testKeys if none are given
+func newTestEnv(t *testing.T, keys ...Key) *testEnv {
+	if len(keys) == 0 {
+		keys = testKeys
+	}
+
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain(
+		"example.com",
+		&records.RecordA{Type: "A", Name: "www", Content: "1.1.1.1", TTL: 300},
+		&records.RecordTXT{
+			Type: "TXT", Name: "_acme-challenge", Content: "old", TTL: 60,
+		},
+	)
+	njalla.AddDomain("example.org")
+
+	client, err := njalla.Login()
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	server, err := NewServer(client, keys)
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+	audit := &testAuditor{}
+	server.Auditor = audit
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		njalla.Close()
+		t.Fatalf("%s", err)
+	}
+
+	started := make(chan struct{})
+	dnsServer := &dns.Server{
+		PacketConn: conn, Handler: server, TsigSecret: server.TsigSecrets(),
+		MsgAcceptFunc:     AcceptMsg,
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go dnsServer.ActivateAndServe()
+	<-started
+
+	return &testEnv{
+		njalla: njalla, dns: dnsServer, address: conn.LocalAddr().String(),
+		audit: audit,
+	}
+}
+
+// send signs the message with the key, if any, and returns the rcode
+func (e *testEnv) send(t *testing.T, m *dns.Msg, key string) int {
+	client := &dns.Client{
+		TsigSecret: map[string]string{testKey: testSecret, otherKey: testSecret},
+	}
+	if key != "" {
+		m.SetTsig(key, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+
+	// miekg/dns reports every NOTAUTH answer as a bad signature
+	resp, _, err := client.Exchange(m, e.address)
+	if err != nil && !(err == dns.ErrAuth && resp != nil) {
+		t.Fatalf("%s", err)
+	}
+	return resp.Rcode
+}
+
+func mustRR(record string) dns.RR {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func challengeContents(r records.Records) []string {
+	contents := make([]string, 0)
+	filtered := r.Filter(records.ByType("TXT"), records.ByName("_acme-challenge"))
+	for _, record := range filtered {
+		contents = append(contents, record.GetContent())
+	}
+	return contents
+}
+
+func TestUpdateAddAndDelete(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{
+		mustRR(`_acme-challenge.example.com. 60 IN TXT "new"`),
+	})
+	m.Remove([]dns.RR{
+		mustRR(`_acme-challenge.example.com. 60 IN TXT "old"`),
+	})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[rcode])
+	}
+
+	expected := []string{"new"}
+	got := challengeContents(env.njalla.Records("example.com"))
+	if !cmp.Equal(expected, got) {
+		t.Errorf("Challenge records don't match:\n%s", cmp.Diff(expected, got))
+	}
+
+	m = new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.RemoveRRset([]dns.RR{mustRR(`www.example.com. 0 IN A 0.0.0.0`)})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[rcode])
+	}
+
+	if www := env.njalla.Records("example.com").Filter(records.ByName("www")); len(www) != 0 {
+		t.Errorf("RRset wasn't removed: %s", www)
+	}
+}
+
+func TestUpdateChangesTTL(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	before := env.njalla.Records("example.com").Filter(records.ByName("www"))
+	posts := env.njalla.Posts()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{mustRR(`www.example.com. 60 IN A 1.1.1.1`)})
+	m.Remove([]dns.RR{
+		mustRR(`_acme-challenge.example.com. 60 IN TXT "old"`),
+	})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[rcode])
+	}
+
+	// The TTL change and the removal are a single update of the same record
+	if sent := env.njalla.Posts() - posts; sent != 1 {
+		t.Errorf("Expected a single update, got %d requests", sent)
+	}
+
+	after := env.njalla.Records("example.com")
+	expected := records.Records{
+		&records.RecordA{
+			ID: before[0].GetID(), Type: "A", Name: "www", Content: "1.1.1.1",
+			TTL: 60,
+		},
+	}
+	if !cmp.Equal(expected, after) {
+		t.Errorf("Records don't match:\n%s", cmp.Diff(expected, after))
+	}
+}
+
+func TestUpdateUsesDomainCapabilities(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+	env.njalla.Capabilities.TTLs = []int{60, 43200}
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{
+		mustRR(`_acme-challenge.example.com. 40000 IN TXT "new"`),
+	})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[rcode])
+	}
+
+	// The TTL is rounded to the closest one the domain accepts, which isn't
+	// one of the defaults
+	added := env.njalla.Records("example.com").
+		Filter(records.ByType("TXT"), records.ByName("_acme-challenge"))
+	if len(added) != 2 || added[1].GetTTL() != 43200 {
+		t.Errorf("Record wasn't added with TTL 43200: %s", added)
+	}
+}
+
+func TestUpdatePrerequisites(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	// NXRRSET fails since there's already a TXT record
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.RRsetNotUsed([]dns.RR{mustRR(`_acme-challenge.example.com. 0 IN TXT ""`)})
+	m.Insert([]dns.RR{mustRR(`_acme-challenge.example.com. 60 IN TXT "new"`)})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeYXRrset {
+		t.Errorf("Expected YXRRSET, got %s", dns.RcodeToString[rcode])
+	}
+
+	// YXRRSET fails for a name without A records
+	m = new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.RRsetUsed([]dns.RR{mustRR(`_acme-challenge.example.com. 0 IN A 0.0.0.0`)})
+	m.Insert([]dns.RR{mustRR(`_acme-challenge.example.com. 60 IN TXT "new"`)})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeNXRrset {
+		t.Errorf("Expected NXRRSET, got %s", dns.RcodeToString[rcode])
+	}
+
+	// Value dependent prerequisite matching the current RRset
+	m = new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Used([]dns.RR{mustRR(`_acme-challenge.example.com. 0 IN TXT "old"`)})
+	m.Insert([]dns.RR{mustRR(`_acme-challenge.example.com. 60 IN TXT "new"`)})
+
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR, got %s", dns.RcodeToString[rcode])
+	}
+
+	expected := []string{"old", "new"}
+	got := challengeContents(env.njalla.Records("example.com"))
+	if !cmp.Equal(expected, got) {
+		t.Errorf("Challenge records don't match:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestUpdateRefused(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	before := env.njalla.Posts()
+
+	insert := func(zone, record string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetUpdate(zone)
+		m.Insert([]dns.RR{mustRR(record)})
+		return m
+	}
+
+	tests := []struct {
+		name  string
+		msg   *dns.Msg
+		key   string
+		rcode int
+	}{
+		{
+			"unsigned",
+			insert("example.com.", `_acme-challenge.example.com. 60 IN TXT "x"`),
+			"", dns.RcodeRefused,
+		},
+		{
+			"name outside the key's ACL",
+			insert("example.com.", `mail.example.com. 60 IN A 1.1.1.1`),
+			testKey, dns.RcodeRefused,
+		},
+		{
+			"zone outside the key's ACL",
+			insert("example.com.", `_acme-challenge.example.com. 60 IN TXT "x"`),
+			otherKey, dns.RcodeRefused,
+		},
+		{
+			"record outside the zone",
+			insert("example.com.", `_acme-challenge.example.net. 60 IN TXT "x"`),
+			testKey, dns.RcodeRefused,
+		},
+		{
+			"zone not in the account",
+			insert("example.net.", `www.example.net. 60 IN A 1.1.1.1`),
+			otherKey, dns.RcodeRefused,
+		},
+	}
+
+	for _, test := range tests {
+		if rcode := env.send(t, test.msg, test.key); rcode != test.rcode {
+			t.Errorf(
+				"%s: expected %s, got %s", test.name,
+				dns.RcodeToString[test.rcode], dns.RcodeToString[rcode],
+			)
+		}
+	}
+
+	if env.njalla.Posts() != before {
+		t.Errorf("Refused updates changed records")
+	}
+
+	// Unsigned updates aren't policy denials
+	denials := env.audit.Denials()
+	if len(denials) != 4 {
+		t.Fatalf("Expected 4 audit entries, got %+v", denials)
+	}
+	if denials[0].Name != "mail" || denials[0].Principal != testKey {
+		t.Errorf("Unexpected audit entry: %+v", denials[0])
+	}
+}
+
+func TestUpdateKeyTypes(t *testing.T) {
+	env := newTestEnv(t, Key{
+		Name: testKey, Secret: testSecret, Types: []string{"TXT"},
+	})
+	defer env.Close()
+
+	// Deleting every RRset of a name only sees the TXT records
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.RemoveName([]dns.RR{mustRR(`www.example.com. 0 IN ANY`)})
+	m.Insert([]dns.RR{mustRR(`www.example.com. 60 IN TXT "hello"`)})
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR, got %s", dns.RcodeToString[rcode])
+	}
+	if len(env.njalla.Records("example.com").Filter(records.ByType("A"))) != 1 {
+		t.Errorf("The A record of www was removed")
+	}
+
+	m = new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{
+		mustRR(`_acme-challenge.example.com. 60 IN TXT "new"`),
+		mustRR(`mail.example.com. 60 IN A 1.1.1.1`),
+	})
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got %s", dns.RcodeToString[rcode])
+	}
+	if len(challengeContents(env.njalla.Records("example.com"))) != 1 {
+		t.Errorf("A refused update was partly applied")
+	}
+
+	denials := env.audit.Denials()
+	if len(denials) != 1 || denials[0].Operation != policy.Add ||
+		denials[0].Name != "mail" || denials[0].Frontend != "rfc2136" {
+		t.Errorf("Unexpected audit entries: %+v", denials)
+	}
+}
+
+func TestUpdateNotZoneAndNotAuth(t *testing.T) {
+	// A key with no ACL, to reach the zone checks
+	env := newTestEnv(t, Key{Name: testKey, Secret: testSecret})
+	defer env.Close()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{mustRR(`www.example.net. 60 IN A 1.1.1.1`)})
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeNotZone {
+		t.Errorf("Expected NOTZONE, got %s", dns.RcodeToString[rcode])
+	}
+
+	m = new(dns.Msg)
+	m.SetUpdate("example.net.")
+	m.Insert([]dns.RR{mustRR(`www.example.net. 60 IN A 1.1.1.1`)})
+	if rcode := env.send(t, m, testKey); rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH, got %s", dns.RcodeToString[rcode])
+	}
+}
+
+func TestBadSignature(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{mustRR(`_acme-challenge.example.com. 60 IN TXT "x"`)})
+	m.SetTsig(testKey, dns.HmacSHA256, 300, time.Now().Unix())
+
+	client := &dns.Client{
+		TsigSecret: map[string]string{testKey: "d3Jvbmctc2VjcmV0"},
+	}
+	resp, _, _ := client.Exchange(m, env.address)
+	if resp == nil || resp.Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH for a bad signature, got %v", resp)
+	}
+}
+
+func TestSOAQuery(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Close()
+
+	client := &dns.Client{}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeSOA)
+	resp, _, err := client.Exchange(m, env.address)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess || !resp.Authoritative ||
+		len(resp.Answer) != 1 {
+		t.Errorf("Unexpected answer for the zone:\n%s", resp)
+	}
+
+	m.SetQuestion("_acme-challenge.example.com.", dns.TypeSOA)
+	resp, _, err = client.Exchange(m, env.address)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 ||
+		len(resp.Ns) != 1 {
+		t.Errorf("Unexpected answer below the zone:\n%s", resp)
+	}
+}
@@ -0,0 +1,412 @@
+package rfc2136
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// entry is a record of the zone while an update is processed
+type entry struct {
+	record records.Record
+	// rr is nil for Njalla only types, like Redirect, which DNS UPDATE
+	// can't see
+	rr dns.RR
+	// owner is the lower cased, fully qualified name of the record
+	owner   string
+	added   bool
+	removed bool
+	// updated is set on stored records whose TTL changes
+	updated bool
+}
+
+// change is an RR of the update section, along with the record it adds
+type change struct {
+	rr     dns.RR
+	record records.Record
+}
+
+// update processes an UPDATE message as described in RFC 2136 section 3,
+// returning the rcode of the answer
+func (s *Server) update(key *Key, req *dns.Msg, remote string) int {
+	if key == nil {
+		s.logf("%s: refused unsigned update", remote)
+		return dns.RcodeRefused
+	}
+
+	if len(req.Question) != 1 || req.Question[0].Qtype != dns.TypeSOA ||
+		req.Question[0].Qclass != dns.ClassINET {
+		return dns.RcodeFormatError
+	}
+	zone := dns.Fqdn(strings.ToLower(req.Question[0].Name))
+
+	client := policy.NewPolicyProvider(s.client, key.policy, s.Auditor)
+	client.Frontend = "rfc2136"
+	client.Source = remote
+
+	if !key.policy.AllowsAny(zone) {
+		s.logf("%s: key %s may not update %s", remote, key.Name, zone)
+		client.Deny(policy.Denial{
+			Operation: policy.Update, Domain: strings.TrimSuffix(zone, "."),
+		})
+		return dns.RcodeRefused
+	}
+
+	domains, err := s.client.GetDomains()
+	if err != nil {
+		s.logf("Couldn't get domains: %s", err)
+		return dns.RcodeServerFailure
+	}
+
+	domain := ""
+	for _, candidate := range domains {
+		if strings.EqualFold(dns.Fqdn(candidate), zone) {
+			domain = candidate
+		}
+	}
+	if domain == "" {
+		return dns.RcodeNotAuth
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Records the key may not read are left out, so the prerequisites and
+	// the changes can't see or touch them
+	current, err := client.GetRecords(domain)
+	if err != nil {
+		s.logf("Couldn't get records of %s: %s", domain, err)
+		return dns.RcodeServerFailure
+	}
+
+	entries := make([]*entry, 0, len(current))
+	for _, record := range current {
+		entries = append(entries, newEntry(domain, zone, record))
+	}
+
+	rcode := checkPrerequisites(zone, entries, req.Answer)
+	if rcode != dns.RcodeSuccess {
+		return rcode
+	}
+
+	for _, rr := range req.Ns {
+		if !key.policy.AllowsName(domain, rr.Header().Name) {
+			s.logf(
+				"%s: key %s may not update %s", remote, key.Name,
+				rr.Header().Name,
+			)
+			client.Deny(policy.Denial{
+				Operation: policy.Update, Domain: domain,
+				Name: records.CanonicalName(domain, rr.Header().Name),
+				Type: dns.TypeToString[rr.Header().Rrtype],
+			})
+			return dns.RcodeRefused
+		}
+	}
+
+	caps, err := records.CapabilitiesOf(s.client, domain)
+	if err != nil {
+		s.logf("Couldn't get the capabilities of %s: %s", domain, err)
+	}
+
+	var changes []change
+	changes, rcode = prescan(domain, zone, caps, req.Ns)
+	if rcode != dns.RcodeSuccess {
+		return rcode
+	}
+
+	for _, c := range changes {
+		entries = apply(domain, zone, entries, c)
+	}
+
+	// The whole update is checked against the policy before any of it is
+	// applied. Removals and TTL changes are then sent as a single Njalla
+	// update, which makes all of them or none, but the additions are made
+	// one by one after it, so a failed addition leaves the earlier changes
+	// applied
+	removeIDs := make([]int, 0)
+	updates := make(map[int]records.Record)
+	additions := make(records.Records, 0)
+	for _, e := range entries {
+		var err error
+		switch {
+		case e.removed && !e.added:
+			removeIDs = append(removeIDs, e.record.GetID())
+			err = client.Check(policy.Delete, domain, e.record)
+		case e.added && !e.removed:
+			additions = append(additions, e.record)
+			err = client.Check(policy.Add, domain, e.record)
+		case e.updated && !e.removed:
+			updates[e.record.GetID()] = e.record
+			err = client.Check(policy.Update, domain, e.record)
+		}
+		if err != nil {
+			s.logf("%s: %s", remote, err)
+			return dns.RcodeRefused
+		}
+	}
+
+	if len(removeIDs) > 0 || len(updates) > 0 {
+		err := s.client.UpdateRecords(domain, updates, removeIDs)
+		if err != nil {
+			s.logf("Couldn't update records of %s: %s", domain, err)
+			return dns.RcodeServerFailure
+		}
+	}
+	for _, record := range additions {
+		if err := s.client.AddRecord(domain, record); err != nil {
+			s.logf("Couldn't add record to %s: %s", domain, err)
+			return dns.RcodeServerFailure
+		}
+	}
+
+	s.logf(
+		"%s: key %s updated %s, %d added, %d changed, %d removed", remote,
+		key.Name, domain, len(additions), len(updates), len(removeIDs),
+	)
+	return dns.RcodeSuccess
+}
+
+func newEntry(domain, zone string, record records.Record) *entry {
+	owner := zone
+	if name := records.CanonicalName(domain, record.GetName()); name != "@" {
+		owner = name + "." + zone
+	}
+
+	rr, err := records.ToRR(record, domain)
+	if err != nil {
+		rr = nil
+	}
+
+	return &entry{record: record, rr: rr, owner: owner}
+}
+
+// checkPrerequisites checks the prerequisite section, RFC 2136 section 3.2
+func checkPrerequisites(zone string, entries []*entry, prereqs []dns.RR) int {
+	// Value dependent prerequisites are checked as whole RRsets at the end
+	rrsets := make(map[string][]dns.RR)
+	keys := make([]string, 0)
+
+	for _, rr := range prereqs {
+		header := rr.Header()
+		name := strings.ToLower(header.Name)
+
+		if header.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !inZone(name, zone) {
+			return dns.RcodeNotZone
+		}
+
+		switch header.Class {
+		case dns.ClassANY:
+			if header.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if header.Rrtype == dns.TypeANY {
+				if !nameInUse(entries, name) {
+					return dns.RcodeNameError
+				}
+			} else if len(rrset(entries, name, header.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if header.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if header.Rrtype == dns.TypeANY {
+				if nameInUse(entries, name) {
+					return dns.RcodeYXDomain
+				}
+			} else if len(rrset(entries, name, header.Rrtype)) != 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			key := name + " " + dns.TypeToString[header.Rrtype]
+			if _, exists := rrsets[key]; !exists {
+				keys = append(keys, key)
+			}
+			rrsets[key] = append(rrsets[key], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	for _, key := range keys {
+		expected := rrsets[key]
+		header := expected[0].Header()
+		existing := rrset(entries, strings.ToLower(header.Name), header.Rrtype)
+
+		if !sameRRset(expected, existing) {
+			return dns.RcodeNXRrset
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+// prescan checks the update section, RFC 2136 section 3.4.1, and converts
+// the records to add with the capabilities of the domain
+func prescan(
+	domain, zone string, caps structures.Capabilities, updates []dns.RR,
+) ([]change, int) {
+	changes := make([]change, 0, len(updates))
+
+	for _, rr := range updates {
+		header := rr.Header()
+		if !inZone(strings.ToLower(header.Name), zone) {
+			return nil, dns.RcodeNotZone
+		}
+
+		c := change{rr: rr}
+		switch header.Class {
+		case dns.ClassINET:
+			if isMetaType(header.Rrtype) {
+				return nil, dns.RcodeFormatError
+			}
+			// The SOA is Njalla's, changes to it are ignored
+			if header.Rrtype == dns.TypeSOA {
+				continue
+			}
+
+			record, err := records.FromRRWith(rr, domain, caps)
+			if err != nil {
+				return nil, dns.RcodeRefused
+			}
+			c.record = record
+		case dns.ClassANY:
+			if header.Ttl != 0 || header.Rdlength != 0 ||
+				(header.Rrtype != dns.TypeANY && isMetaType(header.Rrtype)) {
+				return nil, dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if header.Ttl != 0 || isMetaType(header.Rrtype) {
+				return nil, dns.RcodeFormatError
+			}
+		default:
+			return nil, dns.RcodeFormatError
+		}
+
+		changes = append(changes, c)
+	}
+
+	return changes, dns.RcodeSuccess
+}
+
+// apply applies a single change to the entries, RFC 2136 section 3.4.2
+func apply(domain, zone string, entries []*entry, c change) []*entry {
+	header := c.rr.Header()
+	name := strings.ToLower(header.Name)
+
+	switch header.Class {
+	case dns.ClassINET:
+		for _, e := range entries {
+			if e.removed || e.rr == nil || e.owner != name {
+				continue
+			}
+
+			// A CNAME can't share its name with other records
+			isCNAME := e.rr.Header().Rrtype == dns.TypeCNAME
+			if isCNAME != (header.Rrtype == dns.TypeCNAME) {
+				return entries
+			}
+
+			if dns.IsDuplicate(e.rr, c.rr) {
+				if e.record.GetTTL() != c.record.GetTTL() {
+					// Only the TTL changes, so the record is changed in place
+					e.record = e.record.WithTTL(c.record.GetTTL())
+					e.rr.Header().Ttl = uint32(e.record.GetTTL())
+					e.updated = !e.added
+				}
+				return entries
+			}
+		}
+
+		added := newEntry(domain, zone, c.record)
+		added.added = true
+		return append(entries, added)
+
+	case dns.ClassANY:
+		for _, e := range entries {
+			if e.rr == nil || e.owner != name {
+				continue
+			}
+			if header.Rrtype == dns.TypeANY ||
+				e.rr.Header().Rrtype == header.Rrtype {
+				e.removed = true
+			}
+		}
+
+	case dns.ClassNONE:
+		// Compare as class IN, since the class NONE is only a marker
+		rr := dns.Copy(c.rr)
+		rr.Header().Class = dns.ClassINET
+
+		for _, e := range entries {
+			if e.rr != nil && dns.IsDuplicate(e.rr, rr) {
+				e.removed = true
+			}
+		}
+	}
+
+	return entries
+}
+
+// rrset returns the records at a name with the type
+func rrset(entries []*entry, name string, rrtype uint16) []dns.RR {
+	result := make([]dns.RR, 0)
+	for _, e := range entries {
+		if !e.removed && e.rr != nil && e.owner == name &&
+			e.rr.Header().Rrtype == rrtype {
+			result = append(result, e.rr)
+		}
+	}
+	return result
+}
+
+func nameInUse(entries []*entry, name string) bool {
+	for _, e := range entries {
+		if !e.removed && e.owner == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sameRRset compares two RRsets ignoring TTLs and order
+func sameRRset(a, b []dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, rr := range a {
+		found := false
+		for _, other := range b {
+			if dns.IsDuplicate(rr, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func inZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+func isMetaType(rrtype uint16) bool {
+	switch rrtype {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA,
+		dns.TypeMAILB, dns.TypeOPT, dns.TypeTSIG:
+		return true
+	}
+	return false
+}
@@ -32,3 +32,34 @@ const (
 	SSHTYPESSHA1   = 1
 	SSHTYPESSHA256 = 2
 )
+
+// Capabilities holds the values Njalla accepts for the enum-like record
+// fields, as offered in the add record form of its website
+type Capabilities struct {
+	TTLs          []int
+	Priorities    []int
+	RedirectTypes []int
+	SSHAlgorithms []int
+	SSHTypes      []int
+}
+
+// DefaultCapabilities returns the Capabilities defined by the constants in
+// this package. These are used when the live values can't be fetched from
+// Njalla
+func DefaultCapabilities() Capabilities {
+	return Capabilities{
+		TTLs: []int{
+			TTL60, TTL300, TTL900, TTL3600, TTL10800, TTL21600, TTL86400,
+		},
+		Priorities: []int{
+			PRIORITY0, PRIORITY1, PRIORITY5, PRIORITY10, PRIORITY20,
+			PRIORITY30, PRIORITY40, PRIORITY50, PRIORITY60,
+		},
+		RedirectTypes: []int{REDIRECTTYPE301, REDIRECTTYPE302},
+		SSHAlgorithms: []int{
+			SSHALGORITHMRSA, SSHALGORITHMDSA, SSHALGORITHMECDSA,
+			SSHALGORITHMED25519,
+		},
+		SSHTypes: []int{SSHTYPESSHA1, SSHTYPESSHA256},
+	}
+}
@@ -1,70 +1,94 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
 )
 
 func listDomains(cmd *cobra.Command, args []string) error {
-	njalla, err := loginCLI()
+	format, err := outputFormat(cmd)
 	if err != nil {
 		return err
 	}
 
-	domains, err := njalla.GetDomains()
+	allProfiles, err := cmd.Flags().GetBool("all-profiles")
 	if err != nil {
-		return fmt.Errorf("Couldn't fetch domains: %s", err)
+		return err
 	}
 
-	fmt.Println(strings.Join(domains, "\n"))
-
-	return nil
-}
-
-func listRecords(cmd *cobra.Command, args []string) error {
-	domain := args[0]
-
-	njalla, err := loginCLI()
+	c, err := loadConfig()
 	if err != nil {
 		return err
 	}
 
-	records, err := njalla.GetRecords(domain)
+	name, err := selectedProfile(cmd, c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(records)
+	names := []string{name}
+	if allProfiles {
+		names = c.profileNames()
+	}
+
+	domains := make([]domainOutput, 0)
+	for _, name := range names {
+		p, err := c.getProfile(name)
+		if err != nil {
+			return err
+		}
+
+		njalla, err := loginProfile(cmd, name, p, !allProfiles)
+		if err != nil {
+			return fmt.Errorf("Profile %s: %s", name, err)
+		}
+
+		profileDomains, err := njalla.GetDomains()
+		if err != nil {
+			return fmt.Errorf("Couldn't fetch domains for %s: %s", name, err)
+		}
+
+		for _, domain := range profileDomains {
+			domains = append(domains, domainOutput{
+				Domain: domain, Profile: name,
+			})
+		}
+	}
 
-	return nil
+	return printDomains(os.Stdout, format, domains)
 }
 
-func removeRecord(cmd *cobra.Command, args []string) error {
+func listRecords(cmd *cobra.Command, args []string) error {
 	domain := args[0]
-	recordID, err := strconv.Atoi(args[1])
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	filters, err := recordFilters(cmd, domain)
 	if err != nil {
-		return fmt.Errorf("RecordID is not a valid int value: %s", args[1])
+		return err
 	}
 
-	njalla, err := loginCLI()
+	njalla, err := loginCLI(cmd)
 	if err != nil {
 		return err
 	}
 
-	err = njalla.RemoveRecord(domain, recordID)
+	stored, err := njalla.GetRecords(domain)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return printRecords(os.Stdout, format, domain, stored.Filter(filters...))
 }
 
 func main() {
@@ -74,23 +98,368 @@ func main() {
 		Args:  cobra.NoArgs,
 		RunE:  listDomains,
 	}
+	cmdDomains.Flags().Bool(
+		"all-profiles", false,
+		"List the domains of every profile in the config",
+	)
 
 	cmdRecords := &cobra.Command{
 		Use:   "records [domain]",
 		Short: "List all available records for a domain",
-		Args:  cobra.ExactArgs(1),
-		RunE:  listRecords,
+		Long: `Lists the records of a domain. Use --type, --name and --content-regex
+to only list the records matching all of them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: listRecords,
 	}
+	addFilterFlags(cmdRecords, "Only list")
 
 	cmdRemove := &cobra.Command{
 		Use:   "remove [domain] [recordID]",
 		Short: "Remove a given record with its ID from a domain",
 		Long: `Takes a domain and a record ID to remove.
 You can get a record ID from the records command. This record ID should be
-an integer number or else this command will fail.`,
-		Args: cobra.ExactArgs(2),
+an integer number or else this command will fail.
+
+Instead of an ID, records can be selected with --type, --name and
+--content-regex. Every record matching all the given selectors is shown and,
+after confirming, removed at once. Use --yes to skip the confirmation, and
+--dry-run to only show the matched records.
+With --wait, it only returns once no authoritative name server of the domain
+serves the removed records.`,
+		Args: cobra.RangeArgs(1, 2),
 		RunE: removeRecord,
 	}
+	addFilterFlags(cmdRemove, "Only remove")
+	addWaitFlags(cmdRemove)
+	cmdRemove.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+	cmdRemove.Flags().Bool(
+		"dry-run", false, "Only show the records that would be removed",
+	)
+
+	cmdLint := &cobra.Command{
+		Use:   "lint [domain]",
+		Short: "Check a domain's records for common mistakes",
+		Long: `Checks all the records of a domain for common mistakes, such as a
+CNAME sharing its name with other records, or several SPF records.
+With --file, the records are read from a local JSON file instead of Njalla,
+and the domain, if given, is only used to qualify the record names. Records
+from a file are checked against the default values Njalla accepts rather
+than those of the domain.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: lintZone,
+	}
+	cmdLint.Flags().StringP(
+		"file", "f", "", "JSON file with the records to check",
+	)
+
+	cmdExport := &cobra.Command{
+		Use:   "export [domain]",
+		Short: "Export all the records of a domain",
+		Long: `Exports all the records of a domain to stdout, in the format
+given with --output, or as a zone file if it isn't given.
+The zone format renders a BIND style zone file. Njalla only records, like
+Redirect and Dynamic, are kept as comments in the zone file.
+The json format can be read back by the lint command.`,
+		Args: cobra.ExactArgs(1),
+		RunE: exportRecords,
+	}
+	cmdExport.Flags().String("format", "zone", "Alias of --output")
+	cmdExport.Flags().MarkDeprecated("format", "use --output instead")
+
+	cmdImport := &cobra.Command{
+		Use:   "import [domain] [zone file]",
+		Short: "Import the records of a zone file into a domain",
+		Long: `Parses a BIND style zone file and adds all its records to a domain.
+Entries Njalla can't hold, like SOA records, are skipped, and TTLs and MX
+and SRV priorities Njalla doesn't accept are changed to the closest one it
+does. Both are reported before adding anything. With --dry-run, the records are only shown, and
+checked against the default values Njalla accepts rather than those of the
+domain.`,
+		Args: cobra.ExactArgs(2),
+		RunE: importZone,
+	}
+	cmdImport.Flags().Bool(
+		"dry-run", false, "Only show the records that would be added",
+	)
+
+	cmdPlan := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the changes needed to reach a desired state",
+		Long: `Reads a YAML or JSON file with the desired records of one or more
+domains, and shows the records that would be created, updated or deleted to
+reach that state. Records are matched by name, type and content.`,
+		Args: cobra.NoArgs,
+		RunE: planState,
+	}
+
+	cmdApply := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply the changes needed to reach a desired state",
+		Long: `Same as plan, but after showing the changes it asks for
+confirmation and applies them. Use --auto-approve to skip the confirmation.
+Every record of the listed domains that isn't in the desired state is
+deleted. The deletes and updates of a domain are applied all at once, and
+the new records are added after them.`,
+		Args: cobra.NoArgs,
+		RunE: applyState,
+	}
+	cmdApply.Flags().Bool(
+		"auto-approve", false, "Apply the changes without asking for confirmation",
+	)
+
+	for _, cmd := range []*cobra.Command{cmdPlan, cmdApply} {
+		cmd.Flags().StringP(
+			"file", "f", "", "YAML or JSON file with the desired state",
+		)
+		cmd.MarkFlagRequired("file")
+	}
+
+	cmdAdd := &cobra.Command{
+		Use:   "add [domain] [type]",
+		Short: "Add a new record to a domain",
+		Long: `Adds a new record of the given type to a domain.
+Every record type only uses the flags it needs. For instance, an A record
+takes --name, --content and --ttl, while an SRV record also takes --prio,
+--weight and --port. The values are validated against those the domain
+accepts before adding the record.
+With --wait, it only returns once every authoritative name server of the
+domain serves the new record.`,
+		Args: cobra.ExactArgs(2),
+		RunE: addRecord,
+	}
+	addRecordFlags(cmdAdd.Flags())
+	addWaitFlags(cmdAdd)
+
+	cmdUpdate := &cobra.Command{
+		Use:   "update [domain] [recordID]",
+		Short: "Update the given fields of a record",
+		Long: `Takes a domain and a record ID to update.
+Only the fields given as flags are changed, the rest keep their current
+values. You can get a record ID from the records command.
+With --wait, it only returns once every authoritative name server of the
+domain serves the updated record.`,
+		Args: cobra.ExactArgs(2),
+		RunE: updateRecord,
+	}
+	addRecordFlags(cmdUpdate.Flags())
+	addWaitFlags(cmdUpdate)
+
+	cmdCertbotAuth := &cobra.Command{
+		Use:   "certbot-auth",
+		Short: "Certbot manual auth hook for DNS-01 challenges",
+		Long: `Creates the _acme-challenge TXT record for certbot's DNS-01 challenge,
+reading CERTBOT_DOMAIN and CERTBOT_VALIDATION. The record is added to the
+Njalla domain the name belongs to, so subdomains work too. After the last
+challenge, it waits --delay for the records to propagate. Use it along with
+certbot-cleanup:
+
+  certbot certonly --manual --preferred-challenges dns \
+    --manual-auth-hook "njallaclient certbot-auth" \
+    --manual-cleanup-hook "njallaclient certbot-cleanup" \
+    -d example.com -d '*.example.com'
+
+It never prompts for credentials, so they must be in the environment, the
+config file or ~/.netrc.`,
+		Args:        cobra.NoArgs,
+		RunE:        certbotAuth,
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmdCertbotAuth.Flags().Duration(
+		"delay", 60*time.Second,
+		"How long to wait after the last challenge for the records to propagate",
+	)
+
+	cmdCertbotCleanup := &cobra.Command{
+		Use:   "certbot-cleanup",
+		Short: "Certbot manual cleanup hook for DNS-01 challenges",
+		Long: `Removes the TXT record created by certbot-auth for the same challenge,
+leaving any other challenge records alone.`,
+		Args:        cobra.NoArgs,
+		RunE:        certbotCleanup,
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+
+	cmdServeRFC2136 := &cobra.Command{
+		Use:   "serve-rfc2136",
+		Short: "Serve a DNS UPDATE (RFC 2136) gateway to Njalla",
+		Long: `Serves DNS over UDP and TCP, accepting TSIG signed DNS UPDATE messages
+and applying them to the Njalla domain named in their zone section, so tools
+like nsupdate, certbot-dns-rfc2136 or external-dns can manage the records.
+Prerequisites are checked against the current records, and failed ones are
+answered with the usual rcodes, such as NXRRSET or YXRRSET. SOA queries for
+the domains are answered too, so clients can find the zone of a name.
+
+The TSIG keys are read from the --keys YAML file. Each key may be limited to
+some zones, to some names within them as glob patterns, and to some types:
+
+  keys:
+    - name: certbot.
+      algorithm: hmac-sha256.
+      secret: c2VjcmV0LXNlY3JldC1zZWNyZXQ=
+      zones: [example.com]
+      names: ["_acme-challenge.*example.com"]
+      types: [TXT]
+
+Records outside of what a key may change are hidden from its prerequisites.
+Refused updates are written to --audit-log as JSON lines.
+
+It never prompts for credentials, so they must be in the environment, the
+config file or ~/.netrc. The session is renewed whenever it expires.`,
+		Args:        cobra.NoArgs,
+		RunE:        serveRFC2136,
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmdServeRFC2136.Flags().String(
+		"listen", ":5353", "Address to serve DNS on, over UDP and TCP",
+	)
+	cmdServeRFC2136.Flags().String(
+		"keys", "", "YAML file with the TSIG keys and what each may change",
+	)
+	cmdServeRFC2136.MarkFlagRequired("keys")
+	addAuditFlag(cmdServeRFC2136)
+
+	cmdServeACMEDNS := &cobra.Command{
+		Use:   "serve-acmedns",
+		Short: "Serve an acme-dns compatible API backed by Njalla",
+		Long: `Serves acme-dns's HTTP API, so ACME clients supporting acme-dns can
+solve DNS-01 challenges with Njalla records. POST /register creates an account
+with its own subdomain of --zone, and POST /update sets the _acme-challenge
+TXT record of that subdomain, keeping the two latest values as acme-dns does.
+Point the _acme-challenge name of a domain to the registration's fulldomain
+with a CNAME record to use it.
+
+Registrations are kept in --store, by default acmedns.json next to the config
+file, with bcrypt hashes of their passwords. Each registration may limit the
+addresses allowed to update it with the allowfrom CIDRs it registers with.
+Attempts to update another subdomain are written to --audit-log.
+
+It never prompts for credentials, so they must be in the environment, the
+config file or ~/.netrc. The session is renewed whenever it expires.`,
+		Args:        cobra.NoArgs,
+		RunE:        serveACMEDNS,
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmdServeACMEDNS.Flags().String(
+		"listen", ":8080", "Address to serve the HTTP API on",
+	)
+	cmdServeACMEDNS.Flags().String(
+		"zone", "",
+		"Name the subdomains are created under, such as auth.example.com",
+	)
+	cmdServeACMEDNS.MarkFlagRequired("zone")
+	cmdServeACMEDNS.Flags().String(
+		"store", "", "JSON file with the registrations",
+	)
+	cmdServeACMEDNS.Flags().Bool(
+		"disable-registration", false,
+		"Only allow the registrations already in the store",
+	)
+	cmdServeACMEDNS.Flags().String(
+		"forwarded-header", "",
+		"Header with the client address, such as X-Forwarded-For, when "+
+			"behind a reverse proxy",
+	)
+	addTLSFlags(cmdServeACMEDNS)
+	addAuditFlag(cmdServeACMEDNS)
+
+	cmdServe := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a JSON REST API for the domains and records",
+		Long: `Serves a JSON API to list the domains, and to list, add, update and
+remove their records, so other services can manage them without the account
+password. It keeps a single Njalla session, renewed whenever it expires.
+
+  GET    /domains
+  GET    /domains/{domain}/records
+  POST   /domains/{domain}/records
+  GET    /domains/{domain}/records/{id}
+  PATCH  /domains/{domain}/records/{id}
+  DELETE /domains/{domain}/records/{id}
+
+Records use the JSON of Njalla's website, with only the fields of their type.
+GET /openapi.json returns the OpenAPI document with the schema of every type.
+
+Every other request needs one of the bearer tokens in the --tokens YAML file.
+Each token has rules granting operations (read, add, update or delete) on
+domains, names and types, where domains and names are glob patterns and an
+empty field matches anything. Requests outside them are answered with 403
+and written to --audit-log as JSON lines:
+
+  tokens:
+    - name: deploy
+      token: a-long-random-secret
+      rules:
+        - {}
+    - name: certbot
+      token: another-long-random-secret
+      rules:
+        - domains: [example.com]
+          names: ["_acme-challenge.*"]
+          types: [TXT]
+          operations: [read, add, delete]
+
+It never prompts for credentials, so they must be in the environment, the
+config file or ~/.netrc.`,
+		Args:        cobra.NoArgs,
+		RunE:        serveAPI,
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmdServe.Flags().String(
+		"listen", "127.0.0.1:8080", "Address to serve the API on",
+	)
+	cmdServe.Flags().String(
+		"tokens", "", "YAML file with the bearer tokens allowed to use the API",
+	)
+	cmdServe.MarkFlagRequired("tokens")
+	addTLSFlags(cmdServe)
+	addAuditFlag(cmdServe)
+
+	cmdServeExternalDNS := &cobra.Command{
+		Use:   "serve-external-dns",
+		Short: "Serve an external-dns webhook provider backed by Njalla",
+		Long: `Serves external-dns's webhook provider protocol, so external-dns can
+manage the records of Kubernetes workloads in Njalla domains. Run external-dns
+with --provider=webhook, pointing --webhook-provider-url to --listen, and
+with the TXT registry, since only the records owned by --txt-owner-id are
+updated or removed. Records owned by nobody, such as those made by hand, are
+left untouched.
+
+  GET  /                 negotiation, answering the managed domains
+  GET  /records          the records of the managed domains
+  POST /adjustendpoints  rounds TTLs to those Njalla accepts
+  POST /records          applies the changes, also served as POST /apply
+  GET  /healthz          health check
+
+Updates and removals in a domain are made in a single Njalla operation.
+Every domain of the account is managed, unless limited with --domain.
+
+external-dns can't authenticate to a webhook provider, so there are no tokens
+as with serve: keep --listen on a loopback address, next to external-dns.
+What may be changed can be limited with --rules, a YAML file with a rules list
+as described in the serve help. Changes with any denied part are refused
+whole, and written to --audit-log.
+
+It never prompts for credentials, so they must be in the environment, the
+config file or ~/.netrc. The session is renewed whenever it expires.`,
+		Args:        cobra.NoArgs,
+		RunE:        serveExternalDNS,
+		Annotations: map[string]string{nonInteractiveAnnotation: "true"},
+	}
+	cmdServeExternalDNS.Flags().String(
+		"listen", "127.0.0.1:8888", "Address to serve the webhook on",
+	)
+	cmdServeExternalDNS.Flags().StringSlice(
+		"domain", nil, "Domain external-dns may manage, every one if not given",
+	)
+	cmdServeExternalDNS.Flags().String(
+		"txt-owner-id", "default",
+		"The --txt-owner-id of external-dns, owning the records it may change",
+	)
+	cmdServeExternalDNS.Flags().String(
+		"rules", "", "YAML file with the rules limiting what may be changed",
+	)
+	addAuditFlag(cmdServeExternalDNS)
+	addTLSFlags(cmdServeExternalDNS)
 
 	rootCmd := &cobra.Command{
 		Use:   "njallaclient",
@@ -100,42 +469,124 @@ an integer number or else this command will fail.`,
 Since Njalla doesn't offer an API, this makes use of the go-njalla-dns-scrapper
 library to parse and interact with Njalla's website.
 This CLI allows you to list available domains, list records for a domain,
-adds, updates, or removes any one record from a domain.`,
+adds, updates, or removes any one record from a domain.
+
+Credentials are taken, in order, from --password-file, the NJALLA_EMAIL and
+NJALLA_PASSWORD environment variables, the email and password_command keys
+of njallaclient/config.yaml under $XDG_CONFIG_HOME, or ~/.config if it
+isn't set, the njal.la entry of ~/.netrc, and lastly an interactive prompt.
+Several accounts can be kept as profiles in the config file, selected with
+--profile or NJALLA_PROFILE. Sessions are cached per profile and email, so
+logging in again is only needed once they expire.`,
 	}
+	rootCmd.PersistentFlags().String(
+		"profile", "",
+		"Config profile to use, instead of NJALLA_PROFILE or the default",
+	)
+	rootCmd.PersistentFlags().String(
+		"password-file", "",
+		"File with the password, instead of NJALLA_PASSWORD or the prompt",
+	)
+	rootCmd.PersistentFlags().StringP(
+		"output", "o", "table",
+		"Output format: table, json, yaml, jsonl or zone",
+	)
 	rootCmd.AddCommand(cmdDomains)
 	rootCmd.AddCommand(cmdRecords)
+	rootCmd.AddCommand(cmdAdd)
+	rootCmd.AddCommand(cmdUpdate)
 	rootCmd.AddCommand(cmdRemove)
-	rootCmd.Execute()
+	rootCmd.AddCommand(cmdLint)
+	rootCmd.AddCommand(cmdExport)
+	rootCmd.AddCommand(cmdImport)
+	rootCmd.AddCommand(cmdPlan)
+	rootCmd.AddCommand(cmdApply)
+	rootCmd.AddCommand(cmdCertbotAuth)
+	rootCmd.AddCommand(cmdCertbotCleanup)
+	rootCmd.AddCommand(cmdServeRFC2136)
+	rootCmd.AddCommand(cmdServe)
+	rootCmd.AddCommand(cmdServeACMEDNS)
+	rootCmd.AddCommand(cmdServeExternalDNS)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
-func loginCLI() (*provider.Provider, error) {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Username: ")
-	username, err := reader.ReadString('\n')
+// loginCLI logs in to Njalla with the selected profile, see selectedProfile
+func loginCLI(cmd *cobra.Command) (*provider.Provider, error) {
+	c, err := loadConfig()
 	if err != nil {
 		return nil, err
 	}
-	username = strings.TrimSpace(username)
 
-	fmt.Print("Password: ")
-	pw, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	name, err := selectedProfile(cmd, c)
 	if err != nil {
 		return nil, err
 	}
-	password := strings.TrimSpace(string(pw))
 
-	fmt.Println()
+	p, err := c.getProfile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return loginProfile(cmd, name, p, true)
+}
+
+// domainCapabilities returns the capabilities of a domain, see
+// Provider.GetCapabilities. If they couldn't be got it warns about it and
+// returns the defaults
+func domainCapabilities(
+	njalla *provider.Provider, domain string,
+) structures.Capabilities {
+	caps, err := njalla.GetCapabilities(domain)
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Couldn't get the capabilities of %s, using the defaults: %s\n",
+			domain, err,
+		)
+	}
+	return caps
+}
 
+// loginProfile logs in to Njalla with a profile. If the profile has a valid
+// cached session it's reused, otherwise the credentials are resolved, see
+// credentials, and the new session is cached
+func loginProfile(
+	cmd *cobra.Command, name string, p profile, useOverrides bool,
+) (*provider.Provider, error) {
 	njalla, err := provider.New()
 	if err != nil {
 		return njalla,
 			fmt.Errorf("Error creating the Njalla provider: %s", err)
 	}
 
+	if p.BaseURL != "" {
+		njalla.BaseURL = strings.TrimSuffix(p.BaseURL, "/")
+	}
+
+	email, err := configuredEmail(p, useOverrides)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := sessionCachePath(name, email, p)
+	if restoreSession(njalla, cache) {
+		return njalla, nil
+	}
+
+	username, password, err := credentials(cmd, p, useOverrides)
+	if err != nil {
+		return nil, err
+	}
+
 	err = njalla.Login(username, password)
 	if err != nil {
 		return njalla, fmt.Errorf("Error logging in: %s", err)
 	}
 
+	if err := saveSession(njalla, cache); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't cache the session: %s\n", err)
+	}
+
 	return njalla, nil
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// recordOutput is the stable schema used to print records as JSON, JSON
+// Lines and YAML. Every key is always present, and fields that don't apply
+// to a record type, like `port` for an A record, are null
+type recordOutput struct {
+	ID           int    `json:"id" yaml:"id"`
+	Type         string `json:"type" yaml:"type"`
+	Name         string `json:"name" yaml:"name"`
+	Content      string `json:"content" yaml:"content"`
+	TTL          *int   `json:"ttl" yaml:"ttl"`
+	Priority     *int   `json:"prio" yaml:"prio"`
+	Weight       *int   `json:"weight" yaml:"weight"`
+	Port         *int   `json:"port" yaml:"port"`
+	SSHAlgorithm *int   `json:"ssh_algorithm" yaml:"ssh_algorithm"`
+	SSHType      *int   `json:"ssh_type" yaml:"ssh_type"`
+}
+
+func newRecordOutput(record records.Record) recordOutput {
+	values := record.GetURLValues()
+
+	optional := func(key string) *int {
+		value, err := strconv.Atoi(values.Get(key))
+		if err != nil {
+			return nil
+		}
+		return &value
+	}
+
+	return recordOutput{
+		ID:           record.GetID(),
+		Type:         record.GetType(),
+		Name:         record.GetName(),
+		Content:      record.GetContent(),
+		TTL:          optional("ttl"),
+		Priority:     optional("prio"),
+		Weight:       optional("weight"),
+		Port:         optional("port"),
+		SSHAlgorithm: optional("ssh_algorithm"),
+		SSHType:      optional("ssh_type"),
+	}
+}
+
+// domainOutput is the stable schema used to print domains as JSON, JSON
+// Lines and YAML
+type domainOutput struct {
+	Domain  string `json:"domain" yaml:"domain"`
+	Profile string `json:"profile" yaml:"profile"`
+}
+
+// outputFormat returns the value of the global --output flag
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", err
+	}
+
+	return checkOutputFormat(format)
+}
+
+// checkOutputFormat returns the format if it's one of those --output takes
+func checkOutputFormat(format string) (string, error) {
+	switch format {
+	case "table", "json", "yaml", "jsonl", "zone":
+		return format, nil
+	default:
+		return "", fmt.Errorf("Unknown output format: %s", format)
+	}
+}
+
+func printRecords(
+	w io.Writer, format string, domain string, r records.Records,
+) error {
+	if format == "zone" {
+		return r.WriteZoneFile(w, domain)
+	}
+
+	if format == "table" {
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "ID\tTYPE\tNAME\tCONTENT\tTTL\tPRIO")
+		for _, record := range r {
+			out := newRecordOutput(record)
+			fmt.Fprintf(
+				table, "%d\t%s\t%s\t%s\t%s\t%s\n", out.ID, out.Type, out.Name,
+				out.Content, optionalString(out.TTL),
+				optionalString(out.Priority),
+			)
+		}
+		return table.Flush()
+	}
+
+	items := make([]interface{}, len(r))
+	for i, record := range r {
+		items[i] = newRecordOutput(record)
+	}
+	return printItems(w, format, items)
+}
+
+func printDomains(w io.Writer, format string, domains []domainOutput) error {
+	switch format {
+	case "zone":
+		return fmt.Errorf("Domains can't be printed as a zone file")
+	case "table":
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "DOMAIN\tPROFILE")
+		for _, domain := range domains {
+			fmt.Fprintf(table, "%s\t%s\n", domain.Domain, domain.Profile)
+		}
+		return table.Flush()
+	}
+
+	items := make([]interface{}, len(domains))
+	for i, domain := range domains {
+		items[i] = domain
+	}
+	return printItems(w, format, items)
+}
+
+// printItems prints a list in one of the structured formats: json, jsonl or
+// yaml
+func printItems(w io.Writer, format string, items []interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		for _, item := range items {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(items)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("Unknown output format: %s", format)
+	}
+}
+
+func optionalString(value *int) string {
+	if value == nil {
+		return "-"
+	}
+	return strconv.Itoa(*value)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/reconcile"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+func planState(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	_, plans, err := loadPlans(cmd)
+	if err != nil {
+		return err
+	}
+
+	return printPlans(os.Stdout, format, plans)
+}
+
+func applyState(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	njalla, plans, err := loadPlans(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := printPlans(os.Stdout, format, plans); err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, plan := range plans {
+		if !plan.Empty() {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return nil
+	}
+
+	autoApprove, err := cmd.Flags().GetBool("auto-approve")
+	if err != nil {
+		return err
+	}
+
+	if !autoApprove {
+		confirmed, err := confirm(
+			fmt.Sprintf("Apply the changes to %d domain(s)?", changed),
+			"--auto-approve",
+		)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("Aborted")
+		}
+	}
+
+	for _, plan := range plans {
+		if plan.Empty() {
+			continue
+		}
+
+		if err := plan.Apply(njalla); err != nil {
+			return fmt.Errorf("Applying %s failed: %s", plan.Domain, err)
+		}
+	}
+
+	return nil
+}
+
+// loadPlans reads the desired state file given with --file, and plans the
+// changes for all its domains against Njalla
+func loadPlans(cmd *cobra.Command) (reconcile.Client, []*reconcile.Plan, error) {
+	path, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	state, err := reconcile.LoadState(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Couldn't parse %s: %s", path, err)
+	}
+
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caps := make(map[string]structures.Capabilities)
+	for _, domain := range state.DomainNames() {
+		caps[domain] = domainCapabilities(njalla, domain)
+	}
+	if err := state.ValidateWith(caps); err != nil {
+		return nil, nil, err
+	}
+
+	plans, err := reconcile.PlanState(njalla, state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return njalla, plans, nil
+}
+
+// printPlans prints the plans as text, or as a single JSON array or JSON
+// lines with one plan each
+func printPlans(w io.Writer, format string, plans []*reconcile.Plan) error {
+	switch format {
+	case "table":
+		for _, plan := range plans {
+			fmt.Fprint(w, plan)
+		}
+		return nil
+	case "json", "jsonl":
+		items := make([]interface{}, len(plans))
+		for i, plan := range plans {
+			items[i] = plan
+		}
+		return printItems(w, format, items)
+	default:
+		return fmt.Errorf("Plans can't be printed as %s", format)
+	}
+}
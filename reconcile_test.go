@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/reconcile"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func TestPrintPlansJSON(t *testing.T) {
+	plans := []*reconcile.Plan{
+		reconcile.NewPlan("example.com", nil, records.Records{
+			&records.RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300},
+		}),
+		reconcile.NewPlan("example.org", nil, nil),
+	}
+
+	var buf bytes.Buffer
+	if err := printPlans(&buf, "json", plans); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Every plan is in the same JSON document
+	var decoded []struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output isn't a JSON array: %s\n%s", err, buf.String())
+	}
+
+	domains := make([]string, len(decoded))
+	for i, plan := range decoded {
+		domains[i] = plan.Domain
+	}
+	if expected := []string{"example.com", "example.org"}; !cmp.Equal(expected, domains) {
+		t.Errorf("Plans don't match:\n%s", cmp.Diff(expected, domains))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func removeRecord(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+
+	if len(args) == 2 {
+		recordID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("RecordID is not a valid int value: %s", args[1])
+		}
+
+		njalla, err := loginCLI(cmd)
+		if err != nil {
+			return err
+		}
+
+		// The record is only needed to know what to wait for
+		removed := make(records.Records, 0, 1)
+		if wait, _ := cmd.Flags().GetBool("wait"); wait {
+			stored, err := njalla.GetRecords(domain)
+			if err != nil {
+				return err
+			}
+			if record, found := stored.FindByID(recordID); found {
+				removed = append(removed, record)
+			}
+		}
+
+		if err := njalla.RemoveRecord(domain, recordID); err != nil {
+			return err
+		}
+
+		return waitForChanges(cmd, domain, nil, removed)
+	}
+
+	yes, _ := cmd.Flags().GetBool("yes")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	filters, err := recordFilters(cmd, domain)
+	if err != nil {
+		return err
+	}
+
+	if len(filters) == 0 {
+		return fmt.Errorf(
+			"Either a record ID or at least one of --type, --name or " +
+				"--content-regex is required",
+		)
+	}
+
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return err
+	}
+
+	stored, err := njalla.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+
+	matched := stored.Filter(filters...)
+
+	if len(matched) == 0 {
+		fmt.Fprintln(os.Stderr, "No records matched")
+		return nil
+	}
+
+	if err := printRecords(os.Stdout, "table", domain, matched); err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if !yes {
+		confirmed, err := confirm(
+			fmt.Sprintf("Remove %d record(s) from %s?", len(matched), domain),
+			"--yes",
+		)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("Aborted")
+		}
+	}
+
+	ids := make([]int, len(matched))
+	for i, record := range matched {
+		ids[i] = record.GetID()
+	}
+
+	if err := njalla.RemoveRecords(domain, ids); err != nil {
+		return err
+	}
+
+	return waitForChanges(cmd, domain, nil, matched)
+}
+
+// confirm asks a yes/no question on the terminal. It fails if stdin isn't a
+// terminal, since there'd be no one to answer, pointing to the flag that
+// skips the question instead
+func confirm(question, skipFlag string) (bool, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf(
+			"Can't ask for confirmation without a terminal, use %s", skipFlag,
+		)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/acmedns"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/api"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/externaldns"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/policy"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/rfc2136"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// sessionCheckInterval is how often liveSession checks the session is still
+// valid before using it
+const sessionCheckInterval = time.Minute
+
+// liveSession keeps a Njalla session alive for long running servers, logging
+// in again with the selected profile whenever the session expires. It has
+// the methods of provider.Provider the servers need
+type liveSession struct {
+	cmd *cobra.Command
+
+	mu      sync.Mutex
+	njalla  *provider.Provider
+	checked time.Time
+	// capabilities caches those got for every domain, since they don't
+	// change while the server runs
+	capabilities map[string]structures.Capabilities
+}
+
+// newLiveSession logs in with the selected profile, see loginCLI
+func newLiveSession(cmd *cobra.Command) (*liveSession, error) {
+	njalla, err := loginCLI(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &liveSession{
+		cmd:          cmd,
+		njalla:       njalla,
+		checked:      time.Now(),
+		capabilities: make(map[string]structures.Capabilities),
+	}, nil
+}
+
+// provider returns a provider with a valid session
+func (s *liveSession) provider() (*provider.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.checked) < sessionCheckInterval {
+		return s.njalla, nil
+	}
+
+	if loggedIn, err := s.njalla.LoggedIn(); err != nil || !loggedIn {
+		njalla, err := loginCLI(s.cmd)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't log in again: %s", err)
+		}
+		s.njalla = njalla
+	}
+	s.checked = time.Now()
+
+	return s.njalla, nil
+}
+
+func (s *liveSession) GetDomains() ([]string, error) {
+	njalla, err := s.provider()
+	if err != nil {
+		return nil, err
+	}
+	return njalla.GetDomains()
+}
+
+func (s *liveSession) GetRecords(domain string) (records.Records, error) {
+	njalla, err := s.provider()
+	if err != nil {
+		return nil, err
+	}
+	return njalla.GetRecords(domain)
+}
+
+// GetCapabilities returns the capabilities of the domain, only asking
+// Njalla the first time they're got successfully
+func (s *liveSession) GetCapabilities(
+	domain string,
+) (structures.Capabilities, error) {
+	s.mu.Lock()
+	caps, cached := s.capabilities[domain]
+	s.mu.Unlock()
+	if cached {
+		return caps, nil
+	}
+
+	njalla, err := s.provider()
+	if err != nil {
+		return structures.DefaultCapabilities(), err
+	}
+
+	caps, err = njalla.GetCapabilities(domain)
+	if err != nil {
+		return caps, err
+	}
+
+	s.mu.Lock()
+	s.capabilities[domain] = caps
+	s.mu.Unlock()
+	return caps, nil
+}
+
+func (s *liveSession) AddRecord(domain string, record records.Record) error {
+	njalla, err := s.provider()
+	if err != nil {
+		return err
+	}
+	return njalla.AddRecord(domain, record)
+}
+
+func (s *liveSession) UpdateRecordTyped(
+	domain string, record records.Record,
+) error {
+	njalla, err := s.provider()
+	if err != nil {
+		return err
+	}
+	return njalla.UpdateRecordTyped(domain, record)
+}
+
+func (s *liveSession) RemoveRecord(domain string, recordID int) error {
+	njalla, err := s.provider()
+	if err != nil {
+		return err
+	}
+	return njalla.RemoveRecord(domain, recordID)
+}
+
+func (s *liveSession) RemoveRecords(domain string, recordIDs []int) error {
+	njalla, err := s.provider()
+	if err != nil {
+		return err
+	}
+	return njalla.RemoveRecords(domain, recordIDs)
+}
+
+func (s *liveSession) UpdateRecords(
+	domain string, updates map[int]records.Record, removeIDs []int,
+) error {
+	njalla, err := s.provider()
+	if err != nil {
+		return err
+	}
+	return njalla.UpdateRecords(domain, updates, removeIDs)
+}
+
+// addAuditFlag adds the --audit-log flag read by auditor
+func addAuditFlag(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"audit-log", "",
+		"File to append denied attempts to, as JSON lines, instead of stderr",
+	)
+}
+
+// auditor returns the auditor writing the denied attempts to --audit-log,
+// or to stderr if not given
+func auditor(cmd *cobra.Command) (policy.Auditor, error) {
+	path, _ := cmd.Flags().GetString("audit-log")
+	if path == "" {
+		return policy.NewJSONAuditor(os.Stderr), nil
+	}
+
+	file, err := os.OpenFile(
+		expandHome(path), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't open the audit log: %s", err)
+	}
+	return policy.NewJSONAuditor(file), nil
+}
+
+// rfc2136Keys reads the TSIG keys from a YAML file with a `keys` list
+func rfc2136Keys(path string) ([]rfc2136.Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read the keys file: %s", err)
+	}
+
+	var file struct {
+		Keys []rfc2136.Key `yaml:"keys"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("Couldn't parse %s: %s", path, err)
+	}
+
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("No keys found in %s", path)
+	}
+
+	return file.Keys, nil
+}
+
+func serveRFC2136(cmd *cobra.Command, args []string) error {
+	listen, _ := cmd.Flags().GetString("listen")
+	keysPath, _ := cmd.Flags().GetString("keys")
+
+	keys, err := rfc2136Keys(keysPath)
+	if err != nil {
+		return err
+	}
+	audit, err := auditor(cmd)
+	if err != nil {
+		return err
+	}
+
+	session, err := newLiveSession(cmd)
+	if err != nil {
+		return err
+	}
+
+	server, err := rfc2136.NewServer(session, keys)
+	if err != nil {
+		return err
+	}
+	server.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	server.Auditor = audit
+
+	server.Logger.Printf("Serving DNS UPDATE on %s", listen)
+	return server.ListenAndServe(listen)
+}
+
+// acmednsStorePath returns the file with the acme-dns registrations, from
+// --store or next to the config file
+func acmednsStorePath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("store"); path != "" {
+		return expandHome(path), nil
+	}
+
+	config, err := configPath()
+	if err != nil {
+		return "", fmt.Errorf("Couldn't find a place for the store: %s", err)
+	}
+	return filepath.Join(filepath.Dir(config), "acmedns.json"), nil
+}
+
+func serveACMEDNS(cmd *cobra.Command, args []string) error {
+	listen, _ := cmd.Flags().GetString("listen")
+	zone, _ := cmd.Flags().GetString("zone")
+	disableRegistration, _ := cmd.Flags().GetBool("disable-registration")
+	forwardedHeader, _ := cmd.Flags().GetString("forwarded-header")
+	storePath, err := acmednsStorePath(cmd)
+	if err != nil {
+		return err
+	}
+	store, err := acmedns.OpenStore(storePath)
+	if err != nil {
+		return err
+	}
+	audit, err := auditor(cmd)
+	if err != nil {
+		return err
+	}
+
+	session, err := newLiveSession(cmd)
+	if err != nil {
+		return err
+	}
+
+	server, err := acmedns.NewServer(session, store, zone)
+	if err != nil {
+		return err
+	}
+	server.DisableRegistration = disableRegistration
+	server.ForwardedHeader = forwardedHeader
+	server.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	server.Auditor = audit
+
+	server.Logger.Printf("Serving the acme-dns API on %s", listen)
+	return listenAndServeHTTP(cmd, listen, server)
+}
+
+// addTLSFlags adds the --tls-cert and --tls-key flags read by
+// listenAndServeHTTP
+func addTLSFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"tls-cert", "", "Certificate file, to serve over HTTPS",
+	)
+	cmd.Flags().String(
+		"tls-key", "", "Key file of the --tls-cert certificate",
+	)
+}
+
+// listenAndServeHTTP serves the handler on the address, over HTTPS if
+// --tls-cert and --tls-key were given
+func listenAndServeHTTP(
+	cmd *cobra.Command, listen string, handler http.Handler,
+) error {
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+
+	if (tlsCert == "") != (tlsKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+
+	if tlsCert != "" {
+		return http.ListenAndServeTLS(listen, tlsCert, tlsKey, handler)
+	}
+	return http.ListenAndServe(listen, handler)
+}
+
+// apiTokens reads the bearer tokens from a YAML file with a `tokens` list
+func apiTokens(path string) ([]api.Token, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read the tokens file: %s", err)
+	}
+
+	var file struct {
+		Tokens []api.Token `yaml:"tokens"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("Couldn't parse %s: %s", path, err)
+	}
+
+	if len(file.Tokens) == 0 {
+		return nil, fmt.Errorf("No tokens found in %s", path)
+	}
+
+	return file.Tokens, nil
+}
+
+func serveAPI(cmd *cobra.Command, args []string) error {
+	listen, _ := cmd.Flags().GetString("listen")
+	tokensPath, _ := cmd.Flags().GetString("tokens")
+
+	tokens, err := apiTokens(tokensPath)
+	if err != nil {
+		return err
+	}
+	audit, err := auditor(cmd)
+	if err != nil {
+		return err
+	}
+
+	session, err := newLiveSession(cmd)
+	if err != nil {
+		return err
+	}
+
+	server, err := api.NewServer(session, tokens)
+	if err != nil {
+		return err
+	}
+	server.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	server.Auditor = audit
+
+	server.Logger.Printf("Serving the API on %s", listen)
+	return listenAndServeHTTP(cmd, listen, server)
+}
+
+// policyRules reads the policy rules from a YAML file with a `rules` list
+func policyRules(path string) ([]policy.Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read the rules file: %s", err)
+	}
+
+	var file struct {
+		Rules []policy.Rule `yaml:"rules"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("Couldn't parse %s: %s", path, err)
+	}
+
+	if len(file.Rules) == 0 {
+		return nil, fmt.Errorf("No rules found in %s", path)
+	}
+
+	return file.Rules, nil
+}
+
+func serveExternalDNS(cmd *cobra.Command, args []string) error {
+	listen, _ := cmd.Flags().GetString("listen")
+	domains, _ := cmd.Flags().GetStringSlice("domain")
+	ownerID, _ := cmd.Flags().GetString("txt-owner-id")
+	rulesPath, _ := cmd.Flags().GetString("rules")
+
+	var rules []policy.Rule
+	if rulesPath != "" {
+		var err error
+		if rules, err = policyRules(rulesPath); err != nil {
+			return err
+		}
+	}
+	audit, err := auditor(cmd)
+	if err != nil {
+		return err
+	}
+
+	session, err := newLiveSession(cmd)
+	if err != nil {
+		return err
+	}
+
+	server, err := externaldns.NewServer(session, ownerID, rules)
+	if err != nil {
+		return err
+	}
+	server.Domains = domains
+	server.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	server.Auditor = audit
+
+	server.Logger.Printf("Serving the external-dns webhook on %s", listen)
+	return listenAndServeHTTP(cmd, listen, server)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+)
+
+// sessionCachePath returns the file where the session of a profile is
+// cached when logged in as email, or an empty string if there's no place
+// to cache it. Every email gets its own file, so a profile used with
+// another account, such as through NJALLA_EMAIL, doesn't reuse the session
+// of the profile's own account. A session_cache in the profile is used as
+// is for the profile's own email
+func sessionCachePath(name, email string, p profile) string {
+	suffix := ""
+	if email != "" {
+		suffix = "-" + url.PathEscape(email)
+	}
+
+	if p.SessionCache != "" {
+		path := expandHome(p.SessionCache)
+		if email == "" || email == p.Email {
+			return path
+		}
+		ext := filepath.Ext(path)
+		return strings.TrimSuffix(path, ext) + suffix + ext
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "njallaclient", name+suffix+"-session.json")
+}
+
+// restoreSession loads a cached session into the provider, returning true
+// only if the session is still valid
+func restoreSession(njalla *provider.Provider, path string) bool {
+	if path == "" {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	if err := njalla.LoadSession(file); err != nil {
+		return false
+	}
+
+	loggedIn, err := njalla.LoggedIn()
+	return err == nil && loggedIn
+}
+
+// saveSession caches the session of the provider, readable only by the user
+func saveSession(njalla *provider.Provider, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".session")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := njalla.SaveSession(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// The acceptance tests run terraform against the fake Njalla. They're
+// skipped unless TF_ACC is set
+
+func TestAccRecordResource(t *testing.T) {
+	njalla := newFakeNjalla()
+	defer njalla.Close()
+
+	config := func(ttl int) string {
+		return providerConfig(njalla) + fmt.Sprintf(`
+resource "njalla_record" "www" {
+  domain  = "example.com"
+  type    = "A"
+  name    = "www"
+  content = "2.2.2.2"
+  ttl     = %d
+}
+`, ttl)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: config(300),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("njalla_record.www", "ttl", "300"),
+					resource.TestCheckResourceAttrSet("njalla_record.www", "id"),
+					func(*terraform.State) error {
+						www := njalla.Records("example.com").Filter(records.ByName("www"))
+						if len(www) != 1 || www[0].GetContent() != "2.2.2.2" {
+							return fmt.Errorf("Record wasn't added: %s", www)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				ResourceName:      "njalla_record.www",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateIdFunc: func(s *terraform.State) (string, error) {
+					id := s.RootModule().Resources["njalla_record.www"].Primary.ID
+					return "example.com/" + id, nil
+				},
+			},
+			{
+				Config: config(3600),
+				Check: resource.TestCheckResourceAttr(
+					"njalla_record.www", "ttl", "3600",
+				),
+			},
+			{
+				// A change made outside of Terraform is planned away
+				PreConfig: func() {
+					client, err := njalla.Login()
+					if err != nil {
+						t.Fatalf("%s", err)
+					}
+					www := njalla.Records("example.com").Filter(records.ByName("www"))[0]
+					err = client.UpdateRecords("example.com", map[int]records.Record{
+						www.GetID(): www.WithContent("3.3.3.3"),
+					}, nil)
+					if err != nil {
+						t.Fatalf("%s", err)
+					}
+				},
+				Config:             config(3600),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config:      config(120),
+				ExpectError: regexp.MustCompile(`120 isn't accepted`),
+			},
+		},
+	})
+}
+
+func TestAccDataSources(t *testing.T) {
+	njalla := newFakeNjalla()
+	defer njalla.Close()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: providerConfig(njalla) + `
+data "njalla_domains" "all" {}
+
+data "njalla_records" "mx" {
+  domain = "example.com"
+  type   = "MX"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"data.njalla_domains.all", "domains.#", "2",
+					),
+					resource.TestCheckResourceAttr(
+						"data.njalla_records.mx", "records.#", "1",
+					),
+					resource.TestCheckResourceAttr(
+						"data.njalla_records.mx", "records.0.priority", "10",
+					),
+					resource.TestCheckResourceAttr(
+						"data.njalla_records.mx", "records.0.content",
+						"mail.example.com",
+					),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+var (
+	_ datasource.DataSourceWithConfigure = &recordsDataSource{}
+	_ datasource.DataSourceWithConfigure = &domainsDataSource{}
+)
+
+// recordsDataSource is the njalla_records data source
+type recordsDataSource struct {
+	session *session
+}
+
+// recordsModel is the njalla_records data source
+type recordsModel struct {
+	Domain  types.String  `tfsdk:"domain"`
+	Type    types.String  `tfsdk:"type"`
+	Name    types.String  `tfsdk:"name"`
+	Records []recordModel `tfsdk:"records"`
+}
+
+// NewRecordsDataSource returns the njalla_records data source
+func NewRecordsDataSource() datasource.DataSource {
+	return &recordsDataSource{}
+}
+
+func (d *recordsDataSource) Metadata(
+	ctx context.Context, req datasource.MetadataRequest,
+	resp *datasource.MetadataResponse,
+) {
+	resp.TypeName = req.ProviderTypeName + "_records"
+}
+
+func (d *recordsDataSource) Schema(
+	ctx context.Context, req datasource.SchemaRequest,
+	resp *datasource.SchemaResponse,
+) {
+	resp.Schema = schema.Schema{
+		Description: "The DNS records of a Njalla domain, as njalla_record " +
+			"resources would have them.",
+		Attributes: map[string]schema.Attribute{
+			"domain": schema.StringAttribute{
+				Description: "Domain to get the records of.",
+				Required:    true,
+			},
+			"type": schema.StringAttribute{
+				Description: "Only get the records of this type.",
+				Optional:    true,
+			},
+			"name": schema.StringAttribute{
+				Description: "Only get the records with this name, such as @.",
+				Optional:    true,
+			},
+			"records": schema.ListNestedAttribute{
+				Description: "The records, in the order Njalla has them.",
+				Computed:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id":            schema.StringAttribute{Computed: true},
+						"domain":        schema.StringAttribute{Computed: true},
+						"type":          schema.StringAttribute{Computed: true},
+						"name":          schema.StringAttribute{Computed: true},
+						"ttl":           schema.Int64Attribute{Computed: true},
+						"content":       schema.StringAttribute{Computed: true},
+						"priority":      schema.Int64Attribute{Computed: true},
+						"weight":        schema.Int64Attribute{Computed: true},
+						"port":          schema.Int64Attribute{Computed: true},
+						"redirect_type": schema.Int64Attribute{Computed: true},
+						"ssh_algorithm": schema.Int64Attribute{Computed: true},
+						"ssh_type":      schema.Int64Attribute{Computed: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *recordsDataSource) Configure(
+	ctx context.Context, req datasource.ConfigureRequest,
+	resp *datasource.ConfigureResponse,
+) {
+	d.session = sessionFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (d *recordsDataSource) Read(
+	ctx context.Context, req datasource.ReadRequest,
+	resp *datasource.ReadResponse,
+) {
+	var config recordsModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	domain := config.Domain.ValueString()
+	stored, err := d.session.client.GetRecords(domain)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Couldn't get the records of %s", domain), err.Error(),
+		)
+		return
+	}
+
+	if !config.Type.IsNull() {
+		stored = stored.Filter(records.ByType(config.Type.ValueString()))
+	}
+	if !config.Name.IsNull() {
+		stored = stored.Filter(records.ByName(config.Name.ValueString()))
+	}
+
+	config.Records = make([]recordModel, 0, len(stored))
+	for _, record := range stored {
+		m, err := fromRecord(domain, record)
+		if err != nil {
+			resp.Diagnostics.AddError("Couldn't convert the record", err.Error())
+			return
+		}
+		config.Records = append(config.Records, m)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
+
+// domainsDataSource is the njalla_domains data source
+type domainsDataSource struct {
+	session *session
+}
+
+// domainsModel is the njalla_domains data source
+type domainsModel struct {
+	Domains []string `tfsdk:"domains"`
+}
+
+// NewDomainsDataSource returns the njalla_domains data source
+func NewDomainsDataSource() datasource.DataSource {
+	return &domainsDataSource{}
+}
+
+func (d *domainsDataSource) Metadata(
+	ctx context.Context, req datasource.MetadataRequest,
+	resp *datasource.MetadataResponse,
+) {
+	resp.TypeName = req.ProviderTypeName + "_domains"
+}
+
+func (d *domainsDataSource) Schema(
+	ctx context.Context, req datasource.SchemaRequest,
+	resp *datasource.SchemaResponse,
+) {
+	resp.Schema = schema.Schema{
+		Description: "The domains of the Njalla account.",
+		Attributes: map[string]schema.Attribute{
+			"domains": schema.ListAttribute{
+				Description: "Names of the domains.",
+				Computed:    true,
+				ElementType: types.StringType,
+			},
+		},
+	}
+}
+
+func (d *domainsDataSource) Configure(
+	ctx context.Context, req datasource.ConfigureRequest,
+	resp *datasource.ConfigureResponse,
+) {
+	d.session = sessionFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (d *domainsDataSource) Read(
+	ctx context.Context, req datasource.ReadRequest,
+	resp *datasource.ReadResponse,
+) {
+	domains, err := d.session.client.GetDomains()
+	if err != nil {
+		resp.Diagnostics.AddError("Couldn't get the domains", err.Error())
+		return
+	}
+
+	state := domainsModel{Domains: domains}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
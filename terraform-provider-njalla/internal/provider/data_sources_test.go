@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func dataSourceState(
+	t *testing.T, d datasource.DataSource, model interface{},
+) tfsdk.State {
+	ctx := context.Background()
+	var resp datasource.SchemaResponse
+	d.Schema(ctx, datasource.SchemaRequest{}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("%v", resp.Diagnostics)
+	}
+
+	state := tfsdk.State{
+		Schema: resp.Schema,
+		Raw:    tftypes.NewValue(resp.Schema.Type().TerraformType(ctx), nil),
+	}
+	if diags := state.Set(ctx, model); diags.HasError() {
+		t.Fatalf("%v", diags)
+	}
+	return state
+}
+
+func TestRecordsDataSource(t *testing.T) {
+	njalla := newFakeNjalla()
+	defer njalla.Close()
+	d := &recordsDataSource{session: newTestSession(t, njalla)}
+
+	config := dataSourceState(t, d, &recordsModel{
+		Domain: types.StringValue("example.com"),
+		Type:   types.StringValue("MX"),
+		Name:   types.StringNull(),
+	})
+	resp := datasource.ReadResponse{State: config}
+	d.Read(context.Background(), datasource.ReadRequest{
+		Config: tfsdk.Config{Schema: config.Schema, Raw: config.Raw},
+	}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("%v", resp.Diagnostics)
+	}
+
+	var got recordsModel
+	resp.State.Get(context.Background(), &got)
+	mx := nullModel()
+	mx.ID = types.StringValue("1001")
+	mx.Domain = types.StringValue("example.com")
+	mx.Type = types.StringValue("MX")
+	mx.Name = types.StringValue("@")
+	mx.TTL = types.Int64Value(3600)
+	mx.Content = types.StringValue("mail.example.com")
+	mx.Priority = types.Int64Value(10)
+	if diff := cmp.Diff([]recordModel{mx}, got.Records); diff != "" {
+		t.Errorf("Records don't match:\n%s", diff)
+	}
+}
+
+func TestDomainsDataSource(t *testing.T) {
+	njalla := newFakeNjalla()
+	defer njalla.Close()
+	d := &domainsDataSource{session: newTestSession(t, njalla)}
+
+	state := dataSourceState(t, d, &domainsModel{})
+	resp := datasource.ReadResponse{State: state}
+	d.Read(context.Background(), datasource.ReadRequest{}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("%v", resp.Diagnostics)
+	}
+
+	var got domainsModel
+	resp.State.Get(context.Background(), &got)
+	expected := []string{"example.com", "example.org"}
+	if diff := cmp.Diff(expected, got.Domains); diff != "" {
+		t.Errorf("Domains don't match:\n%s", diff)
+	}
+}
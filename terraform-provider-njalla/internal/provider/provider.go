@@ -0,0 +1,175 @@
+// Package provider implements the njalla Terraform provider: the
+// njalla_record resource and the njalla_records and njalla_domains data
+// sources
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	njalla "github.com/Sighery/go-njalla-dns-scraper/njalla/provider"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// Client is the part of njalla/provider.Provider the resources and data
+// sources need
+type Client interface {
+	GetDomains() ([]string, error)
+	GetRecords(domain string) (records.Records, error)
+	AddRecord(domain string, record records.Record) error
+	UpdateRecords(
+		domain string, updates map[int]records.Record, removeIDs []int,
+	) error
+	RemoveRecord(domain string, recordID int) error
+}
+
+// session is handed to the resources and data sources. Terraform makes
+// changes in parallel, so mu serialises them, since finding the ID of an
+// added record relies on nothing else being added at the same time
+type session struct {
+	client Client
+	mu     sync.Mutex
+}
+
+// njallaProvider implements provider.Provider
+type njallaProvider struct {
+	version string
+}
+
+// providerModel is the provider configuration
+type providerModel struct {
+	Email    types.String `tfsdk:"email"`
+	Password types.String `tfsdk:"password"`
+	BaseURL  types.String `tfsdk:"base_url"`
+}
+
+// New returns a function returning the provider, as providerserver wants
+func New(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &njallaProvider{version: version}
+	}
+}
+
+func (p *njallaProvider) Metadata(
+	ctx context.Context, req provider.MetadataRequest,
+	resp *provider.MetadataResponse,
+) {
+	resp.TypeName = "njalla"
+	resp.Version = p.version
+}
+
+func (p *njallaProvider) Schema(
+	ctx context.Context, req provider.SchemaRequest,
+	resp *provider.SchemaResponse,
+) {
+	resp.Schema = schema.Schema{
+		Description: "Manages the DNS records of Njalla domains.",
+		Attributes: map[string]schema.Attribute{
+			"email": schema.StringAttribute{
+				Description: "Email of the Njalla account. Taken from " +
+					"NJALLA_EMAIL if not set.",
+				Optional: true,
+			},
+			"password": schema.StringAttribute{
+				Description: "Password of the Njalla account. Taken from " +
+					"NJALLA_PASSWORD if not set.",
+				Optional:  true,
+				Sensitive: true,
+			},
+			"base_url": schema.StringAttribute{
+				Description: "Address of Njalla's website, " +
+					"https://njal.la by default.",
+				Optional: true,
+			},
+		},
+	}
+}
+
+func (p *njallaProvider) Configure(
+	ctx context.Context, req provider.ConfigureRequest,
+	resp *provider.ConfigureResponse,
+) {
+	var config providerModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	email := os.Getenv("NJALLA_EMAIL")
+	if !config.Email.IsNull() {
+		email = config.Email.ValueString()
+	}
+	password := os.Getenv("NJALLA_PASSWORD")
+	if !config.Password.IsNull() {
+		password = config.Password.ValueString()
+	}
+	if email == "" || password == "" {
+		resp.Diagnostics.AddError(
+			"Missing credentials",
+			"The email and password of the Njalla account must be set in "+
+				"the provider configuration, or in NJALLA_EMAIL and "+
+				"NJALLA_PASSWORD.",
+		)
+		return
+	}
+
+	client, err := njalla.New()
+	if err != nil {
+		resp.Diagnostics.AddError("Couldn't create the Njalla client", err.Error())
+		return
+	}
+	if !config.BaseURL.IsNull() {
+		client.BaseURL = strings.TrimSuffix(config.BaseURL.ValueString(), "/")
+	}
+	if err := client.Login(email, password); err != nil {
+		resp.Diagnostics.AddError("Couldn't log in to Njalla", err.Error())
+		return
+	}
+
+	s := &session{client: client}
+	resp.ResourceData = s
+	resp.DataSourceData = s
+}
+
+func (p *njallaProvider) Resources(
+	ctx context.Context,
+) []func() resource.Resource {
+	return []func() resource.Resource{NewRecordResource}
+}
+
+func (p *njallaProvider) DataSources(
+	ctx context.Context,
+) []func() datasource.DataSource {
+	return []func() datasource.DataSource{
+		NewRecordsDataSource, NewDomainsDataSource,
+	}
+}
+
+// sessionFrom returns the session given by Configure, or nil with an error
+// diagnostic if the data isn't one. It's nil without errors before the
+// provider is configured
+func sessionFrom(data interface{}, diags *diag.Diagnostics) *session {
+	if data == nil {
+		return nil
+	}
+
+	s, ok := data.(*session)
+	if !ok {
+		diags.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Expected *session, got %T", data),
+		)
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/njallatest"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// testAccProviders are the providers of the acceptance tests, which only run
+// with TF_ACC set and a terraform binary available
+var testAccProviders = map[string]func() (tfprotov6.ProviderServer, error){
+	"njalla": providerserver.NewProtocol6WithError(New("test")()),
+}
+
+// newFakeNjalla starts a fake Njalla with example.com and its records
+func newFakeNjalla() *njallatest.Server {
+	njalla := njallatest.NewServer("user@example.com", "secret")
+	njalla.AddDomain(
+		"example.com",
+		&records.RecordA{Type: "A", Name: "@", Content: "1.1.1.1", TTL: 300},
+		&records.RecordMX{
+			Type: "MX", Name: "@", Content: "mail.example.com", TTL: 3600,
+			Priority: 10,
+		},
+	)
+	njalla.AddDomain("example.org")
+	return njalla
+}
+
+// providerConfig is the provider block pointing to the fake Njalla
+func providerConfig(njalla *njallatest.Server) string {
+	return fmt.Sprintf(`
+provider "njalla" {
+  email    = %q
+  password = %q
+  base_url = %q
+}
+`, njalla.Email, njalla.Password, njalla.URL)
+}
+
+// newTestSession returns a session logged in to the fake Njalla
+func newTestSession(t *testing.T, njalla *njallatest.Server) *session {
+	client, err := njalla.Login()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return &session{client: client}
+}
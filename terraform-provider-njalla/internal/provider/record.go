@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+// defaultTTL is used for records without a TTL, as Njalla's website does
+const defaultTTL = structures.TTL10800
+
+// fieldsByType are the attributes each record type has, besides id, domain,
+// type and name. All of them are required but ttl
+var fieldsByType = map[string][]string{
+	"A":        {"content", "ttl"},
+	"AAAA":     {"content", "ttl"},
+	"CNAME":    {"content", "ttl"},
+	"MX":       {"content", "ttl", "priority"},
+	"TXT":      {"content", "ttl"},
+	"SRV":      {"content", "ttl", "priority", "weight", "port"},
+	"CAA":      {"content", "ttl"},
+	"PTR":      {"content", "ttl"},
+	"NS":       {"content", "ttl"},
+	"TLSA":     {"content", "ttl"},
+	"Redirect": {"content", "redirect_type"},
+	"Dynamic":  {"ttl"},
+	"SSHFP":    {"content", "ttl", "ssh_algorithm", "ssh_type"},
+}
+
+// jsonKeys are the fields of the records JSON holding each attribute
+var jsonKeys = map[string]string{
+	"content":       "content",
+	"ttl":           "ttl",
+	"priority":      "prio",
+	"weight":        "weight",
+	"port":          "port",
+	"redirect_type": "prio",
+	"ssh_algorithm": "ssh_algorithm",
+	"ssh_type":      "ssh_type",
+}
+
+// recordModel is a record, as the njalla_record resource and the items of
+// the njalla_records data source
+type recordModel struct {
+	ID           types.String `tfsdk:"id"`
+	Domain       types.String `tfsdk:"domain"`
+	Type         types.String `tfsdk:"type"`
+	Name         types.String `tfsdk:"name"`
+	TTL          types.Int64  `tfsdk:"ttl"`
+	Content      types.String `tfsdk:"content"`
+	Priority     types.Int64  `tfsdk:"priority"`
+	Weight       types.Int64  `tfsdk:"weight"`
+	Port         types.Int64  `tfsdk:"port"`
+	RedirectType types.Int64  `tfsdk:"redirect_type"`
+	SSHAlgorithm types.Int64  `tfsdk:"ssh_algorithm"`
+	SSHType      types.Int64  `tfsdk:"ssh_type"`
+}
+
+// numbers returns the numeric attributes by name
+func (m *recordModel) numbers() map[string]*types.Int64 {
+	return map[string]*types.Int64{
+		"ttl":           &m.TTL,
+		"priority":      &m.Priority,
+		"weight":        &m.Weight,
+		"port":          &m.Port,
+		"redirect_type": &m.RedirectType,
+		"ssh_algorithm": &m.SSHAlgorithm,
+		"ssh_type":      &m.SSHType,
+	}
+}
+
+// fields returns the attributes of fieldsByType by name
+func (m *recordModel) fields() map[string]attr.Value {
+	fields := map[string]attr.Value{"content": m.Content}
+	for name, value := range m.numbers() {
+		fields[name] = *value
+	}
+	return fields
+}
+
+// hasTTL returns true if records of the type have a TTL
+func hasTTL(recordType string) bool {
+	for _, field := range fieldsByType[recordType] {
+		if field == "ttl" {
+			return true
+		}
+	}
+	return false
+}
+
+// toRecord converts the model into a record of its type, without the
+// default TTL. The ID is only set if known
+func (m *recordModel) toRecord() (records.Record, error) {
+	recordType := m.Type.ValueString()
+	fields, exists := fieldsByType[recordType]
+	if !exists {
+		return nil, fmt.Errorf("Unknown record type: %s", recordType)
+	}
+
+	values := map[string]interface{}{
+		"type": recordType,
+		"name": m.Name.ValueString(),
+	}
+	if !m.ID.IsNull() && !m.ID.IsUnknown() {
+		id, err := strconv.Atoi(m.ID.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("Invalid record ID %s", m.ID.ValueString())
+		}
+		values["id"] = id
+	}
+
+	numbers := m.numbers()
+	for _, field := range fields {
+		if field == "content" {
+			values["content"] = m.Content.ValueString()
+			continue
+		}
+		values[jsonKeys[field]] = numbers[field].ValueInt64()
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return records.UnmarshalRecord(data)
+}
+
+// fromRecord converts a record of the domain into a model
+func fromRecord(domain string, record records.Record) (recordModel, error) {
+	m := recordModel{
+		ID:           types.StringValue(strconv.Itoa(record.GetID())),
+		Domain:       types.StringValue(domain),
+		Type:         types.StringValue(record.GetType()),
+		Name:         types.StringValue(record.GetName()),
+		TTL:          types.Int64Null(),
+		Content:      types.StringNull(),
+		Priority:     types.Int64Null(),
+		Weight:       types.Int64Null(),
+		Port:         types.Int64Null(),
+		RedirectType: types.Int64Null(),
+		SSHAlgorithm: types.Int64Null(),
+		SSHType:      types.Int64Null(),
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return m, err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return m, err
+	}
+
+	numbers := m.numbers()
+	for _, field := range fieldsByType[record.GetType()] {
+		value := values[jsonKeys[field]]
+		if field == "content" {
+			content, _ := value.(string)
+			m.Content = types.StringValue(content)
+			continue
+		}
+		number, _ := value.(float64)
+		*numbers[field] = types.Int64Value(int64(number))
+	}
+
+	return m, nil
+}
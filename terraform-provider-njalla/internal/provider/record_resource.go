@@ -0,0 +1,441 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/structures"
+)
+
+var (
+	_ resource.ResourceWithConfigure      = &recordResource{}
+	_ resource.ResourceWithImportState    = &recordResource{}
+	_ resource.ResourceWithValidateConfig = &recordResource{}
+	_ planmodifier.Int64                  = ttlDefault{}
+	_ validator.Int64                     = oneOf{}
+	_ validator.String                    = oneOf{}
+)
+
+// recordResource is the njalla_record resource
+type recordResource struct {
+	session *session
+}
+
+// NewRecordResource returns the njalla_record resource
+func NewRecordResource() resource.Resource {
+	return &recordResource{}
+}
+
+func (r *recordResource) Metadata(
+	ctx context.Context, req resource.MetadataRequest,
+	resp *resource.MetadataResponse,
+) {
+	resp.TypeName = req.ProviderTypeName + "_record"
+}
+
+func (r *recordResource) Schema(
+	ctx context.Context, req resource.SchemaRequest,
+	resp *resource.SchemaResponse,
+) {
+	caps := structures.DefaultCapabilities()
+	recordTypes := append([]string{}, records.Types...)
+	sort.Strings(recordTypes)
+
+	resp.Schema = schema.Schema{
+		Description: "A DNS record of a Njalla domain. Which attributes a " +
+			"record has depends on its type: content and ttl for most of " +
+			"them, priority for MX, priority, weight and port for SRV, " +
+			"ssh_algorithm and ssh_type for SSHFP, and redirect_type " +
+			"instead of ttl for Redirect. Import with `domain/id`.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Description: "ID of the record in Njalla.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"domain": schema.StringAttribute{
+				Description: "Domain of the record, such as example.com.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"type": schema.StringAttribute{
+				Description: "Type of the record, one of " +
+					strings.Join(recordTypes, ", ") + ".",
+				Required: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{oneOfStrings(recordTypes)},
+			},
+			"name": schema.StringAttribute{
+				Description: "Name of the record, relative to the domain. " +
+					"@ for the domain itself, the default.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("@"),
+			},
+			"ttl": schema.Int64Attribute{
+				Description: fmt.Sprintf(
+					"TTL of the record, %d by default. Redirect records "+
+						"have none.", defaultTTL,
+				),
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Int64{ttlDefault{}},
+				Validators:    []validator.Int64{oneOfInts(caps.TTLs)},
+			},
+			"content": schema.StringAttribute{
+				Description: "Content of the record, such as the address of " +
+					"A records or the URL of Redirect records. Dynamic " +
+					"records have none.",
+				Optional: true,
+			},
+			"priority": schema.Int64Attribute{
+				Description: "Priority of MX and SRV records.",
+				Optional:    true,
+				Validators:  []validator.Int64{oneOfInts(caps.Priorities)},
+			},
+			"weight": schema.Int64Attribute{
+				Description: "Weight of SRV records.",
+				Optional:    true,
+			},
+			"port": schema.Int64Attribute{
+				Description: "Port of SRV records.",
+				Optional:    true,
+			},
+			"redirect_type": schema.Int64Attribute{
+				Description: "HTTP status of Redirect records, 301 or 302.",
+				Optional:    true,
+				Validators:  []validator.Int64{oneOfInts(caps.RedirectTypes)},
+			},
+			"ssh_algorithm": schema.Int64Attribute{
+				Description: "Key algorithm of SSHFP records.",
+				Optional:    true,
+				Validators:  []validator.Int64{oneOfInts(caps.SSHAlgorithms)},
+			},
+			"ssh_type": schema.Int64Attribute{
+				Description: "Fingerprint type of SSHFP records.",
+				Optional:    true,
+				Validators:  []validator.Int64{oneOfInts(caps.SSHTypes)},
+			},
+		},
+	}
+}
+
+func (r *recordResource) Configure(
+	ctx context.Context, req resource.ConfigureRequest,
+	resp *resource.ConfigureResponse,
+) {
+	r.session = sessionFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+// ValidateConfig checks the attributes are those of the record type, and
+// validates the record once every attribute is known
+func (r *recordResource) ValidateConfig(
+	ctx context.Context, req resource.ValidateConfigRequest,
+	resp *resource.ValidateConfigResponse,
+) {
+	var config recordModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() || config.Type.IsUnknown() {
+		return
+	}
+
+	recordType := config.Type.ValueString()
+	allowed, exists := fieldsByType[recordType]
+	if !exists {
+		return
+	}
+
+	known := !config.Name.IsUnknown()
+	for name, value := range config.fields() {
+		has := false
+		for _, field := range allowed {
+			has = has || field == name
+		}
+
+		switch {
+		case value.IsUnknown():
+			known = false
+		case !has && !value.IsNull():
+			resp.Diagnostics.AddAttributeError(
+				path.Root(name), "Unexpected attribute",
+				fmt.Sprintf("%s records don't have %s.", recordType, name),
+			)
+		case has && name != "ttl" && value.IsNull():
+			resp.Diagnostics.AddAttributeError(
+				path.Root(name), "Missing attribute",
+				fmt.Sprintf("%s records need %s.", recordType, name),
+			)
+		}
+	}
+	if resp.Diagnostics.HasError() || !known {
+		return
+	}
+
+	config.ID = types.StringNull()
+	if config.Name.IsNull() {
+		config.Name = types.StringValue("@")
+	}
+	if config.TTL.IsNull() {
+		config.TTL = types.Int64Value(defaultTTL)
+	}
+	record, err := config.toRecord()
+	if err == nil {
+		err = record.Validate()
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid record", err.Error())
+	}
+}
+
+func (r *recordResource) Create(
+	ctx context.Context, req resource.CreateRequest,
+	resp *resource.CreateResponse,
+) {
+	var plan recordModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = types.StringNull()
+	record, err := plan.toRecord()
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid record", err.Error())
+		return
+	}
+
+	r.session.mu.Lock()
+	defer r.session.mu.Unlock()
+
+	domain := plan.Domain.ValueString()
+	added, err := r.add(domain, record)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Couldn't add the record to %s", domain), err.Error(),
+		)
+		return
+	}
+
+	plan.ID = types.StringValue(strconv.Itoa(added.GetID()))
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// add adds the record, and returns it as stored. Njalla doesn't return the
+// new record, so it's found among the records that weren't there before
+func (r *recordResource) add(
+	domain string, record records.Record,
+) (records.Record, error) {
+	before, err := r.session.client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.session.client.AddRecord(domain, record); err != nil {
+		return nil, err
+	}
+	after, err := r.session.client.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	key := records.MatchKey(domain, record)
+	for _, stored := range after {
+		if _, existed := before.FindByID(stored.GetID()); existed {
+			continue
+		}
+		if records.MatchKey(domain, stored) == key {
+			return stored, nil
+		}
+	}
+	return nil, fmt.Errorf("Couldn't find the added record")
+}
+
+// Read refreshes the record from Njalla, so changes made outside of
+// Terraform show up as drift, and removes it from the state if it's gone
+func (r *recordResource) Read(
+	ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse,
+) {
+	var state recordModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	id, err := strconv.Atoi(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid record ID", err.Error())
+		return
+	}
+
+	domain := state.Domain.ValueString()
+	stored, err := r.session.client.GetRecords(domain)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Couldn't get the records of %s", domain), err.Error(),
+		)
+		return
+	}
+
+	record, exists := stored.FindByID(id)
+	if !exists {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	refreshed, err := fromRecord(domain, record)
+	if err != nil {
+		resp.Diagnostics.AddError("Couldn't convert the record", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &refreshed)...)
+}
+
+func (r *recordResource) Update(
+	ctx context.Context, req resource.UpdateRequest,
+	resp *resource.UpdateResponse,
+) {
+	var plan, state recordModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = state.ID
+	record, err := plan.toRecord()
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid record", err.Error())
+		return
+	}
+
+	r.session.mu.Lock()
+	defer r.session.mu.Unlock()
+
+	domain := plan.Domain.ValueString()
+	err = r.session.client.UpdateRecords(
+		domain, map[int]records.Record{record.GetID(): record}, nil,
+	)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Couldn't update the record of %s", domain),
+			err.Error(),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *recordResource) Delete(
+	ctx context.Context, req resource.DeleteRequest,
+	resp *resource.DeleteResponse,
+) {
+	var state recordModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	id, err := strconv.Atoi(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid record ID", err.Error())
+		return
+	}
+
+	r.session.mu.Lock()
+	defer r.session.mu.Unlock()
+
+	domain := state.Domain.ValueString()
+	if err := r.session.client.RemoveRecord(domain, id); err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Couldn't remove the record of %s", domain),
+			err.Error(),
+		)
+	}
+}
+
+// ImportState imports a record by `domain/id`
+func (r *recordResource) ImportState(
+	ctx context.Context, req resource.ImportStateRequest,
+	resp *resource.ImportStateResponse,
+) {
+	parts := strings.Split(req.ID, "/")
+	if len(parts) != 2 || parts[0] == "" {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf(
+				"Expected domain/id, such as example.com/123, got %s", req.ID,
+			),
+		)
+		return
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("The record ID %s isn't a number", parts[1]),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(
+		resp.State.SetAttribute(ctx, path.Root("domain"), parts[0])...,
+	)
+	resp.Diagnostics.Append(
+		resp.State.SetAttribute(ctx, path.Root("id"), parts[1])...,
+	)
+}
+
+// ttlDefault plans the default TTL for records without one in the
+// configuration, or none for the types without TTL
+type ttlDefault struct{}
+
+func (m ttlDefault) Description(ctx context.Context) string {
+	return fmt.Sprintf(
+		"Defaults to %d, or nothing for Redirect records", defaultTTL,
+	)
+}
+
+func (m ttlDefault) MarkdownDescription(ctx context.Context) string {
+	return m.Description(ctx)
+}
+
+func (m ttlDefault) PlanModifyInt64(
+	ctx context.Context, req planmodifier.Int64Request,
+	resp *planmodifier.Int64Response,
+) {
+	if !req.ConfigValue.IsNull() {
+		return
+	}
+
+	var recordType types.String
+	resp.Diagnostics.Append(
+		req.Plan.GetAttribute(ctx, path.Root("type"), &recordType)...,
+	)
+	if resp.Diagnostics.HasError() || recordType.IsUnknown() {
+		return
+	}
+
+	if hasTTL(recordType.ValueString()) {
+		resp.PlanValue = types.Int64Value(defaultTTL)
+	} else {
+		resp.PlanValue = types.Int64Null()
+	}
+}
@@ -0,0 +1,342 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+func resourceSchema(t *testing.T) schema.Schema {
+	var resp resource.SchemaResponse
+	NewRecordResource().Schema(context.Background(), resource.SchemaRequest{}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("%v", resp.Diagnostics)
+	}
+	return resp.Schema
+}
+
+// nullModel returns a model with every attribute null
+func nullModel() recordModel {
+	return recordModel{
+		ID:           types.StringNull(),
+		Domain:       types.StringNull(),
+		Type:         types.StringNull(),
+		Name:         types.StringNull(),
+		TTL:          types.Int64Null(),
+		Content:      types.StringNull(),
+		Priority:     types.Int64Null(),
+		Weight:       types.Int64Null(),
+		Port:         types.Int64Null(),
+		RedirectType: types.Int64Null(),
+		SSHAlgorithm: types.Int64Null(),
+		SSHType:      types.Int64Null(),
+	}
+}
+
+// newState returns a state of the schema holding the model
+func newState(t *testing.T, s schema.Schema, m recordModel) tfsdk.State {
+	ctx := context.Background()
+	state := tfsdk.State{
+		Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(ctx), nil),
+	}
+	if diags := state.Set(ctx, &m); diags.HasError() {
+		t.Fatalf("%v", diags)
+	}
+	return state
+}
+
+func emptyState(s schema.Schema) tfsdk.State {
+	ctx := context.Background()
+	return tfsdk.State{
+		Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(ctx), nil),
+	}
+}
+
+func getModel(t *testing.T, state tfsdk.State) recordModel {
+	var m recordModel
+	if diags := state.Get(context.Background(), &m); diags.HasError() {
+		t.Fatalf("%v", diags)
+	}
+	return m
+}
+
+func TestRecordResourceLifecycle(t *testing.T) {
+	njalla := newFakeNjalla()
+	defer njalla.Close()
+	r := &recordResource{session: newTestSession(t, njalla)}
+	s := resourceSchema(t)
+	ctx := context.Background()
+
+	planned := nullModel()
+	planned.ID = types.StringUnknown()
+	planned.Domain = types.StringValue("example.com")
+	planned.Type = types.StringValue("SRV")
+	planned.Name = types.StringValue("_sip._tcp")
+	planned.TTL = types.Int64Value(3600)
+	planned.Content = types.StringValue("sip.example.com")
+	planned.Priority = types.Int64Value(10)
+	planned.Weight = types.Int64Value(5)
+	planned.Port = types.Int64Value(5060)
+
+	plan := newState(t, s, planned)
+	createResp := resource.CreateResponse{State: emptyState(s)}
+	r.Create(ctx, resource.CreateRequest{
+		Plan: tfsdk.Plan{Schema: s, Raw: plan.Raw},
+	}, &createResp)
+	if createResp.Diagnostics.HasError() {
+		t.Fatalf("%v", createResp.Diagnostics)
+	}
+
+	created := getModel(t, createResp.State)
+	srv := njalla.Records("example.com").Filter(records.ByType("SRV"))
+	if len(srv) != 1 || created.ID.ValueString() != "1002" {
+		t.Fatalf("Unexpected records after create: %s, ID %s", srv, created.ID)
+	}
+
+	// Reading it back gives the same state
+	readResp := resource.ReadResponse{State: createResp.State}
+	r.Read(ctx, resource.ReadRequest{State: createResp.State}, &readResp)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("%v", readResp.Diagnostics)
+	}
+	if diff := cmp.Diff(created, getModel(t, readResp.State)); diff != "" {
+		t.Errorf("Read doesn't match the created record:\n%s", diff)
+	}
+
+	updated := created
+	updated.TTL = types.Int64Value(60)
+	updateResp := resource.UpdateResponse{State: createResp.State}
+	r.Update(ctx, resource.UpdateRequest{
+		Plan:  tfsdk.Plan{Schema: s, Raw: newState(t, s, updated).Raw},
+		State: createResp.State,
+	}, &updateResp)
+	if updateResp.Diagnostics.HasError() {
+		t.Fatalf("%v", updateResp.Diagnostics)
+	}
+	stored, _ := njalla.Records("example.com").FindByID(1002)
+	if stored == nil || stored.GetTTL() != 60 {
+		t.Errorf("Record wasn't updated: %s", stored)
+	}
+
+	// Changes made outside of Terraform show up when reading
+	changed := stored.WithContent("sip2.example.com")
+	if err := r.session.client.UpdateRecords(
+		"example.com", map[int]records.Record{1002: changed}, nil,
+	); err != nil {
+		t.Fatalf("%s", err)
+	}
+	readResp = resource.ReadResponse{State: updateResp.State}
+	r.Read(ctx, resource.ReadRequest{State: updateResp.State}, &readResp)
+	if content := getModel(t, readResp.State).Content.ValueString(); content !=
+		"sip2.example.com" {
+		t.Errorf("Drift wasn't detected, content is %s", content)
+	}
+
+	deleteResp := resource.DeleteResponse{State: readResp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: readResp.State}, &deleteResp)
+	if deleteResp.Diagnostics.HasError() {
+		t.Fatalf("%v", deleteResp.Diagnostics)
+	}
+	if len(njalla.Records("example.com")) != 2 {
+		t.Errorf("Record wasn't removed: %s", njalla.Records("example.com"))
+	}
+
+	// Records removed outside of Terraform are removed from the state
+	readResp = resource.ReadResponse{State: updateResp.State}
+	r.Read(ctx, resource.ReadRequest{State: updateResp.State}, &readResp)
+	if !readResp.State.Raw.IsNull() {
+		t.Errorf("Removed record wasn't removed from the state")
+	}
+}
+
+func TestImportState(t *testing.T) {
+	njalla := newFakeNjalla()
+	defer njalla.Close()
+	r := &recordResource{session: newTestSession(t, njalla)}
+	s := resourceSchema(t)
+	ctx := context.Background()
+
+	importResp := resource.ImportStateResponse{State: emptyState(s)}
+	r.ImportState(ctx, resource.ImportStateRequest{ID: "example.com/1001"}, &importResp)
+	if importResp.Diagnostics.HasError() {
+		t.Fatalf("%v", importResp.Diagnostics)
+	}
+
+	readResp := resource.ReadResponse{State: importResp.State}
+	r.Read(ctx, resource.ReadRequest{State: importResp.State}, &readResp)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("%v", readResp.Diagnostics)
+	}
+
+	expected := nullModel()
+	expected.ID = types.StringValue("1001")
+	expected.Domain = types.StringValue("example.com")
+	expected.Type = types.StringValue("MX")
+	expected.Name = types.StringValue("@")
+	expected.TTL = types.Int64Value(3600)
+	expected.Content = types.StringValue("mail.example.com")
+	expected.Priority = types.Int64Value(10)
+	if diff := cmp.Diff(expected, getModel(t, readResp.State)); diff != "" {
+		t.Errorf("Imported record doesn't match:\n%s", diff)
+	}
+
+	for _, id := range []string{"1001", "example.com/", "/1001", "example.com/mx"} {
+		importResp := resource.ImportStateResponse{State: emptyState(s)}
+		r.ImportState(ctx, resource.ImportStateRequest{ID: id}, &importResp)
+		if !importResp.Diagnostics.HasError() {
+			t.Errorf("Expected an error importing %s", id)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	s := resourceSchema(t)
+	record := func(recordType, content string) recordModel {
+		m := nullModel()
+		m.Domain = types.StringValue("example.com")
+		m.Type = types.StringValue(recordType)
+		if content != "" {
+			m.Content = types.StringValue(content)
+		}
+		return m
+	}
+
+	mxWithoutPriority := record("MX", "mail.example.com")
+	aWithPriority := record("A", "1.1.1.1")
+	aWithPriority.Priority = types.Int64Value(10)
+	redirect := record("Redirect", "https://example.net")
+	redirect.RedirectType = types.Int64Value(301)
+	redirectWithTTL := redirect
+	redirectWithTTL.TTL = types.Int64Value(300)
+	unknownContent := record("A", "")
+	unknownContent.Content = types.StringUnknown()
+	srv := record("SRV", "sip.example.com")
+	srv.Priority = types.Int64Value(10)
+	srv.Weight = types.Int64Value(0)
+	srv.Port = types.Int64Value(0)
+
+	for _, test := range []struct {
+		name     string
+		config   recordModel
+		expected string
+	}{
+		{"valid A", record("A", "1.1.1.1"), ""},
+		{"valid Redirect", redirect, ""},
+		{"unknown content", unknownContent, ""},
+		{"MX without priority", mxWithoutPriority, "MX records need priority"},
+		{"A with priority", aWithPriority, "A records don't have priority"},
+		{"Redirect with TTL", redirectWithTTL, "Redirect records don't have ttl"},
+		{"invalid content", record("A", "not an IP"), "not an IP"},
+		{"SRV port 0", srv, "Port"},
+	} {
+		var resp resource.ValidateConfigResponse
+		(&recordResource{}).ValidateConfig(context.Background(), resource.ValidateConfigRequest{
+			Config: tfsdk.Config{Schema: s, Raw: newState(t, s, test.config).Raw},
+		}, &resp)
+
+		errors := ""
+		for _, d := range resp.Diagnostics.Errors() {
+			errors += d.Summary() + ": " + d.Detail() + "\n"
+		}
+		switch {
+		case test.expected == "" && errors != "":
+			t.Errorf("%s: unexpected errors:\n%s", test.name, errors)
+		case test.expected != "" && !strings.Contains(errors, test.expected):
+			t.Errorf("%s: expected %q, got:\n%s", test.name, test.expected, errors)
+		}
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	ttls := oneOfInts([]int{60, 300})
+	for value, valid := range map[int64]bool{60: true, 300: true, 120: false} {
+		var resp validator.Int64Response
+		ttls.ValidateInt64(context.Background(), validator.Int64Request{
+			Path: path.Root("ttl"), ConfigValue: types.Int64Value(value),
+		}, &resp)
+		if resp.Diagnostics.HasError() == valid {
+			t.Errorf("TTL %d: expected valid %t", value, valid)
+		}
+	}
+
+	var resp validator.StringResponse
+	oneOfStrings(records.Types).ValidateString(
+		context.Background(), validator.StringRequest{
+			Path: path.Root("type"), ConfigValue: types.StringValue("SPF"),
+		}, &resp,
+	)
+	if !resp.Diagnostics.HasError() {
+		t.Errorf("SPF records were accepted")
+	}
+}
+
+func TestTTLDefault(t *testing.T) {
+	s := resourceSchema(t)
+	for recordType, expected := range map[string]types.Int64{
+		"A":        types.Int64Value(defaultTTL),
+		"Redirect": types.Int64Null(),
+	} {
+		m := nullModel()
+		m.Type = types.StringValue(recordType)
+
+		resp := planmodifier.Int64Response{PlanValue: types.Int64Unknown()}
+		ttlDefault{}.PlanModifyInt64(context.Background(), planmodifier.Int64Request{
+			Path:        path.Root("ttl"),
+			ConfigValue: types.Int64Null(),
+			Plan:        tfsdk.Plan{Schema: s, Raw: newState(t, s, m).Raw},
+		}, &resp)
+		if !resp.PlanValue.Equal(expected) {
+			t.Errorf("%s: expected TTL %s, got %s", recordType, expected, resp.PlanValue)
+		}
+	}
+}
+
+func TestRecordConversionRoundTrip(t *testing.T) {
+	for _, record := range []records.Record{
+		&records.RecordA{ID: 1, Type: "A", Name: "@", Content: "1.1.1.1", TTL: 60},
+		&records.RecordMX{
+			ID: 2, Type: "MX", Name: "@", Content: "mail.example.com",
+			TTL: 60, Priority: 10,
+		},
+		&records.RecordSRV{
+			ID: 3, Type: "SRV", Name: "_sip._tcp", Content: "sip.example.com",
+			TTL: 60, Priority: 10, Weight: 5, Port: 5060,
+		},
+		&records.RecordRedirect{
+			ID: 4, Type: "Redirect", Name: "old", URL: "https://example.com",
+			RedirectType: 302,
+		},
+		&records.RecordDynamic{ID: 5, Type: "Dynamic", Name: "home", TTL: 60},
+		&records.RecordSSHFP{
+			ID: 6, Type: "SSHFP", Name: "host", TTL: 60, SSHAlgorithm: 4,
+			SSHType: 2, Content: "123456789abcdef67890123456789abcdef67890",
+		},
+	} {
+		m, err := fromRecord("example.com", record)
+		if err != nil {
+			t.Errorf("%s: %s", record.GetType(), err)
+			continue
+		}
+
+		back, err := m.toRecord()
+		if err != nil {
+			t.Errorf("%s: %s", record.GetType(), err)
+			continue
+		}
+		if diff := cmp.Diff(record, back); diff != "" {
+			t.Errorf("%s doesn't round trip:\n%s", record.GetType(), diff)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// oneOf is a validator.Int64 and validator.String accepting only the given
+// values, so values Njalla would reject fail at plan time
+type oneOf struct {
+	values []string
+}
+
+// oneOfInts returns a oneOf accepting the numbers, such as the TTLs of
+// structures.DefaultCapabilities
+func oneOfInts(values []int) oneOf {
+	v := oneOf{}
+	for _, value := range values {
+		v.values = append(v.values, fmt.Sprint(value))
+	}
+	return v
+}
+
+// oneOfStrings returns a oneOf accepting the strings
+func oneOfStrings(values []string) oneOf {
+	return oneOf{values: values}
+}
+
+func (v oneOf) Description(ctx context.Context) string {
+	return fmt.Sprintf("Must be one of %s", strings.Join(v.values, ", "))
+}
+
+func (v oneOf) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v oneOf) accepts(value string) bool {
+	for _, accepted := range v.values {
+		if value == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+func (v oneOf) ValidateInt64(
+	ctx context.Context, req validator.Int64Request,
+	resp *validator.Int64Response,
+) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	value := fmt.Sprint(req.ConfigValue.ValueInt64())
+	if !v.accepts(value) {
+		resp.Diagnostics.AddAttributeError(
+			req.Path, "Invalid value",
+			fmt.Sprintf("%s isn't accepted. %s.", value, v.Description(ctx)),
+		)
+	}
+}
+
+func (v oneOf) ValidateString(
+	ctx context.Context, req validator.StringRequest,
+	resp *validator.StringResponse,
+) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	value := req.ConfigValue.ValueString()
+	if !v.accepts(value) {
+		resp.Diagnostics.AddAttributeError(
+			req.Path, "Invalid value",
+			fmt.Sprintf("%s isn't accepted. %s.", value, v.Description(ctx)),
+		)
+	}
+}
@@ -0,0 +1,34 @@
+// Command terraform-provider-njalla is a Terraform provider managing the DNS
+// records of Njalla domains, on top of the njalla/provider package
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+
+	"github.com/Sighery/go-njalla-dns-scraper/terraform-provider-njalla/internal/provider"
+)
+
+// version is set when building releases
+var version = "dev"
+
+func main() {
+	debug := flag.Bool(
+		"debug", false, "Start the provider with support for debuggers",
+	)
+	flag.Parse()
+
+	err := providerserver.Serve(
+		context.Background(), provider.New(version),
+		providerserver.ServeOpts{
+			Address: "registry.terraform.io/sighery/njalla",
+			Debug:   *debug,
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
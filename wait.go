@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/propagation"
+	"github.com/Sighery/go-njalla-dns-scraper/njalla/records"
+)
+
+// addWaitFlags adds the --wait and --wait-timeout flags read by
+// waitForChanges
+func addWaitFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(
+		"wait", false,
+		"Wait until every authoritative name server serves the change",
+	)
+	cmd.Flags().Duration(
+		"wait-timeout", 5*time.Minute, "How long --wait waits before failing",
+	)
+}
+
+// waitForChanges waits, if --wait was given, until every authoritative name
+// server of the domain serves the added records and none of the removed ones.
+// Progress is reported on stderr
+func waitForChanges(
+	cmd *cobra.Command, domain string, added, removed records.Records,
+) error {
+	wait, _ := cmd.Flags().GetBool("wait")
+	if !wait {
+		return nil
+	}
+	timeout, _ := cmd.Flags().GetDuration("wait-timeout")
+
+	present := waitableRRs(domain, added)
+	absent := make([]dns.RR, 0)
+	for _, rr := range waitableRRs(domain, removed) {
+		// An update that only changes the TTL would wait forever otherwise
+		if !containsRR(present, rr) {
+			absent = append(absent, rr)
+		}
+	}
+
+	if len(present) == 0 && len(absent) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing to wait for")
+		return nil
+	}
+
+	waiter := propagation.New()
+	waiter.Timeout = timeout
+	waiter.Progress = func(r propagation.Report) {
+		status := "waiting"
+		if r.Err != nil {
+			status = fmt.Sprintf("error: %s", r.Err)
+		} else if r.Ready {
+			status = "ready"
+		}
+		fmt.Fprintf(
+			os.Stderr, "[%d] %s: %s\n", r.Attempt, r.Nameserver, status,
+		)
+	}
+
+	return waiter.Wait(domain, propagation.Expect(present, absent)...)
+}
+
+// waitableRRs converts records into resource records, warning about those
+// with no DNS counterpart, which can't be waited for
+func waitableRRs(domain string, r records.Records) []dns.RR {
+	rrs := make([]dns.RR, 0, len(r))
+	for _, record := range r {
+		rr, err := records.ToRR(record, domain)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Not waiting for %s\n", err)
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+func containsRR(rrs []dns.RR, rr dns.RR) bool {
+	for _, candidate := range rrs {
+		if dns.IsDuplicate(candidate, rr) {
+			return true
+		}
+	}
+	return false
+}